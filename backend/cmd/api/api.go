@@ -1,9 +1,15 @@
 package main
 
 import (
-	"biblia-be/internal/db"
 	"biblia-be/internal/handler"
+	"biblia-be/internal/idempotency"
+	"biblia-be/internal/jobs"
+	"biblia-be/internal/search"
+	"biblia-be/internal/storage"
+	"context"
+	"fmt"
 	"log"
+	"time"
 
 	_ "biblia-be/generated/docs"
 
@@ -18,12 +24,17 @@ type application struct {
 }
 
 type config struct {
-	host string
-	addr string
-	db   dbConfig
+	host        string
+	addr        string
+	db          dbConfig
+	jobs        jobsConfig
+	storage     storageConfig
+	search      searchConfig
+	idempotency idempotencyConfig
 }
 
 type dbConfig struct {
+	driver       string
 	user         string
 	host         string
 	password     string
@@ -32,10 +43,33 @@ type dbConfig struct {
 	maxOpenConns int
 	maxIdleConns int
 	maxIdleTime  int
+	migrate      string
 }
 
-func setupRouter(db *gorm.DB) *gin.Engine {
+type jobsConfig struct {
+	workers      int
+	maxAttempts  int
+	pollInterval int
+}
+
+type storageConfig struct {
+	blobDir       string
+	coverMaxBytes int
+	scanMaxBytes  int
+	epubMaxBytes  int
+}
+
+type searchConfig struct {
+	backend string
+}
+
+type idempotencyConfig struct {
+	ttl int
+}
+
+func (app *application) setupRouter(db *gorm.DB, jobQueue *jobs.Queue, blobStore storage.BlobStore, searchIndex search.SearchIndex) *gin.Engine {
 	router := gin.Default()
+	router.Use(handler.RequestID(), handler.Localize(), handler.ErrorHandler())
 
 	// Testing purpose
 	router.GET("/ping", func(ctx *gin.Context) {
@@ -44,46 +78,217 @@ func setupRouter(db *gorm.DB) *gin.Engine {
 		})
 	})
 
+	// Creating users and records can be retried safely with an Idempotency-Key
+	idempotent := handler.Idempotency(idempotency.NewGormStore(db), time.Duration(app.config.idempotency.ttl)*time.Hour)
+
 	userHandler := handler.UserHandler{}
 	userHandler.Initialize(db)
 
 	router.GET("users", userHandler.GetUsers)
 	router.GET("users/:id", userHandler.GetUser)
-	router.POST("users", userHandler.CreateUser)
+	router.POST("users", idempotent, userHandler.CreateUser)
 	router.PUT("users/:id", userHandler.UpdateUser)
 	router.DELETE("users/:id", userHandler.DeleteUser)
 	router.POST("auth/login", userHandler.AuthenticateUser)
 
 	recordHandler := handler.RecordHandler{}
 	recordHandler.Initialize(db)
+	recordHandler.UseJobs(jobQueue)
 
 	router.GET("records", recordHandler.GetRecords)
-	router.POST("records", recordHandler.CreateRecord)
+	router.GET("records/detail", recordHandler.GetRecordByUserAndISBN)
+	router.POST("records", idempotent, recordHandler.CreateRecord)
 	router.PUT("records", recordHandler.UpdateRecord)
 	router.DELETE("records", recordHandler.DeleteRecord)
+	router.POST("records/import", recordHandler.ImportRecords)
+
+	jobHandler := handler.JobHandler{}
+	jobHandler.Initialize(jobQueue)
+
+	router.GET("jobs/:id", jobHandler.GetJob)
+	router.DELETE("jobs/:id", jobHandler.CancelJob)
+
+	coverHandler := handler.CoverHandler{}
+	coverHandler.Initialize(db, blobStore, int64(app.config.storage.coverMaxBytes))
+
+	router.POST("records/:id/cover", coverHandler.UploadCover)
+	router.GET("covers/:key", coverHandler.GetCover)
+
+	bookHandler := handler.BookHandler{}
+	bookHandler.Initialize(db, int64(app.config.storage.scanMaxBytes))
+
+	router.POST("books/scan", bookHandler.ScanBook)
+
+	ebookHandler := handler.EbookHandler{}
+	ebookHandler.Initialize(db, blobStore, int64(app.config.storage.epubMaxBytes))
+
+	router.POST("records/epub", ebookHandler.UploadEbook)
+	router.GET("records/:id/epub", ebookHandler.DownloadEbook)
+
+	apiKeyHandler := handler.APIKeyHandler{}
+	apiKeyHandler.Initialize(db)
+
+	router.GET("users/:id/api-keys", apiKeyHandler.GetAPIKeys)
+	router.POST("users/:id/api-keys", apiKeyHandler.CreateAPIKey)
+	router.DELETE("users/:id/api-keys/:keyId", apiKeyHandler.DeleteAPIKey)
+
+	opdsHandler := handler.OPDSHandler{}
+	opdsHandler.Initialize(db, blobStore)
+
+	opdsGroup := router.Group("opds", apiKeyHandler.RequireAPIKey("Biblia OPDS"))
+	opdsGroup.GET("", opdsHandler.Catalog)
+	opdsGroup.GET("all", opdsHandler.AllBooks)
+	opdsGroup.GET("status/:status", opdsHandler.BooksByStatus)
+	opdsGroup.GET("shelves/:shelf", opdsHandler.BooksByShelf)
+	opdsGroup.GET("books/:id/epub", opdsHandler.DownloadBook)
+
+	kosyncHandler := handler.KosyncHandler{}
+	kosyncHandler.Initialize(db)
+
+	// KOReader's progress sync protocol; point the device's sync server at this host
+	router.POST("users/create", kosyncHandler.RegisterUser)
+	router.GET("users/auth", kosyncHandler.RequireSyncAuth(), kosyncHandler.AuthorizeUser)
+	router.PUT("syncs/progress", kosyncHandler.RequireSyncAuth(), kosyncHandler.UpdateProgress)
+	router.GET("syncs/progress/:document", kosyncHandler.RequireSyncAuth(), kosyncHandler.GetProgress)
+	router.PUT("records/:id/sync-document", kosyncHandler.LinkDocument)
+
+	searchHandler := handler.SearchHandler{}
+	searchHandler.Initialize(db, searchIndex)
+
+	router.GET("search", searchHandler.Search)
+
+	recommendationHandler := handler.RecommendationHandler{}
+	recommendationHandler.Initialize(db)
+
+	router.GET("users/:id/recommendations", recommendationHandler.GetRecommendations)
+
+	followHandler := handler.FollowHandler{}
+	followHandler.Initialize(db)
+
+	router.GET("users/:id/followers", followHandler.GetFollowers)
+	router.PUT("users/:id/followers/:followerId", followHandler.ApproveFollower)
+	router.DELETE("users/:id/followers/:followerId", followHandler.RemoveFollower)
+	router.GET("users/:id/following", followHandler.GetFollowing)
+	router.POST("users/:id/following/:targetId", followHandler.Follow)
+	router.DELETE("users/:id/following/:targetId", followHandler.Unfollow)
+
+	feedHandler := handler.FeedHandler{}
+	feedHandler.Initialize(db)
+
+	router.GET("feed", feedHandler.GetFeed)
+
+	clubHandler := handler.ClubHandler{}
+	clubHandler.Initialize(db)
+
+	router.GET("clubs", clubHandler.GetClubs)
+	router.POST("clubs", clubHandler.CreateClub)
+	router.GET("clubs/:id", clubHandler.GetClub)
+	router.POST("clubs/:id/members", clubHandler.JoinClub)
+	router.PUT("clubs/:id/members/:memberId", clubHandler.UpdateClubMember)
+	router.DELETE("clubs/:id/members/:memberId", clubHandler.RemoveClubMember)
+	router.PUT("clubs/:id/book", clubHandler.SetClubBook)
+	router.GET("clubs/:id/progress", clubHandler.GetClubProgress)
+	router.GET("clubs/:id/threads", clubHandler.GetClubThreads)
+	router.POST("clubs/:id/threads", clubHandler.CreateClubThread)
+	router.GET("clubs/:id/threads/:threadId", clubHandler.GetClubThread)
+	router.POST("clubs/:id/threads/:threadId/replies", clubHandler.ReplyToClubThread)
+
+	loanHandler := handler.LoanHandler{}
+	loanHandler.Initialize(db)
+
+	router.GET("loans", loanHandler.GetLoans)
+	router.POST("loans", loanHandler.CreateLoan)
+	router.GET("loans/overdue", loanHandler.GetOverdueLoans)
+	router.POST("loans/:id/return", loanHandler.ReturnLoan)
+
+	queueHandler := handler.QueueHandler{}
+	queueHandler.Initialize(db)
+
+	router.GET("users/:id/queue", queueHandler.GetQueue)
+	router.POST("users/:id/queue", queueHandler.AddToQueue)
+	router.GET("users/:id/queue/next", queueHandler.GetNextUp)
+	router.PUT("users/:id/queue/:itemId", queueHandler.UpdateQueueItem)
+	router.PUT("users/:id/queue/:itemId/position", queueHandler.MoveQueueItem)
+	router.DELETE("users/:id/queue/:itemId", queueHandler.RemoveFromQueue)
+
+	syncHandler := handler.SyncHandler{}
+	syncHandler.Initialize(db)
+
+	router.GET("sync", syncHandler.GetChanges)
+	router.POST("sync", idempotent, syncHandler.PushChanges)
+
+	seriesHandler := handler.SeriesHandler{}
+	seriesHandler.Initialize(db)
+
+	router.GET("series", seriesHandler.GetSeries)
+	router.POST("series", seriesHandler.CreateSeries)
+	router.GET("series/:id", seriesHandler.GetSeriesByID)
+	router.POST("series/:id/entries", seriesHandler.AddSeriesEntries)
+	router.DELETE("series/:id/entries/:entryId", seriesHandler.RemoveSeriesEntry)
+	router.GET("users/:id/series", seriesHandler.GetUserSeries)
+	router.GET("users/:id/series/:seriesId", seriesHandler.GetUserSeriesProgress)
 
 	return router
 }
 
+// newSearchIndex creates the configured search backend
+func (app *application) newSearchIndex(db *gorm.DB) (search.SearchIndex, error) {
+	switch app.config.search.backend {
+	case "memory":
+		return search.NewMemoryIndex(), nil
+	case "mysql":
+		if db.Dialector.Name() != "mysql" {
+			return nil, fmt.Errorf("the mysql search backend needs DB_DRIVER=mysql, use SEARCH_BACKEND=memory with %s", db.Dialector.Name())
+		}
+		return search.NewMySQLIndex(db)
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", app.config.search.backend)
+	}
+}
+
 // @title Biblia Backend API
 // @version 1.0
 // @description This is a Biblia backend server.
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
 func (app *application) run() {
-	db, err := db.NewDB(
-		app.config.db.host,
-		app.config.db.user,
-		app.config.db.password,
-		app.config.db.db_name,
-		app.config.db.db_addr,
-		app.config.db.maxOpenConns,
-		app.config.db.maxIdleConns,
-		app.config.db.maxIdleTime)
+	db, err := app.connectDB()
+	if err != nil {
+		log.Panic(err)
+	}
+	if err := app.checkSchema(db); err != nil {
+		log.Panic(err)
+	}
+
+	jobQueue := jobs.NewQueue(db, jobs.Options{
+		Workers:      app.config.jobs.workers,
+		MaxAttempts:  app.config.jobs.maxAttempts,
+		PollInterval: time.Duration(app.config.jobs.pollInterval) * time.Second,
+	})
 
+	blobStore, err := storage.NewLocalStore(app.config.storage.blobDir)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	router := setupRouter(db)
+	searchIndex, err := app.newSearchIndex(db)
+	if err != nil {
+		log.Panic(err)
+	}
+	if err := search.Sync(db, searchIndex); err != nil {
+		log.Panic(err)
+	}
+	go func() {
+		if err := search.ReindexIfEmpty(context.Background(), db, searchIndex); err != nil {
+			log.Printf("failed to build search index: %v", err)
+		}
+	}()
+
+	router := app.setupRouter(db, jobQueue, blobStore, searchIndex)
+
+	jobQueue.Start(context.Background())
+	defer jobQueue.Stop()
 
 	url := ginSwagger.URL("/docs/doc.json")
 	router.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
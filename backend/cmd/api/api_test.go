@@ -0,0 +1,903 @@
+package main
+
+import (
+	"biblia-be/internal/db"
+	"biblia-be/internal/handler"
+	"biblia-be/internal/jobs"
+	"biblia-be/internal/model"
+	"biblia-be/internal/search"
+	"biblia-be/internal/service"
+	"biblia-be/internal/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testResponse is handler.Response with the data left undecoded
+type testResponse struct {
+	Success   bool            `json:"success"`
+	Error     string          `json:"error"`
+	Code      string          `json:"code"`
+	RequestID string          `json:"requestId"`
+	Data      json.RawMessage `json:"data"`
+	Meta      *handler.Meta   `json:"meta"`
+}
+
+// newTestServer runs the whole API against a migrated SQLite database in a
+// temporary directory, so the suite needs neither Docker nor a MySQL server
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newTestServerIn(t, t.TempDir())
+}
+
+// newTestApp configures the application to keep its SQLite database and
+// blobs in dir
+func newTestApp(dir string) *application {
+	return &application{config: config{
+		db: dbConfig{
+			driver:       db.DriverSQLite,
+			db_name:      filepath.Join(dir, "biblia.db"),
+			maxOpenConns: 4,
+			maxIdleConns: 4,
+			migrate:      "auto",
+		},
+		storage: storageConfig{
+			blobDir:       filepath.Join(dir, "blobs"),
+			coverMaxBytes: 1 << 20,
+			scanMaxBytes:  1 << 20,
+			epubMaxBytes:  1 << 20,
+		},
+		search: searchConfig{backend: "memory"},
+	}}
+}
+
+// newTestServerIn runs the API against the database in dir, migrating it first
+func newTestServerIn(t *testing.T, dir string) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	app := newTestApp(dir)
+
+	conn, err := app.connectDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.checkSchema(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	jobQueue := jobs.NewQueue(conn, jobs.Options{Workers: 1, PollInterval: 50 * time.Millisecond})
+	blobStore, err := storage.NewLocalStore(app.config.storage.blobDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	searchIndex, err := app.newSearchIndex(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := search.Sync(conn, searchIndex); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(app.setupRouter(conn, jobQueue, blobStore, searchIndex))
+	jobQueue.Start(context.Background())
+	t.Cleanup(func() {
+		server.Close()
+		jobQueue.Stop()
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return server
+}
+
+// call sends a JSON request and decodes the response envelope, failing the
+// test when the status is not the expected one
+func call(t *testing.T, server *httptest.Server, method, path string, body interface{}, want int) testResponse {
+	t.Helper()
+	resp, _ := send(t, server, method, path, nil, body, want)
+	return resp
+}
+
+// send is call with extra request headers, also returning the response headers
+func send(t *testing.T, server *httptest.Server, method, path string, header http.Header, body interface{}, want int) (testResponse, http.Header) {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, server.URL+path, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var resp testResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		t.Fatalf("%s %s: invalid response: %v", method, path, err)
+	}
+	if res.StatusCode != want {
+		t.Fatalf("%s %s: got %d %q, want %d", method, path, res.StatusCode, resp.Error, want)
+	}
+	return resp, res.Header
+}
+
+// decode unmarshals the data of a response
+func decode[T any](t *testing.T, resp testResponse) T {
+	t.Helper()
+	var data T
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		t.Fatalf("invalid data %s: %v", resp.Data, err)
+	}
+	return data
+}
+
+func TestUsersOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+
+	for _, name := range []string{"somchai", "som_ying", "somsak"} {
+		call(t, server, http.MethodPost, "/users", gin.H{"username": name, "password": "secret1"}, http.StatusCreated)
+	}
+	call(t, server, http.MethodPost, "/users", gin.H{"username": "SOMCHAI", "password": "secret1"}, http.StatusConflict)
+
+	// Prefixes ignore case and match wildcards literally
+	if users := decode[[]handler.UserResponse](t, call(t, server, http.MethodGet, "/users?username=SOM", nil, http.StatusOK)); len(users) != 3 {
+		t.Errorf("prefix SOM: got %d users, want 3", len(users))
+	}
+	if users := decode[[]handler.UserResponse](t, call(t, server, http.MethodGet, "/users?username=som_", nil, http.StatusOK)); len(users) != 1 || users[0].Username != "som_ying" {
+		t.Errorf("prefix som_: got %+v, want som_ying", users)
+	}
+
+	call(t, server, http.MethodPost, "/auth/login", gin.H{"username": "somsak", "password": "secret1"}, http.StatusOK)
+	call(t, server, http.MethodDelete, "/users/3", nil, http.StatusOK)
+	call(t, server, http.MethodGet, "/users/3", nil, http.StatusNotFound)
+}
+
+func TestRecordsOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, http.MethodPost, "/users", gin.H{"username": "malee", "password": "secret1"}, http.StatusCreated)
+
+	genres := []string{"fiction", "fiction", "essay"}
+	for i, isbn := range []string{"9786161851125", "9786161851132", "9786161851149"} {
+		call(t, server, http.MethodPost, "/records", model.CreateRecord{
+			UserID:      1,
+			ISBN:        isbn,
+			Title:       fmt.Sprintf("Book %d", i),
+			Genre:       genres[i],
+			Status:      "reading",
+			CurrentPage: int32(50 * i),
+			TotalPages:  100,
+		}, http.StatusCreated)
+	}
+	call(t, server, http.MethodPut, "/records?userId=1&isbn=9786161851149", model.UpdateRecord{Status: "finished", CurrentPage: 100}, http.StatusOK)
+
+	resp := call(t, server, http.MethodGet, "/records?userId=1&sort=progress&facets=genre,status,yearAdded", nil, http.StatusOK)
+	records := decode[[]model.Record](t, resp)
+	if len(records) != 3 || records[0].ISBN != "9786161851149" || records[2].ISBN != "9786161851125" {
+		t.Errorf("want records by descending progress, got %+v", records)
+	}
+	year := strconv.Itoa(time.Now().Year())
+	if facet := resp.Meta.Facets["yearAdded"]; len(facet) != 1 || facet[0].Value != year || facet[0].Count != 3 {
+		t.Errorf("yearAdded facet: got %+v, want %s: 3", facet, year)
+	}
+	if facet := resp.Meta.Facets["genre"]; len(facet) != 2 || facet[0].Value != "fiction" || facet[0].Count != 2 {
+		t.Errorf("genre facet: got %+v", facet)
+	}
+
+	// Keyset pagination over a computed sort
+	var isbns []string
+	path := "/records?userId=1&sort=progress&limit=1"
+	for path != "" {
+		resp := call(t, server, http.MethodGet, path, nil, http.StatusOK)
+		for _, record := range decode[[]model.Record](t, resp) {
+			isbns = append(isbns, record.ISBN)
+		}
+		path = ""
+		if resp.Meta.NextCursor != "" {
+			path = "/records?userId=1&sort=progress&limit=1&cursor=" + resp.Meta.NextCursor
+		}
+	}
+	if len(isbns) != 3 {
+		t.Errorf("pages: got %v, want 3 records", isbns)
+	}
+
+	hits := decode[handler.SearchResponse](t, call(t, server, http.MethodGet, "/search?userId=1&q=Book", nil, http.StatusOK))
+	if len(hits.Records) != 3 {
+		t.Errorf("search: got %d records, want 3", len(hits.Records))
+	}
+
+	call(t, server, http.MethodDelete, "/records?userId=1&isbn=9786161851125", nil, http.StatusOK)
+	call(t, server, http.MethodDelete, "/records?userId=1&isbn=9786161851125", nil, http.StatusNotFound)
+}
+
+func TestProgressPagesOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	for _, name := range []string{"malee", "somchai"} {
+		call(t, server, http.MethodPost, "/users", gin.H{"username": name, "password": "secret1"}, http.StatusCreated)
+	}
+
+	// Thirds only compare equal to their cursor once both sides are rounded,
+	// and ties fall back to the ID
+	books := []struct {
+		isbn        string
+		currentPage int32
+		totalPages  int32
+	}{
+		{"p-none", 0, 100},
+		{"p-third", 1, 3},
+		{"p-half", 50, 100},
+		{"p-half-again", 100, 200},
+		{"p-two-thirds", 2, 3},
+		{"p-third-again", 100, 300},
+		{"p-unknown", 10, 0},
+	}
+	for _, book := range books {
+		call(t, server, http.MethodPost, "/records", model.CreateRecord{
+			UserID:      1,
+			ISBN:        book.isbn,
+			Title:       book.isbn,
+			CurrentPage: book.currentPage,
+			TotalPages:  book.totalPages,
+		}, http.StatusCreated)
+	}
+	call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 2, ISBN: "p-other", Title: "p-other", CurrentPage: 1, TotalPages: 2}, http.StatusCreated)
+
+	want := []string{"p-two-thirds", "p-half-again", "p-half", "p-third-again", "p-third", "p-unknown", "p-none"}
+	for _, limit := range []int{1, 2, 3, 7} {
+		var isbns []string
+		path := fmt.Sprintf("/records?userId=1&sort=progress&limit=%d", limit)
+		for pages := 0; path != ""; pages++ {
+			if pages > len(books) {
+				t.Fatalf("limit %d: cursor never ran out after %v", limit, isbns)
+			}
+			resp := call(t, server, http.MethodGet, path, nil, http.StatusOK)
+			for _, record := range decode[[]model.Record](t, resp) {
+				isbns = append(isbns, record.ISBN)
+			}
+			path = ""
+			if resp.Meta.NextCursor != "" {
+				path = fmt.Sprintf("/records?userId=1&sort=progress&limit=%d&cursor=%s", limit, resp.Meta.NextCursor)
+			}
+		}
+		if fmt.Sprint(isbns) != fmt.Sprint(want) {
+			t.Errorf("limit %d: got %v, want %v", limit, isbns, want)
+		}
+	}
+}
+
+func TestRecordFacetsOnSQLite(t *testing.T) {
+	dir := t.TempDir()
+	server := newTestServerIn(t, dir)
+	for _, name := range []string{"malee", "somchai"} {
+		call(t, server, http.MethodPost, "/users", gin.H{"username": name, "password": "secret1"}, http.StatusCreated)
+	}
+
+	date := func(year int, month time.Month, day int) *time.Time {
+		t := time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+		return &t
+	}
+	records := []struct {
+		create   model.CreateRecord
+		added    *time.Time
+		finished *time.Time
+	}{
+		{model.CreateRecord{UserID: 1, ISBN: "f-1", Title: "f-1", Genre: "fiction", Status: "reading"}, date(2023, 5, 1), nil},
+		{model.CreateRecord{UserID: 1, ISBN: "f-2", Title: "f-2", Genre: "fiction", Status: "finished"}, date(2024, 1, 10), date(2024, 3, 1)},
+		{model.CreateRecord{UserID: 1, ISBN: "f-3", Title: "f-3", Genre: "essay", Status: "finished"}, date(2024, 2, 1), date(2025, 1, 1)},
+		{model.CreateRecord{UserID: 1, ISBN: "f-4", Title: "f-4", Status: "reading"}, date(2024, 6, 1), nil},
+		{model.CreateRecord{UserID: 2, ISBN: "f-5", Title: "f-5", Genre: "fiction", Status: "reading"}, date(2023, 5, 1), nil},
+	}
+
+	// The API stamps records with the current time, so the dates are set
+	// behind its back
+	conn, err := newTestApp(dir).connectDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	for _, record := range records {
+		call(t, server, http.MethodPost, "/records", record.create, http.StatusCreated)
+		err := conn.Model(&model.Record{}).Where("user_id = ? AND isbn = ?", record.create.UserID, record.create.ISBN).
+			UpdateColumns(map[string]interface{}{"date_added": *record.added, "date_finished": record.finished}).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each facet counts the records matching every filter but its own, and
+	// skips records without a value
+	tests := []struct {
+		query string
+		want  map[string]string
+	}{
+		{"", map[string]string{
+			"genre":        "[{fiction 2} {essay 1}]",
+			"status":       "[{finished 2} {reading 2}]",
+			"yearAdded":    "[{2024 3} {2023 1}]",
+			"yearFinished": "[{2024 1} {2025 1}]",
+		}},
+		{"&genre=fiction", map[string]string{
+			"genre":        "[{fiction 2} {essay 1}]",
+			"status":       "[{finished 1} {reading 1}]",
+			"yearAdded":    "[{2023 1} {2024 1}]",
+			"yearFinished": "[{2024 1}]",
+		}},
+		{"&status=finished&addedFrom=2024-01-01&addedTo=2024-01-31", map[string]string{
+			"genre":        "[{fiction 1}]",
+			"status":       "[{finished 1}]",
+			"yearAdded":    "[{2024 2}]",
+			"yearFinished": "[{2024 1}]",
+		}},
+	}
+	for _, tc := range tests {
+		resp := call(t, server, http.MethodGet, "/records?userId=1&facets=genre,status,yearAdded,yearFinished"+tc.query, nil, http.StatusOK)
+		for name, want := range tc.want {
+			if got := fmt.Sprint(resp.Meta.Facets[name]); got != want {
+				t.Errorf("%q %s facet: got %s, want %s", tc.query, name, got, want)
+			}
+		}
+	}
+}
+
+func TestIdempotencyOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	key := http.Header{handler.IdempotencyKeyHeader: {"create-nok"}}
+	user := gin.H{"username": "nok", "password": "secret1"}
+
+	first, _ := send(t, server, http.MethodPost, "/users", key, user, http.StatusCreated)
+	retry, header := send(t, server, http.MethodPost, "/users", key, user, http.StatusCreated)
+	if header.Get(handler.IdempotentReplayedHeader) != "true" || string(retry.Data) != string(first.Data) {
+		t.Errorf("retry: got %s, want the replayed %s", retry.Data, first.Data)
+	}
+	if users := decode[[]handler.UserResponse](t, call(t, server, http.MethodGet, "/users", nil, http.StatusOK)); len(users) != 1 {
+		t.Errorf("got %d users, want 1", len(users))
+	}
+
+	// The key belongs to the first body and endpoint
+	send(t, server, http.MethodPost, "/users", key, gin.H{"username": "noi", "password": "secret1"}, http.StatusUnprocessableEntity)
+	send(t, server, http.MethodPost, "/records", key, model.CreateRecord{UserID: 1, ISBN: "9786161851125", Title: "Book"}, http.StatusCreated)
+
+	// Failed requests are not stored, so they can be fixed and sent again
+	key = http.Header{handler.IdempotencyKeyHeader: {"create-nam"}}
+	send(t, server, http.MethodPost, "/users", key, gin.H{"username": "nam"}, http.StatusBadRequest)
+	send(t, server, http.MethodPost, "/users", key, gin.H{"username": "nam", "password": "secret1"}, http.StatusCreated)
+}
+
+func TestRecordVersionsOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, http.MethodPost, "/users", gin.H{"username": "kanya", "password": "secret1"}, http.StatusCreated)
+	_, header := send(t, server, http.MethodPost, "/records", nil, model.CreateRecord{UserID: 1, ISBN: "9786161851125", Title: "Book", TotalPages: 100}, http.StatusCreated)
+	first := header.Get("ETag")
+	if _, header := send(t, server, http.MethodGet, "/records/detail?userId=1&isbn=9786161851125", nil, nil, http.StatusOK); first == "" || header.Get("ETag") != first {
+		t.Fatalf("detail ETag: got %q, want %q", header.Get("ETag"), first)
+	}
+
+	// The first device saves; the second still holds the old version
+	resp, header := send(t, server, http.MethodPut, "/records?userId=1&isbn=9786161851125", http.Header{"If-Match": {first}}, model.UpdateRecord{CurrentPage: 10}, http.StatusOK)
+	second := header.Get("ETag")
+	if record := decode[model.Record](t, resp); record.Version != 2 || second == first {
+		t.Errorf("update: got version %d and ETag %q", record.Version, second)
+	}
+	send(t, server, http.MethodPut, "/records?userId=1&isbn=9786161851125", http.Header{"If-Match": {first}}, model.UpdateRecord{CurrentPage: 20}, http.StatusPreconditionFailed)
+	send(t, server, http.MethodDelete, "/records?userId=1&isbn=9786161851125", http.Header{"If-Match": {first}}, nil, http.StatusPreconditionFailed)
+
+	// Without If-Match the last write wins, as before
+	call(t, server, http.MethodPut, "/records?userId=1&isbn=9786161851125", model.UpdateRecord{CurrentPage: 30}, http.StatusOK)
+	send(t, server, http.MethodDelete, "/records?userId=1&isbn=9786161851125", http.Header{"If-Match": {second}}, nil, http.StatusPreconditionFailed)
+	if hits := decode[handler.SearchResponse](t, call(t, server, http.MethodGet, "/search?userId=1&q=Book", nil, http.StatusOK)); len(hits.Records) != 1 {
+		t.Errorf("a refused delete must keep the record searchable, got %d hits", len(hits.Records))
+	}
+	send(t, server, http.MethodDelete, "/records?userId=1&isbn=9786161851125", http.Header{"If-Match": {`"1-3"`}}, nil, http.StatusOK)
+}
+
+func TestCoverUploadOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, http.MethodPost, "/users", gin.H{"username": "pim", "password": "secret1"}, http.StatusCreated)
+	call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 1, ISBN: "1000", Title: "Book", TotalPages: 100}, http.StatusCreated)
+	before := decode[handler.SyncResponse](t, call(t, server, http.MethodGet, "/sync?userId=1", nil, http.StatusOK))
+
+	var picture bytes.Buffer
+	if err := png.Encode(&picture, image.NewGray(image.Rect(0, 0, 40, 60))); err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("cover", "cover.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(picture.Bytes())
+	form.Close()
+
+	res, err := server.Client().Post(server.URL+"/records/1/cover", form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var resp testResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("upload: got %d %q, %v", res.StatusCode, resp.Error, err)
+	}
+	upload := decode[handler.CoverResponse](t, resp)
+	if upload.Record.Version != 2 || upload.Record.Cover != upload.Cover || upload.Cover == "" {
+		t.Errorf("upload: got version %d and cover %q, want version 2 and %q", upload.Record.Version, upload.Record.Cover, upload.Cover)
+	}
+
+	// The new cover is a change to the record like any other
+	delta := decode[handler.SyncResponse](t, call(t, server, http.MethodGet, "/sync?userId=1&since="+before.Token, nil, http.StatusOK))
+	if len(delta.Records) != 1 || delta.Records[0].Cover != upload.Cover || delta.Records[0].Title != "Book" {
+		t.Errorf("delta: got %+v", delta.Records)
+	}
+
+	// The upload is a change to the record, so the ETag read before it is stale
+	send(t, server, http.MethodPut, "/records?userId=1&isbn=1000", http.Header{"If-Match": {`"1-1"`}}, model.UpdateRecord{CurrentPage: 10}, http.StatusPreconditionFailed)
+}
+
+func TestSyncOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, http.MethodPost, "/users", gin.H{"username": "dao", "password": "secret1"}, http.StatusCreated)
+	for _, isbn := range []string{"9786161851125", "9786161851132", "9786161851149"} {
+		call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 1, ISBN: isbn, Title: "Book", TotalPages: 100}, http.StatusCreated)
+	}
+
+	// A full sync in batches of two
+	first := decode[handler.SyncResponse](t, call(t, server, http.MethodGet, "/sync?userId=1&limit=2", nil, http.StatusOK))
+	if len(first.Records) != 2 || !first.HasMore {
+		t.Fatalf("first batch: got %d records, hasMore %v", len(first.Records), first.HasMore)
+	}
+	rest := decode[handler.SyncResponse](t, call(t, server, http.MethodGet, "/sync?userId=1&since="+first.Token, nil, http.StatusOK))
+	if len(rest.Records) != 1 || rest.Records[0].ISBN != "9786161851149" || rest.HasMore {
+		t.Fatalf("second batch: got %+v", rest)
+	}
+
+	// Changes made on another device
+	call(t, server, http.MethodPut, "/records?userId=1&isbn=9786161851125", model.UpdateRecord{CurrentPage: 50}, http.StatusOK)
+	call(t, server, http.MethodDelete, "/records?userId=1&isbn=9786161851132", nil, http.StatusOK)
+	delta := decode[handler.SyncResponse](t, call(t, server, http.MethodGet, "/sync?userId=1&since="+rest.Token, nil, http.StatusOK))
+	if len(delta.Records) != 1 || delta.Records[0].CurrentPage != 50 || len(delta.Deleted) != 1 || delta.Deleted[0].ISBN != "9786161851132" {
+		t.Fatalf("delta: got %+v", delta)
+	}
+
+	// Offline edits pushed in one batch, one made to a version that is now stale
+	notes := "signed copy"
+	push := decode[handler.PushResponse](t, call(t, server, http.MethodPost, "/sync", model.PushChanges{
+		UserID: 1,
+		Changes: []model.RecordChange{
+			{Op: model.ChangeCreate, ISBN: "9786161851156", Record: &model.CreateRecord{Title: "New Book", Shelves: []string{"wishlist"}}},
+			{Op: model.ChangeUpdate, ISBN: "9786161851125", IfMatch: first.Records[0].ETag, Update: &model.UpdateRecord{CurrentPage: 10}},
+			{Op: model.ChangeUpdate, ISBN: "9786161851149", IfMatch: rest.Records[0].ETag, Update: &model.UpdateRecord{CurrentPage: 10, Notes: &notes}},
+			{Op: model.ChangeDelete, ISBN: "9786161851132"},
+			{Op: model.ChangeCreate, ISBN: "9786161851163", Record: &model.CreateRecord{}},
+			{Op: model.ChangeUpdate, ISBN: "9786161851132", Update: &model.UpdateRecord{CurrentPage: 10}},
+		},
+	}, http.StatusOK))
+	want := []string{handler.ChangeApplied, handler.ChangeConflict, handler.ChangeApplied, handler.ChangeApplied, handler.ChangeRejected, handler.ChangeConflict}
+	for i, result := range push.Results {
+		if result.Status != want[i] {
+			t.Errorf("change %d: got %s %s, want %s", i, result.Status, result.Code, want[i])
+		}
+	}
+	if conflict := push.Results[1]; conflict.Record == nil || conflict.Record.CurrentPage != 50 {
+		t.Errorf("conflict must carry the server's record, got %+v", conflict.Record)
+	}
+
+	after := decode[handler.SyncResponse](t, call(t, server, http.MethodGet, "/sync?userId=1&since="+delta.Token, nil, http.StatusOK))
+	if len(after.Records) != 2 || after.Records[0].ISBN != "9786161851156" || after.Records[1].Notes != notes || len(after.Deleted) != 0 {
+		t.Errorf("after push: got %+v", after)
+	}
+
+	call(t, server, http.MethodGet, "/sync?userId=1&since=bogus", nil, http.StatusBadRequest)
+	call(t, server, http.MethodGet, "/sync?userId=9", nil, http.StatusNotFound)
+}
+
+func TestImportJobOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, http.MethodPost, "/users", gin.H{"username": "prasert", "password": "secret1"}, http.StatusCreated)
+
+	job := decode[handler.JobResponse](t, call(t, server, http.MethodPost, "/records/import", model.ImportRecords{
+		UserID: 1,
+		Records: []model.CreateRecord{
+			{ISBN: "9786161851125", Title: "Khu Kam"},
+			{ISBN: "9786161851132", Title: "Lap Lae"},
+			{ISBN: "9786161851149"},
+		},
+	}, http.StatusAccepted))
+
+	deadline := time.Now().Add(10 * time.Second)
+	for job.Status != model.JobSucceeded {
+		if job.Status == model.JobFailed || time.Now().After(deadline) {
+			t.Fatalf("import job did not succeed: %+v", job)
+		}
+		time.Sleep(50 * time.Millisecond)
+		job = decode[handler.JobResponse](t, call(t, server, http.MethodGet, fmt.Sprintf("/jobs/%d", job.ID), nil, http.StatusOK))
+	}
+
+	var result handler.ImportResult
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 2 || len(result.Failed) != 1 {
+		t.Errorf("unexpected import result %+v", result)
+	}
+}
+
+func TestSocialOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, http.MethodPost, "/users", gin.H{"username": "wanida", "password": "secret1"}, http.StatusCreated)
+	call(t, server, http.MethodPost, "/users", gin.H{"username": "kittipong", "password": "secret1"}, http.StatusCreated)
+
+	record := decode[model.Record](t, call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 1, ISBN: "9786161851125", Title: "Khu Kam", TotalPages: 300}, http.StatusCreated))
+
+	call(t, server, http.MethodPost, "/users/2/following/1", nil, http.StatusCreated)
+	if events := decode[[]handler.FeedEvent](t, call(t, server, http.MethodGet, "/feed?userId=2", nil, http.StatusOK)); len(events) == 0 || events[0].Username != "wanida" {
+		t.Errorf("feed: got %+v, want wanida's events", events)
+	}
+
+	borrower := uint(2)
+	loan := decode[handler.LoanResponse](t, call(t, server, http.MethodPost, "/loans", model.CreateLoan{RecordID: record.ID, BorrowerID: &borrower}, http.StatusCreated))
+	if loans := decode[[]handler.LoanResponse](t, call(t, server, http.MethodGet, "/loans?userId=1&active=true", nil, http.StatusOK)); len(loans) != 1 {
+		t.Errorf("active loans: got %d, want 1", len(loans))
+	}
+	call(t, server, http.MethodPost, fmt.Sprintf("/loans/%d/return", loan.ID), nil, http.StatusOK)
+
+	call(t, server, http.MethodPost, "/users/2/queue", model.CreateQueueItem{ISBN: "9786161851132", Title: "Lap Lae", Priority: 1}, http.StatusCreated)
+	call(t, server, http.MethodPost, "/users/2/queue", model.CreateQueueItem{ISBN: "9786161851149", Title: "Phaendin", Priority: 3}, http.StatusCreated)
+	if next := decode[model.QueueItem](t, call(t, server, http.MethodGet, "/users/2/queue/next", nil, http.StatusOK)); next.ISBN != "9786161851149" {
+		t.Errorf("next up: got %s, want the high priority book", next.ISBN)
+	}
+
+	call(t, server, http.MethodPost, "/series", model.CreateSeries{
+		Name:    "Si Phaendin",
+		Entries: []model.CreateSeriesEntry{{ISBN: "9786161851125", Title: "Khu Kam", Position: 1}},
+	}, http.StatusCreated)
+	if series := decode[[]model.Series](t, call(t, server, http.MethodGet, "/series?name=si%20p", nil, http.StatusOK)); len(series) != 1 {
+		t.Errorf("series by name: got %d, want 1", len(series))
+	}
+
+	// Errors of every handler carry a code, a request ID and a catalog message
+	for _, tc := range []struct {
+		method, path string
+		body         interface{}
+		status       int
+		code         string
+	}{
+		{http.MethodPost, "/users/1/following/1", nil, http.StatusBadRequest, handler.CodeSelfFollow},
+		{http.MethodPost, "/loans", model.CreateLoan{RecordID: record.ID}, http.StatusBadRequest, handler.CodeBorrowerRequired},
+		{http.MethodGet, "/users/1/queue/next", nil, http.StatusNotFound, handler.CodeQueueEmpty},
+		{http.MethodGet, "/clubs/99", nil, http.StatusNotFound, handler.CodeClubNotFound},
+		{http.MethodGet, "/clubs/abc", nil, http.StatusBadRequest, handler.CodeInvalidParameter},
+		{http.MethodPost, "/users/2/queue", model.CreateQueueItem{ISBN: "9786161851156"}, http.StatusBadRequest, service.CodeValidation},
+	} {
+		resp := call(t, server, tc.method, tc.path, tc.body, tc.status)
+		if resp.Code != tc.code || resp.RequestID == "" || resp.Error == "" || resp.Error == "error."+tc.code {
+			t.Errorf("%s %s: got %q %q (request %q), want %s", tc.method, tc.path, resp.Code, resp.Error, resp.RequestID, tc.code)
+		}
+	}
+
+	// Deleting a user cascades to their records and follows
+	call(t, server, http.MethodDelete, "/users/1", nil, http.StatusOK)
+	if events := decode[[]handler.FeedEvent](t, call(t, server, http.MethodGet, "/feed?userId=2", nil, http.StatusOK)); len(events) != 0 {
+		t.Errorf("feed after delete: got %d events, want 0", len(events))
+	}
+}
+
+func TestLoansOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	for _, name := range []string{"wanida", "kittipong", "somsak"} {
+		call(t, server, http.MethodPost, "/users", gin.H{"username": name, "password": "secret1"}, http.StatusCreated)
+	}
+	lent := map[string]model.Record{}
+	for _, isbn := range []string{"khu-kam", "lap-lae", "phaendin"} {
+		lent[isbn] = decode[model.Record](t, call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 1, ISBN: isbn, Title: isbn, TotalPages: 300}, http.StatusCreated))
+	}
+	call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 2, ISBN: "phaendin", Title: "phaendin"}, http.StatusCreated)
+
+	// record fetches a user's record of a book with its active loan
+	record := func(userId uint, isbn string) model.Record {
+		t.Helper()
+		records := decode[[]model.Record](t, call(t, server, http.MethodGet, fmt.Sprintf("/records?userId=%d&isbn=%s", userId, isbn), nil, http.StatusOK))
+		if len(records) != 1 {
+			t.Fatalf("user %d: got %d records of %s", userId, len(records), isbn)
+		}
+		return records[0]
+	}
+	// overdue lists the IDs of the overdue loans of a user
+	overdue := func(query string) string {
+		t.Helper()
+		loans := decode[[]handler.LoanResponse](t, call(t, server, http.MethodGet, "/loans/overdue?"+query, nil, http.StatusOK))
+		ids := make([]uint, len(loans))
+		for i, loan := range loans {
+			if !loan.Overdue {
+				t.Errorf("loan %d is listed as overdue but not marked so", loan.ID)
+			}
+			ids[i] = loan.ID
+		}
+		return fmt.Sprint(ids)
+	}
+
+	now := time.Now()
+	days := func(n int) *time.Time {
+		t := now.AddDate(0, 0, n)
+		return &t
+	}
+	kittipong, somsak := uint(2), uint(3)
+
+	// Lending moves the book from the lender's shelf to the borrower's library
+	khuKam := decode[handler.LoanResponse](t, call(t, server, http.MethodPost, "/loans", model.CreateLoan{RecordID: lent["khu-kam"].ID, BorrowerID: &kittipong, LentAt: days(-30), DueAt: days(-10)}, http.StatusCreated))
+	if !khuKam.Overdue || khuKam.LenderName != "wanida" || khuKam.BorrowerName != "kittipong" || khuKam.BorrowerRecordID == nil {
+		t.Errorf("loan: got %+v", khuKam)
+	}
+	if got := record(1, "khu-kam"); got.Ownership != model.OwnershipLent || got.Version != 2 || got.Loan == nil || got.Loan.ID != khuKam.ID {
+		t.Errorf("lender record: got %s version %d with loan %+v", got.Ownership, got.Version, got.Loan)
+	}
+	borrowed := record(2, "khu-kam")
+	if borrowed.ID != *khuKam.BorrowerRecordID || borrowed.Ownership != model.OwnershipBorrowed || borrowed.TotalPages != 300 || borrowed.Loan == nil {
+		t.Errorf("borrower record: got %+v", borrowed)
+	}
+
+	// A borrower's own copy is never overwritten, and nothing of the loan is kept
+	if resp := call(t, server, http.MethodPost, "/loans", model.CreateLoan{RecordID: lent["phaendin"].ID, BorrowerID: &kittipong}, http.StatusConflict); resp.Code != handler.CodeBorrowerHasBook {
+		t.Errorf("borrower has the book: got %s", resp.Code)
+	}
+	if got := record(1, "phaendin"); got.Ownership != model.OwnershipOwned || got.Version != 1 {
+		t.Errorf("lender record after refused loan: got %s version %d", got.Ownership, got.Version)
+	}
+	if got := record(2, "phaendin"); got.Ownership != model.OwnershipOwned || got.Version != 1 {
+		t.Errorf("borrower record after refused loan: got %s version %d", got.Ownership, got.Version)
+	}
+
+	lapLae := decode[handler.LoanResponse](t, call(t, server, http.MethodPost, "/loans", model.CreateLoan{RecordID: lent["lap-lae"].ID, BorrowerName: "Somsri", DueAt: days(10)}, http.StatusCreated))
+	if lapLae.Overdue || lapLae.BorrowerID != nil || lapLae.BorrowerRecordID != nil {
+		t.Errorf("loan to a name: got %+v", lapLae)
+	}
+	phaendin := decode[handler.LoanResponse](t, call(t, server, http.MethodPost, "/loans", model.CreateLoan{RecordID: lent["phaendin"].ID, BorrowerID: &somsak, LentAt: days(-5), DueAt: days(-1)}, http.StatusCreated))
+
+	for _, tc := range []struct {
+		name   string
+		loan   model.CreateLoan
+		status int
+		code   string
+	}{
+		{"lent already", model.CreateLoan{RecordID: lent["khu-kam"].ID, BorrowerID: &somsak}, http.StatusConflict, handler.CodeNotOnShelf},
+		{"due before lent", model.CreateLoan{RecordID: lent["khu-kam"].ID, BorrowerName: "Somsri", DueAt: days(-40)}, http.StatusBadRequest, handler.CodeInvalidDueDate},
+	} {
+		if resp := call(t, server, http.MethodPost, "/loans", tc.loan, tc.status); resp.Code != tc.code {
+			t.Errorf("%s: got %s, want %s", tc.name, resp.Code, tc.code)
+		}
+	}
+
+	// Overdue loans come longest overdue first, on both sides of the loan
+	if got, want := overdue("userId=1"), fmt.Sprint([]uint{khuKam.ID, phaendin.ID}); got != want {
+		t.Errorf("overdue for the lender: got %s, want %s", got, want)
+	}
+	if got, want := overdue("userId=2&role=borrower"), fmt.Sprint([]uint{khuKam.ID}); got != want {
+		t.Errorf("overdue for the borrower: got %s, want %s", got, want)
+	}
+	if got := overdue("userId=2&role=lender"); got != "[]" {
+		t.Errorf("overdue lent by the borrower: got %s, want none", got)
+	}
+	call(t, server, http.MethodGet, "/loans/overdue?userId=1&role=owner", nil, http.StatusBadRequest)
+
+	// Returning puts the book back on the shelf and marks the borrowed copy
+	returned := decode[handler.LoanResponse](t, call(t, server, http.MethodPost, fmt.Sprintf("/loans/%d/return", khuKam.ID), nil, http.StatusOK))
+	if returned.ReturnedAt == nil || returned.Overdue {
+		t.Errorf("returned loan: got %+v", returned)
+	}
+	if got := record(1, "khu-kam"); got.Ownership != model.OwnershipOwned || got.Version != 3 || got.Loan != nil {
+		t.Errorf("lender record after return: got %s version %d", got.Ownership, got.Version)
+	}
+	if got := record(2, "khu-kam"); got.Ownership != model.OwnershipReturned || got.Version != 2 || got.Loan != nil {
+		t.Errorf("borrower record after return: got %s version %d", got.Ownership, got.Version)
+	}
+	if resp := call(t, server, http.MethodPost, fmt.Sprintf("/loans/%d/return", khuKam.ID), nil, http.StatusConflict); resp.Code != handler.CodeLoanReturned {
+		t.Errorf("second return: got %s", resp.Code)
+	}
+	if got, want := overdue("userId=1"), fmt.Sprint([]uint{phaendin.ID}); got != want {
+		t.Errorf("overdue after return: got %s, want %s", got, want)
+	}
+
+	// Borrowing the book again reuses the returned record
+	again := decode[handler.LoanResponse](t, call(t, server, http.MethodPost, "/loans", model.CreateLoan{RecordID: lent["khu-kam"].ID, BorrowerID: &kittipong}, http.StatusCreated))
+	if again.BorrowerRecordID == nil || *again.BorrowerRecordID != borrowed.ID {
+		t.Errorf("second loan: got borrower record %v, want %d", again.BorrowerRecordID, borrowed.ID)
+	}
+
+	// Only one of several simultaneous returns goes through
+	statuses := make(chan int, 20)
+	for i := 0; i < cap(statuses); i++ {
+		go func() {
+			res, err := server.Client().Post(fmt.Sprintf("%s/loans/%d/return", server.URL, again.ID), "application/json", nil)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			res.Body.Close()
+			statuses <- res.StatusCode
+		}()
+	}
+	counts := map[int]int{}
+	for i := 0; i < cap(statuses); i++ {
+		counts[<-statuses]++
+	}
+	if counts[http.StatusOK] != 1 || counts[http.StatusConflict] != cap(statuses)-1 {
+		t.Errorf("simultaneous returns: got statuses %v", counts)
+	}
+	if got := record(1, "khu-kam"); got.Version != 5 {
+		t.Errorf("lender record after simultaneous returns: got version %d, want 5", got.Version)
+	}
+}
+
+func TestSeriesOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, http.MethodPost, "/users", gin.H{"username": "wanida", "password": "secret1"}, http.StatusCreated)
+	for _, book := range []struct{ isbn, title, status string }{
+		{"volume-1", "Volume 1", "finished"},
+		{"novella", "The Novella", "reading"},
+		{"volume-2", "Volume 2", "finished"},
+	} {
+		call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 1, ISBN: book.isbn, Title: book.title, Status: book.status}, http.StatusCreated)
+	}
+
+	// Volumes come in reading order however they were added, with novellas
+	// between two books, and missing titles come from the catalog
+	series := decode[model.Series](t, call(t, server, http.MethodPost, "/series", model.CreateSeries{
+		Name: "Si Phaendin",
+		Entries: []model.CreateSeriesEntry{
+			{ISBN: "volume-3", Title: "Volume 3", Position: 3},
+			{ISBN: "volume-1", Title: "Volume 1", Position: 1},
+			{ISBN: "novella", Position: 1.5},
+		},
+	}, http.StatusCreated))
+	call(t, server, http.MethodPost, "/series", model.CreateSeries{Name: "Unread", Entries: []model.CreateSeriesEntry{{ISBN: "other", Title: "Other", Position: 1}}}, http.StatusCreated)
+	added := decode[model.Series](t, call(t, server, http.MethodPost, fmt.Sprintf("/series/%d/entries", series.ID), []model.CreateSeriesEntry{{ISBN: "volume-2", Title: "Volume 2", Position: 2}}, http.StatusOK))
+
+	order := func(entries []model.SeriesEntry) string {
+		var titles []string
+		for _, entry := range entries {
+			titles = append(titles, fmt.Sprintf("%v %s", entry.Position, entry.Title))
+		}
+		return fmt.Sprint(titles)
+	}
+	want := "[1 Volume 1 1.5 The Novella 2 Volume 2 3 Volume 3]"
+	if got := order(added.Entries); got != want {
+		t.Errorf("after adding: got %s, want %s", got, want)
+	}
+	if got := order(decode[model.Series](t, call(t, server, http.MethodGet, fmt.Sprintf("/series/%d", series.ID), nil, http.StatusOK)).Entries); got != want {
+		t.Errorf("series: got %s, want %s", got, want)
+	}
+
+	// The next volume is the first one in reading order not finished yet, even
+	// when later volumes were read out of order
+	progressPath := fmt.Sprintf("/users/1/series/%d", series.ID)
+	progress := decode[handler.SeriesProgress](t, call(t, server, http.MethodGet, progressPath, nil, http.StatusOK))
+	if progress.Total != 4 || progress.Finished != 2 || progress.Reading != 1 || progress.Next == nil || progress.Next.ISBN != "novella" {
+		t.Errorf("progress: got %+v", progress)
+	}
+	if len(progress.Volumes) != 4 || progress.Volumes[3].RecordID != nil || progress.Volumes[1].Status != "reading" {
+		t.Errorf("volumes: got %+v", progress.Volumes)
+	}
+
+	call(t, server, http.MethodPut, "/records?userId=1&isbn=novella", model.UpdateRecord{Status: "finished"}, http.StatusOK)
+	if next := decode[handler.SeriesProgress](t, call(t, server, http.MethodGet, progressPath, nil, http.StatusOK)).Next; next == nil || next.ISBN != "volume-3" || next.RecordID != nil {
+		t.Errorf("next after the novella: got %+v, want volume-3", next)
+	}
+
+	// Only series the user started are listed, finished ones on request
+	all := decode[[]handler.SeriesProgress](t, call(t, server, http.MethodGet, "/users/1/series", nil, http.StatusOK))
+	if len(all) != 1 || all[0].ID != series.ID || all[0].Next == nil || all[0].Next.ISBN != "volume-3" {
+		t.Errorf("user series: got %+v", all)
+	}
+	call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 1, ISBN: "volume-3", Title: "Volume 3", Status: "finished"}, http.StatusCreated)
+	if all := decode[[]handler.SeriesProgress](t, call(t, server, http.MethodGet, "/users/1/series", nil, http.StatusOK)); len(all) != 0 {
+		t.Errorf("user series once finished: got %+v, want none", all)
+	}
+	if all := decode[[]handler.SeriesProgress](t, call(t, server, http.MethodGet, "/users/1/series?complete=true", nil, http.StatusOK)); len(all) != 1 || all[0].Next != nil || all[0].Finished != 4 {
+		t.Errorf("complete user series: got %+v", all)
+	}
+}
+
+func TestClubSpoilersOnSQLite(t *testing.T) {
+	server := newTestServer(t)
+	for _, name := range []string{"wanida", "kittipong", "somsak", "malee"} {
+		call(t, server, http.MethodPost, "/users", gin.H{"username": name, "password": "secret1"}, http.StatusCreated)
+	}
+
+	const isbn = "9786161851125"
+	club := decode[model.Club](t, call(t, server, http.MethodPost, "/clubs", model.CreateClub{UserID: 1, Name: "Thai classics"}, http.StatusCreated))
+	for _, member := range []int{2, 3} {
+		call(t, server, http.MethodPost, fmt.Sprintf("/clubs/%d/members?userId=%d", club.ID, member), nil, http.StatusCreated)
+	}
+	call(t, server, http.MethodPut, fmt.Sprintf("/clubs/%d/book?userId=1", club.ID), model.SetClubBook{ISBN: isbn, Title: "Khu Kam", TotalPages: 300}, http.StatusOK)
+
+	// kittipong is on page 50, somsak has finished and the owner has no record
+	call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 2, ISBN: isbn, Title: "Khu Kam", Status: "reading", CurrentPage: 50, TotalPages: 300}, http.StatusCreated)
+	call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 3, ISBN: isbn, Title: "Khu Kam", Status: "finished", CurrentPage: 300, TotalPages: 300}, http.StatusCreated)
+
+	threads := fmt.Sprintf("/clubs/%d/threads", club.ID)
+	early := decode[model.ClubPost](t, call(t, server, http.MethodPost, threads, model.CreateClubPost{UserID: 1, Title: "First chapters", Body: "Kobori arrives", Page: 10}, http.StatusCreated))
+	call(t, server, http.MethodPost, threads, model.CreateClubPost{UserID: 1, Title: "The ending", Body: "Kobori dies", Page: 200}, http.StatusCreated)
+	thread := fmt.Sprintf("%s/%d", threads, early.ID)
+	call(t, server, http.MethodPost, thread+"/replies", model.CreateClubPost{UserID: 3, Body: "Wait for the bombing", Page: 250}, http.StatusCreated)
+	call(t, server, http.MethodPost, thread+"/replies", model.CreateClubPost{UserID: 2, Body: "Angsumalin is wary", Page: 50}, http.StatusCreated)
+
+	// hidden lists the bodies a member sees withheld, by title or reply author
+	hidden := func(posts []handler.ClubPostResponse) map[string]bool {
+		result := map[string]bool{}
+		for _, post := range posts {
+			name := post.Title
+			if name == "" {
+				name = post.Username
+			}
+			if post.Spoiler != (post.Body == "") {
+				t.Errorf("post %d: spoiler %v with body %q", post.ID, post.Spoiler, post.Body)
+			}
+			result[name] = post.Spoiler
+		}
+		return result
+	}
+
+	list := decode[[]handler.ClubPostResponse](t, call(t, server, http.MethodGet, threads+"?userId=2", nil, http.StatusOK))
+	if got := hidden(list); !reflect.DeepEqual(got, map[string]bool{"First chapters": false, "The ending": true}) {
+		t.Errorf("threads for kittipong: got %v", got)
+	}
+	for _, post := range list {
+		if post.Title == "First chapters" && post.Replies != 2 {
+			t.Errorf("first thread: got %d replies, want 2", post.Replies)
+		}
+	}
+
+	tests := []struct {
+		userId int
+		want   map[string]bool
+	}{
+		// A post on the member's current page is no spoiler
+		{2, map[string]bool{"First chapters": false, "somsak": true, "kittipong": false}},
+		// Finished books are read to the end
+		{3, map[string]bool{"First chapters": false, "somsak": false, "kittipong": false}},
+		// Members always see their own posts, whatever their progress
+		{1, map[string]bool{"First chapters": false, "somsak": true, "kittipong": true}},
+	}
+	for _, tc := range tests {
+		got := decode[handler.ClubThreadResponse](t, call(t, server, http.MethodGet, fmt.Sprintf("%s?userId=%d", thread, tc.userId), nil, http.StatusOK))
+		if posts := hidden(append([]handler.ClubPostResponse{got.ClubPostResponse}, got.Posts...)); !reflect.DeepEqual(posts, tc.want) {
+			t.Errorf("thread for user %d: got %v, want %v", tc.userId, posts, tc.want)
+		}
+	}
+
+	// Reading further reveals the posts up to the new page
+	call(t, server, http.MethodPut, "/records?userId=2&isbn="+isbn, model.UpdateRecord{CurrentPage: 260}, http.StatusOK)
+	list = decode[[]handler.ClubPostResponse](t, call(t, server, http.MethodGet, threads+"?userId=2", nil, http.StatusOK))
+	if got := hidden(list); !reflect.DeepEqual(got, map[string]bool{"First chapters": false, "The ending": false}) {
+		t.Errorf("threads after reading on: got %v", got)
+	}
+
+	call(t, server, http.MethodGet, threads+"?userId=4", nil, http.StatusForbidden)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"biblia-be/internal/db"
+	"biblia-be/internal/legacy"
+	"biblia-be/internal/search"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// importLegacy runs the import-legacy subcommand and returns the process exit code
+func (app *application) importLegacy(args []string) int {
+	flags := flag.NewFlagSet("import-legacy", flag.ContinueOnError)
+	source := flags.String("source", "", "DSN of the root-module database, e.g. user:pass@tcp(host:3306)/biblia_old?parseTime=true")
+	isbnMap := flags.String("isbn-map", "", "CSV file of legacy book IDs and their ISBNs")
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without writing anything")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *source == "" {
+		fmt.Fprintln(os.Stderr, "-source is required")
+		flags.Usage()
+		return 2
+	}
+
+	var isbns map[uint]string
+	if *isbnMap != "" {
+		file, err := os.Open(*isbnMap)
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		isbns, err = legacy.ReadISBNMap(file)
+		file.Close()
+		if err != nil {
+			log.Printf("reading %s: %v", *isbnMap, err)
+			return 1
+		}
+	}
+
+	sourceDB, err := gorm.Open(mysql.Open(*source), &gorm.Config{})
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if err := legacy.Check(sourceDB); err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	targetDB, err := app.connectDB()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	migrator, err := db.NewMigrator(targetDB)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if err := migrator.Check(); err != nil {
+		log.Printf("%v; run `main migrate up` first", err)
+		return 1
+	}
+
+	// Keep the search index in step with the imported records
+	searchIndex, err := app.newSearchIndex(targetDB)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if err := search.Sync(targetDB, searchIndex); err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	report, err := legacy.NewImporter(sourceDB, targetDB, isbns, *dryRun).Run()
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+	if err != nil {
+		log.Printf("import stopped, run it again to resume: %v", err)
+		return 1
+	}
+	return 0
+}
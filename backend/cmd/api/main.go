@@ -34,6 +34,7 @@ func main() {
 		addr: env.GetString("ADDR", ":8080"),
 		host: env.GetString("HOST", "localhost"),
 		db: dbConfig{
+			driver:       env.GetString("DB_DRIVER", "mysql"),
 			user:         env.GetString("DB_USER", "root"),
 			host:         env.GetString("DB_HOST", "127.0.0.1"),
 			password:     env.GetString("DB_PASSWORD", "znoksy139"),
@@ -42,6 +43,24 @@ func main() {
 			maxOpenConns: env.GetInt("DB_MAX_OPEN_CONNS", 30),
 			maxIdleConns: env.GetInt("DB_MAX_IDLE_CONNS", 30),
 			maxIdleTime:  env.GetInt("DB_MAX_IDLE_TIME", 15),
+			migrate:      env.GetString("DB_MIGRATE", "check"),
+		},
+		jobs: jobsConfig{
+			workers:      env.GetInt("JOB_WORKERS", 2),
+			maxAttempts:  env.GetInt("JOB_MAX_ATTEMPTS", 3),
+			pollInterval: env.GetInt("JOB_POLL_INTERVAL", 2),
+		},
+		storage: storageConfig{
+			blobDir:       env.GetString("BLOB_DIR", "./data/blobs"),
+			coverMaxBytes: env.GetInt("COVER_MAX_BYTES", 5<<20),
+			scanMaxBytes:  env.GetInt("SCAN_MAX_BYTES", 10<<20),
+			epubMaxBytes:  env.GetInt("EPUB_MAX_BYTES", 50<<20),
+		},
+		search: searchConfig{
+			backend: env.GetString("SEARCH_BACKEND", "mysql"),
+		},
+		idempotency: idempotencyConfig{
+			ttl: env.GetInt("IDEMPOTENCY_TTL", 24),
 		},
 	}
 
@@ -49,5 +68,14 @@ func main() {
 		config: cfg,
 	}
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(app.migrate(os.Args[2:]))
+		case "import-legacy":
+			os.Exit(app.importLegacy(os.Args[2:]))
+		}
+	}
+
 	app.run()
 }
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"biblia-be/internal/changes"
+	"biblia-be/internal/db"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"gorm.io/gorm"
+)
+
+const migrateUsage = `usage: main migrate <command>
+
+commands:
+  up             apply all pending migrations
+  down [steps]   revert the last applied migration, or the last steps ones
+  status         list migrations and whether they are applied
+  force VERSION  mark the schema clean at VERSION after repairing a failed migration`
+
+// connectDB opens the configured database. Every record written through the
+// connection is numbered for syncing, whichever command writes it.
+func (app *application) connectDB() (*gorm.DB, error) {
+	conn, err := db.NewDB(
+		app.config.db.driver,
+		app.config.db.host,
+		app.config.db.user,
+		app.config.db.password,
+		app.config.db.db_name,
+		app.config.db.db_addr,
+		app.config.db.maxOpenConns,
+		app.config.db.maxIdleConns,
+		app.config.db.maxIdleTime)
+	if err != nil {
+		return nil, err
+	}
+	if err := changes.Track(conn); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// checkSchema makes sure the database is migrated before the server starts.
+// DB_MIGRATE=check refuses to start on a pending or dirty schema, auto applies
+// pending migrations first and off starts regardless.
+func (app *application) checkSchema(conn *gorm.DB) error {
+	if app.config.db.migrate == "off" {
+		log.Println("schema check is disabled, the database may not match this build")
+		return nil
+	}
+
+	migrator, err := db.NewMigrator(conn)
+	if err != nil {
+		return err
+	}
+
+	switch app.config.db.migrate {
+	case "check":
+		err = migrator.Check()
+		if errors.Is(err, db.ErrPendingMigrations) {
+			return fmt.Errorf("%w; run `main migrate up` or set DB_MIGRATE=auto", err)
+		}
+		return err
+	case "auto":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			log.Printf("applied migration %04d_%s", migration.Version, migration.Name)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown DB_MIGRATE mode %q, expected check, auto or off", app.config.db.migrate)
+	}
+}
+
+// migrate runs the migrate subcommand and returns the process exit code
+func (app *application) migrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	conn, err := app.connectDB()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	migrator, err := db.NewMigrator(conn)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "steps must be a positive number")
+				return 2
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state = "applied"
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Dirty {
+				state = "dirty"
+			}
+			if status.Unknown {
+				state += " (unknown to this build)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		w.Flush()
+	case "force":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "VERSION must be a migration number")
+			return 2
+		}
+		if err := migrator.Force(uint(version)); err != nil {
+			log.Println(err)
+			return 1
+		}
+		fmt.Printf("schema marked clean at %04d\n", version)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
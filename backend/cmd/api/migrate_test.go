@@ -0,0 +1,101 @@
+package main
+
+import (
+	"biblia-be/internal/db"
+	"biblia-be/internal/handler"
+	"biblia-be/internal/model"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// automigrateUser and automigrateRecord are the models AutoMigrate created
+// tables from before versioned migrations
+type automigrateUser struct {
+	ID             uint `gorm:"primaryKey"`
+	Username       string
+	Password       string
+	FavoriteGenres []string            `gorm:"serializer:json"`
+	Records        []automigrateRecord `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (automigrateUser) TableName() string {
+	return "users"
+}
+
+type automigrateRecord struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement"`
+	UserID      uint   `gorm:"uniqueIndex:idx_user_isbn;foreignKey:UserID;references:ID"`
+	ISBN        string `gorm:"type:varchar(20);uniqueIndex:idx_user_isbn"`
+	Title       string
+	Author      string
+	Cover       string
+	Genre       string
+	Status      string
+	CurrentPage int32
+	TotalPages  int32
+	DateAdded   time.Time
+	User        automigrateUser `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (automigrateRecord) TableName() string {
+	return "records"
+}
+
+func TestMigrateUpAdoptsAutoMigrateSchemaOnSQLite(t *testing.T) {
+	dir := t.TempDir()
+	conn, err := db.NewDB(db.DriverSQLite, "", "", "", filepath.Join(dir, "biblia.db"), "", 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&automigrateUser{}, &automigrateRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	user := automigrateUser{Username: "prasert", Password: "secret1", FavoriteGenres: []string{"history"}}
+	if err := conn.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	record := automigrateRecord{UserID: user.ID, ISBN: "9786161851125", Title: "Khu Kam", Status: "reading", TotalPages: 300, DateAdded: time.Now()}
+	if err := conn.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+
+	if code := newTestApp(dir).migrate([]string{"up"}); code != 0 {
+		t.Fatalf("migrate up: exit code %d", code)
+	}
+
+	server := newTestServerIn(t, dir)
+
+	// Rows kept from before get the defaults of the added columns
+	if got := decode[handler.UserResponse](t, call(t, server, http.MethodGet, "/users/1", nil, http.StatusOK)); got.Username != "prasert" || got.Privacy != model.PrivacyPublic {
+		t.Errorf("adopted user: got %+v", got)
+	}
+	if got := decode[model.Record](t, call(t, server, http.MethodGet, "/records/detail?userId=1&isbn=9786161851125", nil, http.StatusOK)); got.Title != "Khu Kam" || got.Ownership != model.OwnershipOwned || got.Version != 1 {
+		t.Errorf("adopted record: got %+v", got)
+	}
+
+	// Every column of the current models can be written and read back
+	call(t, server, http.MethodPost, "/users", gin.H{"username": "kanya", "password": "secret1", "privacy": "private"}, http.StatusCreated)
+	notes, rating := "Read twice", int8(4)
+	call(t, server, http.MethodPut, "/records?userId=1&isbn=9786161851125", model.UpdateRecord{
+		Status: "finished", CurrentPage: 300, Notes: &notes, Shelves: []string{"classics"}, Rating: &rating,
+	}, http.StatusOK)
+	call(t, server, http.MethodPost, "/records", model.CreateRecord{UserID: 1, ISBN: "9786161851132", Title: "Lap Lae", TotalPages: 200}, http.StatusCreated)
+
+	records := decode[[]model.Record](t, call(t, server, http.MethodGet, "/records?userId=1&status=finished", nil, http.StatusOK))
+	if len(records) != 1 || records[0].Notes != "Read twice" || len(records[0].Shelves) != 1 || records[0].DateFinished == nil {
+		t.Errorf("finished records: got %+v", records)
+	}
+	call(t, server, http.MethodPost, "/users/2/following/1", nil, http.StatusCreated)
+	if events := decode[[]handler.FeedEvent](t, call(t, server, http.MethodGet, "/feed?userId=2", nil, http.StatusOK)); len(events) == 0 {
+		t.Error("feed: got no events")
+	}
+}
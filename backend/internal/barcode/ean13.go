@@ -0,0 +1,324 @@
+// Package barcode decodes EAN-13 barcodes from photos without external dependencies.
+//
+// The decoder samples scanlines across the image in both orientations, binarizes
+// each line, run-length encodes it and looks for the guard patterns of an EAN-13
+// symbol. Digit widths are matched against the L, G and R code tables, the first
+// digit is recovered from the L/G parity of the left half and the check digit is
+// verified. The result read most often across scanlines wins.
+package barcode
+
+import (
+	"biblia-be/internal/isbn"
+	"errors"
+	"image"
+	"math"
+)
+
+// ErrNotFound is returned when no valid EAN-13 barcode could be read
+var ErrNotFound = errors.New("no EAN-13 barcode found")
+
+// scanlines is how many lines are sampled per orientation
+const scanlines = 48
+
+// maxDigitError is the largest normalized width error accepted for a digit
+const maxDigitError = 0.38
+
+// maxGuardError is the largest relative deviation accepted within a guard pattern
+const maxGuardError = 0.5
+
+// lPatterns are the space/bar module widths of the L code for each digit. R codes
+// have the same widths starting with a bar; G codes are the L widths reversed.
+var lPatterns = [10][4]int{
+	{3, 2, 1, 1},
+	{2, 2, 2, 1},
+	{2, 1, 2, 2},
+	{1, 4, 1, 1},
+	{1, 1, 3, 2},
+	{1, 2, 3, 1},
+	{1, 1, 1, 4},
+	{1, 3, 1, 2},
+	{1, 2, 1, 3},
+	{3, 1, 1, 2},
+}
+
+// firstDigitParity maps the L/G parity of the six left digits (bit set for G,
+// most significant bit first) to the implied first digit
+var firstDigitParity = map[int]byte{
+	0b000000: 0,
+	0b001011: 1,
+	0b001101: 2,
+	0b001110: 3,
+	0b010011: 4,
+	0b011001: 5,
+	0b011100: 6,
+	0b010101: 7,
+	0b010110: 8,
+	0b011010: 9,
+}
+
+// DecodeEAN13 returns the 13 digits of the EAN-13 barcode found in img
+func DecodeEAN13(img image.Image) (string, error) {
+	gray := toLuminance(img)
+	votes := map[string]int{}
+
+	for _, vertical := range []bool{false, true} {
+		for i := 1; i <= scanlines; i++ {
+			line := gray.line(vertical, i*gray.span(!vertical)/(scanlines+1))
+			for _, bits := range binarize(line) {
+				runs := runLengths(bits)
+				if code, ok := decodeRuns(runs); ok {
+					votes[code]++
+				}
+				if code, ok := decodeRuns(reversed(runs)); ok {
+					votes[code]++
+				}
+			}
+		}
+	}
+
+	best, bestVotes := "", 0
+	for code, n := range votes {
+		if n > bestVotes || n == bestVotes && code < best {
+			best, bestVotes = code, n
+		}
+	}
+	if best == "" {
+		return "", ErrNotFound
+	}
+	return best, nil
+}
+
+// luminance is an 8-bit grayscale copy of an image
+type luminance struct {
+	w, h int
+	pix  []uint8
+}
+
+func toLuminance(img image.Image) *luminance {
+	b := img.Bounds()
+	l := &luminance{w: b.Dx(), h: b.Dy(), pix: make([]uint8, b.Dx()*b.Dy())}
+	for y := 0; y < l.h; y++ {
+		for x := 0; x < l.w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			// ITU-R BT.601 luma on 16-bit channels
+			l.pix[y*l.w+x] = uint8((299*r + 587*g + 114*bl) / 1000 >> 8)
+		}
+	}
+	return l
+}
+
+// span returns the image width for horizontal lines and height for vertical ones
+func (l *luminance) span(vertical bool) int {
+	if vertical {
+		return l.h
+	}
+	return l.w
+}
+
+// line returns row pos, or column pos when vertical
+func (l *luminance) line(vertical bool, pos int) []uint8 {
+	if !vertical {
+		return l.pix[pos*l.w : (pos+1)*l.w]
+	}
+	col := make([]uint8, l.h)
+	for y := 0; y < l.h; y++ {
+		col[y] = l.pix[y*l.w+pos]
+	}
+	return col
+}
+
+// binarize returns candidate black (true) / white (false) readings of a line: one
+// with a global midpoint threshold and one with a moving-average threshold that
+// tolerates uneven lighting across the photo
+func binarize(line []uint8) [][]bool {
+	if len(line) < 95 {
+		return nil
+	}
+
+	lo, hi := uint8(255), uint8(0)
+	for _, v := range line {
+		lo = min(lo, v)
+		hi = max(hi, v)
+	}
+	if hi-lo < 32 {
+		return nil
+	}
+
+	mid := (int(lo) + int(hi)) / 2
+	global := make([]bool, len(line))
+	for i, v := range line {
+		global[i] = int(v) < mid
+	}
+
+	window := max(len(line)/8, 16)
+	prefix := make([]int, len(line)+1)
+	for i, v := range line {
+		prefix[i+1] = prefix[i] + int(v)
+	}
+	local := make([]bool, len(line))
+	for i, v := range line {
+		from, to := max(i-window/2, 0), min(i+window/2+1, len(line))
+		mean := (prefix[to] - prefix[from]) / (to - from)
+		// Bias towards white so flat paper does not turn into noise
+		local[i] = int(v) < mean-4
+	}
+
+	return [][]bool{global, local}
+}
+
+// run is a stretch of same-colored pixels
+type run struct {
+	black bool
+	width int
+}
+
+func runLengths(bits []bool) []run {
+	var runs []run
+	for i, b := range bits {
+		if i == 0 || b != bits[i-1] {
+			runs = append(runs, run{black: b})
+		}
+		runs[len(runs)-1].width++
+	}
+	return runs
+}
+
+func reversed(runs []run) []run {
+	out := make([]run, len(runs))
+	for i, r := range runs {
+		out[len(runs)-1-i] = r
+	}
+	return out
+}
+
+// symbolRuns is the number of runs from the start guard through the end guard
+const symbolRuns = 3 + 6*4 + 5 + 6*4 + 3
+
+// decodeRuns looks for an EAN-13 symbol starting at any black run
+func decodeRuns(runs []run) (string, bool) {
+	for start := 1; start+symbolRuns <= len(runs); start++ {
+		if !runs[start].black {
+			continue
+		}
+		if code, ok := decodeAt(runs[start:start+symbolRuns], runs[start-1].width); ok {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// decodeAt decodes a symbol whose start guard is the first run of runs
+func decodeAt(runs []run, quietZone int) (string, bool) {
+	module := float64(runs[0].width+runs[1].width+runs[2].width) / 3
+	if !guard(runs[0:3], module) || float64(quietZone) < 3*module {
+		return "", false
+	}
+
+	digits := make([]byte, 13)
+	parity := 0
+	pos := 3
+
+	for i := 0; i < 6; i++ {
+		digit, isG, ok := matchDigit(runs[pos:pos+4], true)
+		if !ok {
+			return "", false
+		}
+		digits[i+1] = digit
+		parity <<= 1
+		if isG {
+			parity |= 1
+		}
+		pos += 4
+	}
+
+	if !guard(runs[pos:pos+5], module) {
+		return "", false
+	}
+	pos += 5
+
+	for i := 0; i < 6; i++ {
+		digit, _, ok := matchDigit(runs[pos:pos+4], false)
+		if !ok {
+			return "", false
+		}
+		digits[i+7] = digit
+		pos += 4
+	}
+
+	if !guard(runs[pos:pos+3], module) {
+		return "", false
+	}
+
+	first, ok := firstDigitParity[parity]
+	if !ok {
+		return "", false
+	}
+	digits[0] = first
+
+	code := make([]byte, 13)
+	for i, d := range digits {
+		code[i] = '0' + d
+	}
+	if !isbn.ValidEAN13(string(code)) {
+		return "", false
+	}
+	return string(code), true
+}
+
+// guard checks that every run of a guard pattern is about one module wide
+func guard(runs []run, module float64) bool {
+	for _, r := range runs {
+		if math.Abs(float64(r.width)-module) > module*maxGuardError+1 {
+			return false
+		}
+	}
+	return true
+}
+
+// matchDigit finds the digit whose pattern best fits four runs. Left-half digits
+// may use the L or G code; right-half digits use the R code.
+func matchDigit(runs []run, left bool) (byte, bool, bool) {
+	total := 0
+	for _, r := range runs {
+		total += r.width
+	}
+	if total == 0 {
+		return 0, false, false
+	}
+
+	var widths [4]float64
+	for i, r := range runs {
+		widths[i] = float64(r.width) * 7 / float64(total)
+	}
+
+	bestDigit, bestG, bestErr := byte(0), false, math.MaxFloat64
+	for d, pattern := range lPatterns {
+		if e := patternError(widths, pattern, false); e < bestErr {
+			bestDigit, bestG, bestErr = byte(d), false, e
+		}
+		if left {
+			if e := patternError(widths, pattern, true); e < bestErr {
+				bestDigit, bestG, bestErr = byte(d), true, e
+			}
+		}
+	}
+
+	if bestErr > maxDigitError {
+		return 0, false, false
+	}
+	return bestDigit, bestG, true
+}
+
+// patternError is the mean absolute module error between widths and a pattern,
+// optionally reversed for the G code
+func patternError(widths [4]float64, pattern [4]int, reverse bool) float64 {
+	sum := 0.0
+	for i := 0; i < 4; i++ {
+		p := pattern[i]
+		if reverse {
+			p = pattern[3-i]
+		}
+		sum += math.Abs(widths[i] - float64(p))
+	}
+	return sum / 4
+}
@@ -0,0 +1,179 @@
+package barcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// modulePixels is the width of one module in rendered symbols
+const modulePixels = 3
+
+// encode returns the 95 modules of an EAN-13 symbol, true for bars. The check
+// digit is taken as given so that invalid symbols can be drawn too.
+func encode(t *testing.T, code string) []bool {
+	t.Helper()
+	if len(code) != 13 {
+		t.Fatalf("code %q is not 13 digits", code)
+	}
+
+	parity := -1
+	for p, first := range firstDigitParity {
+		if first == code[0]-'0' {
+			parity = p
+		}
+	}
+
+	var modules []bool
+	add := func(widths []int, bar bool) {
+		for _, w := range widths {
+			for i := 0; i < w; i++ {
+				modules = append(modules, bar)
+			}
+			bar = !bar
+		}
+	}
+
+	add([]int{1, 1, 1}, true)
+	for i := 1; i <= 6; i++ {
+		pattern := lPatterns[code[i]-'0']
+		if parity&(1<<(6-i)) != 0 {
+			pattern = [4]int{pattern[3], pattern[2], pattern[1], pattern[0]}
+		}
+		add(pattern[:], false)
+	}
+	add([]int{1, 1, 1, 1, 1}, false)
+	for i := 7; i <= 12; i++ {
+		pattern := lPatterns[code[i]-'0']
+		add(pattern[:], true)
+	}
+	add([]int{1, 1, 1}, true)
+	return modules
+}
+
+// render draws a symbol with a quiet zone of ten modules on each side
+func render(modules []bool) *image.Gray {
+	width := (len(modules) + 20) * modulePixels
+	img := image.NewGray(image.Rect(0, 0, width, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+			if m := x/modulePixels - 10; m >= 0 && m < len(modules) && modules[m] {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+	return img
+}
+
+// rotate turns img by degrees around its center onto a white canvas large
+// enough to hold it
+func rotate(img *image.Gray, degrees float64) *image.Gray {
+	rad := degrees * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	w, h := float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+	size := int(math.Ceil(math.Hypot(w, h)))
+
+	out := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)-float64(size)/2, float64(y)-float64(size)/2
+			sx, sy := int(dx*cos+dy*sin+w/2), int(-dx*sin+dy*cos+h/2)
+			out.SetGray(x, y, color.Gray{Y: 255})
+			if image.Pt(sx, sy).In(img.Bounds()) {
+				out.SetGray(x, y, img.GrayAt(sx, sy))
+			}
+		}
+	}
+	return out
+}
+
+// addNoise dims the image towards one side and adds random speckle
+func addNoise(img *image.Gray, seed int64) *image.Gray {
+	random := rand.New(rand.NewSource(seed))
+	b := img.Bounds()
+	out := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			v := float64(img.GrayAt(x, y).Y)
+			v = 40 + v*0.6 - 30*float64(x)/float64(b.Dx()) + random.NormFloat64()*12
+			out.SetGray(x, y, color.Gray{Y: uint8(math.Max(0, math.Min(255, v)))})
+		}
+	}
+	return out
+}
+
+func TestDecodeEAN13(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"ISBN-13 with 978 prefix", "9780306406157"},
+		{"ISBN-13 with 978 prefix and Thai group", "9786161851125"},
+		{"EAN-13", "4006381333931"},
+		{"leading zero", "0012345678905"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeEAN13(render(encode(t, tc.code)))
+			if err != nil || got != tc.code {
+				t.Errorf("got %q, %v; want %q", got, err, tc.code)
+			}
+		})
+	}
+}
+
+func TestDecodeEAN13Transformed(t *testing.T) {
+	const code = "9786161851125"
+	img := render(encode(t, code))
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"upside down", rotate(img, 180)},
+		{"portrait", rotate(img, 90)},
+		{"tilted", rotate(img, 4)},
+		{"noisy", addNoise(img, 1)},
+		{"tilted and noisy", addNoise(rotate(img, -6), 2)},
+		{"portrait and noisy", addNoise(rotate(img, 270), 3)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeEAN13(tc.img)
+			if err != nil || got != code {
+				t.Errorf("got %q, %v; want %q", got, err, code)
+			}
+		})
+	}
+}
+
+func TestDecodeEAN13Rejects(t *testing.T) {
+	blank := image.NewGray(image.Rect(0, 0, 400, 80))
+	for i := range blank.Pix {
+		blank.Pix[i] = 255
+	}
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"bad check digit", render(encode(t, "9786161851126"))},
+		{"bad check digit, noisy", addNoise(render(encode(t, "9780306406150")), 4)},
+		{"blank", blank},
+		{"too small", render(encode(t, "9786161851125")).SubImage(image.Rect(0, 0, 60, 80))},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := DecodeEAN13(tc.img); !errors.Is(err, ErrNotFound) {
+				t.Errorf("got %q, %v; want ErrNotFound", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// Package changes numbers the writes to the records of each user, so clients
+// that keep a copy of their library can fetch only what changed since their
+// last sync, deletions included.
+package changes
+
+import (
+	"biblia-be/internal/model"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownUser is returned for changes of a user that does not exist
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrInvalidToken is returned for tokens that were not issued by Token
+	ErrInvalidToken = errors.New("invalid change token")
+)
+
+// Tombstone is a row of record_tombstones, left behind by a deleted record
+type Tombstone struct {
+	UserID    uint      `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	ChangeSeq uint64    `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	RecordID  uint      `json:"recordID"`
+	ISBN      string    `json:"isbn"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+func (Tombstone) TableName() string {
+	return "record_tombstones"
+}
+
+// Feed is a batch of changes to the records of a user, oldest first. Records
+// are in their current state; a record changed several times appears once.
+type Feed struct {
+	Records []model.Record
+	Deleted []Tombstone
+	// Seq is the change number to continue from
+	Seq uint64
+	// More is set when changes were left for the next batch
+	More bool
+	// Reset is set when the position asked for is ahead of the server, as after
+	// restoring a backup. The feed then starts over from the beginning.
+	Reset bool
+}
+
+// Token encodes a change number for clients, who should treat it as opaque
+func Token(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(seq, 10)))
+}
+
+// ParseToken decodes a token issued by Token. The empty token is the start.
+func ParseToken(token string) (uint64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	seq, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return seq, nil
+}
+
+// Head returns the number of the latest change of a user
+func Head(ctx context.Context, db *gorm.DB, userID uint) (uint64, error) {
+	var head uint64
+	err := db.WithContext(ctx).Table("users").Select("change_seq").Where("id = ?", userID).Row().Scan(&head)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUnknownUser
+	}
+	return head, err
+}
+
+// Since returns up to limit changes of a user made after change number after.
+// Only changes committed when the call starts are included, so continuing from
+// Feed.Seq never skips a write that was in flight. Deletions are left out when
+// starting from the beginning, as the client has nothing to delete.
+func Since(ctx context.Context, db *gorm.DB, userID uint, after uint64, limit int) (Feed, error) {
+	head, err := Head(ctx, db, userID)
+	if err != nil {
+		return Feed{}, err
+	}
+
+	feed := Feed{Records: []model.Record{}, Deleted: []Tombstone{}, Seq: head}
+	if after > head {
+		after, feed.Reset = 0, true
+	}
+
+	// Numbers are unique per user across records and tombstones, so the two
+	// lists merge into a single order
+	conn := db.WithContext(ctx)
+	var records []model.Record
+	err = conn.Where("user_id = ? AND change_seq > ? AND change_seq <= ?", userID, after, head).
+		Order("change_seq").Limit(limit + 1).Find(&records).Error
+	if err != nil {
+		return Feed{}, err
+	}
+	var deleted []Tombstone
+	if after > 0 {
+		err = conn.Where("user_id = ? AND change_seq > ? AND change_seq <= ?", userID, after, head).
+			Order("change_seq").Limit(limit + 1).Find(&deleted).Error
+		if err != nil {
+			return Feed{}, err
+		}
+	}
+
+	i, j := 0, 0
+	var last uint64
+	for i+j < limit && (i < len(records) || j < len(deleted)) {
+		if j == len(deleted) || (i < len(records) && records[i].ChangeSeq < deleted[j].ChangeSeq) {
+			last = records[i].ChangeSeq
+			i++
+		} else {
+			last = deleted[j].ChangeSeq
+			j++
+		}
+	}
+	feed.Records = append(feed.Records, records[:i]...)
+	feed.Deleted = append(feed.Deleted, deleted[:j]...)
+	if i < len(records) || j < len(deleted) {
+		feed.More = true
+		feed.Seq = last
+	}
+	return feed, nil
+}
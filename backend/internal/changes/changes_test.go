@@ -0,0 +1,221 @@
+package changes
+
+import (
+	"biblia-be/internal/db"
+	"biblia-be/internal/model"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB returns a migrated SQLite database with change tracking on and a
+// user with ID 1
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := db.NewDB(db.DriverSQLite, "", "", "", filepath.Join(t.TempDir(), "changes.db"), "", 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := Track(conn); err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := db.NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Create(&model.User{Username: "wanida"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// addRecord creates a record of user 1
+func addRecord(t *testing.T, conn *gorm.DB, isbn string) model.Record {
+	t.Helper()
+	record := model.Record{UserID: 1, ISBN: isbn, Title: "Title " + isbn, TotalPages: 100}
+	if err := conn.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+// storedSeq returns the change number stored with a record
+func storedSeq(t *testing.T, conn *gorm.DB, id uint) uint64 {
+	t.Helper()
+	var record model.Record
+	if err := conn.First(&record, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return record.ChangeSeq
+}
+
+func isbns(records []model.Record) []string {
+	list := []string{}
+	for _, record := range records {
+		list = append(list, record.ISBN)
+	}
+	return list
+}
+
+func TestTrack(t *testing.T) {
+	conn := newTestDB(t)
+	ctx := context.Background()
+
+	first := addRecord(t, conn, "9786161851125")
+	second := addRecord(t, conn, "9786161851132")
+	if first.ChangeSeq != 1 || second.ChangeSeq != 2 {
+		t.Fatalf("created: got %d, %d, want 1, 2", first.ChangeSeq, second.ChangeSeq)
+	}
+
+	// Several records created at once take consecutive numbers
+	batch := []model.Record{
+		{UserID: 1, ISBN: "9786161851149", Title: "Phaendin", TotalPages: 100},
+		{UserID: 1, ISBN: "9786161851156", Title: "Lap Lae", TotalPages: 100},
+	}
+	if err := conn.Create(&batch).Error; err != nil {
+		t.Fatal(err)
+	}
+	if batch[0].ChangeSeq != 3 || batch[1].ChangeSeq != 4 {
+		t.Fatalf("batch: got %d, %d, want 3, 4", batch[0].ChangeSeq, batch[1].ChangeSeq)
+	}
+
+	if err := conn.Model(&first).Update("status", "reading").Error; err != nil {
+		t.Fatal(err)
+	}
+	if seq := storedSeq(t, conn, first.ID); seq != 5 {
+		t.Errorf("updated: got %d, want 5", seq)
+	}
+
+	if err := conn.Delete(&second).Error; err != nil {
+		t.Fatal(err)
+	}
+	var tombstones []Tombstone
+	if err := conn.Order("change_seq").Find(&tombstones).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(tombstones) != 1 || tombstones[0].ChangeSeq != 6 || tombstones[0].RecordID != second.ID ||
+		tombstones[0].ISBN != second.ISBN || tombstones[0].UserID != 1 || tombstones[0].DeletedAt.IsZero() {
+		t.Errorf("tombstones: got %+v, want one for %s at 6", tombstones, second.ISBN)
+	}
+
+	// A delete that matches nothing leaves no tombstone behind
+	if err := conn.Where("status = ?", "finished").Delete(&first).Error; err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := conn.Model(&Tombstone{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("tombstones after a delete of nothing: got %d, want 1", count)
+	}
+
+	// Numbers only grow, even when a write numbered ahead of time changes nothing
+	head, err := Head(ctx, conn, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head < 6 {
+		t.Errorf("head: got %d, want at least 6", head)
+	}
+	if last := addRecord(t, conn, "9786161851163"); last.ChangeSeq != head+1 {
+		t.Errorf("created after head %d: got %d", head, last.ChangeSeq)
+	}
+
+	// Every user counts on its own
+	if err := conn.Create(&model.User{Username: "kittipong"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	other := model.Record{UserID: 2, ISBN: "9786161851125", Title: "Khu Kam", TotalPages: 100}
+	if err := conn.Create(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	if other.ChangeSeq != 1 {
+		t.Errorf("other user: got %d, want 1", other.ChangeSeq)
+	}
+
+	if _, err := Head(ctx, conn, 99); err != ErrUnknownUser {
+		t.Errorf("unknown user: got %v, want ErrUnknownUser", err)
+	}
+}
+
+func TestSinceResumesAfterDelete(t *testing.T) {
+	conn := newTestDB(t)
+	ctx := context.Background()
+
+	kept := addRecord(t, conn, "9786161851125")
+	deleted := addRecord(t, conn, "9786161851132")
+	changed := addRecord(t, conn, "9786161851149")
+
+	feed, err := Since(ctx, conn, 1, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Records) != 3 || len(feed.Deleted) != 0 || feed.Seq != 3 || feed.More || feed.Reset {
+		t.Fatalf("full sync: got %v, %d deleted, seq %d", isbns(feed.Records), len(feed.Deleted), feed.Seq)
+	}
+	since, err := ParseToken(Token(feed.Seq))
+	if err != nil || since != 3 {
+		t.Fatalf("token: got %d, %v, want 3", since, err)
+	}
+
+	if err := conn.Delete(&deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Model(&changed).Update("status", "reading").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// One change per batch: the delete comes first, then the update
+	feed, err = Since(ctx, conn, 1, since, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Records) != 0 || len(feed.Deleted) != 1 || feed.Deleted[0].ISBN != deleted.ISBN || feed.Seq != 4 || !feed.More {
+		t.Fatalf("first batch: got %v, %+v, seq %d, more %v", isbns(feed.Records), feed.Deleted, feed.Seq, feed.More)
+	}
+	feed, err = Since(ctx, conn, 1, feed.Seq, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Deleted) != 0 || len(feed.Records) != 1 || feed.Records[0].ISBN != changed.ISBN || feed.Seq != 5 || feed.More {
+		t.Fatalf("second batch: got %v, %+v, seq %d, more %v", isbns(feed.Records), feed.Deleted, feed.Seq, feed.More)
+	}
+
+	// Resuming from the end returns nothing twice
+	feed, err = Since(ctx, conn, 1, feed.Seq, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Records) != 0 || len(feed.Deleted) != 0 || feed.Seq != 5 || feed.More {
+		t.Errorf("caught up: got %v, %+v, seq %d", isbns(feed.Records), feed.Deleted, feed.Seq)
+	}
+
+	// A full sync after the delete has no tombstones and no deleted record
+	feed, err = Since(ctx, conn, 1, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := isbns(feed.Records); len(got) != 2 || got[0] != kept.ISBN || got[1] != changed.ISBN || len(feed.Deleted) != 0 {
+		t.Errorf("full sync after delete: got %v, %+v", got, feed.Deleted)
+	}
+
+	// A token from ahead of the server starts over
+	feed, err = Since(ctx, conn, 1, 99, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !feed.Reset || len(feed.Records) != 2 || len(feed.Deleted) != 0 || feed.Seq != 5 {
+		t.Errorf("reset: got %v, %+v, seq %d, reset %v", isbns(feed.Records), feed.Deleted, feed.Seq, feed.Reset)
+	}
+}
@@ -0,0 +1,240 @@
+package changes
+
+import (
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// tombstonesKey passes the tombstones of a delete from before to after it runs
+const tombstonesKey = "changes:tombstones"
+
+// Track registers callbacks that give every record created, updated or deleted
+// through db the next change number of its user, and leave a tombstone for each
+// deleted record. Numbers are taken before the write and lock the user's row
+// until the transaction ends, so the writes of a user commit in the order of
+// their numbers. Statements that do not carry the records they change, such as
+// deletes by condition, are not numbered.
+func Track(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("changes:number", numberCreated); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("changes:number", numberUpdated); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("changes:number", numberDeleted); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register("changes:tombstone", writeTombstones)
+}
+
+// isRecordStatement reports whether a statement writes records
+func isRecordStatement(tx *gorm.DB) bool {
+	return tx.Error == nil && tx.Statement.Schema != nil && tx.Statement.Schema.Table == "records" &&
+		tx.Statement.ReflectValue.IsValid()
+}
+
+// statementValues returns the record structs a statement was run with
+func statementValues(tx *gorm.DB) []reflect.Value {
+	value := tx.Statement.ReflectValue
+	switch value.Kind() {
+	case reflect.Struct:
+		return []reflect.Value{value}
+	case reflect.Slice, reflect.Array:
+		values := make([]reflect.Value, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			values = append(values, reflect.Indirect(value.Index(i)))
+		}
+		return values
+	}
+	return nil
+}
+
+// statementIDs returns the IDs of the existing records a statement was run with
+func statementIDs(tx *gorm.DB) []uint {
+	var ids []uint
+	for _, value := range statementValues(tx) {
+		if id := value.FieldByName("ID"); id.IsValid() && id.Uint() != 0 {
+			ids = append(ids, uint(id.Uint()))
+		}
+	}
+	return ids
+}
+
+// reserve takes the next n change numbers of a user and returns the first, or
+// 0 when the user does not exist. The update holds the lock on the user's row
+// until the transaction ends.
+func reserve(conn *gorm.DB, userID uint, n int) (uint64, error) {
+	result := conn.Exec("UPDATE users SET change_seq = change_seq + ? WHERE id = ?", n, userID)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return 0, result.Error
+	}
+	var last uint64
+	if err := conn.Raw("SELECT change_seq FROM users WHERE id = ?", userID).Scan(&last).Error; err != nil {
+		return 0, err
+	}
+	return last - uint64(n) + 1, nil
+}
+
+// owner is the user and ISBN of a stored record
+type owner struct {
+	ID     uint
+	UserID uint
+	ISBN   string
+}
+
+// owners loads who owns the records of a statement. Updates may name a record
+// by its ID alone, so the struct cannot be trusted for it.
+func owners(conn *gorm.DB, ids []uint) ([]owner, error) {
+	var rows []owner
+	err := conn.Table("records").Select("id, user_id, isbn").
+		Where("id IN ? AND user_id IS NOT NULL", ids).Order("id").Scan(&rows).Error
+	return rows, err
+}
+
+func numberCreated(tx *gorm.DB) {
+	if !isRecordStatement(tx) {
+		return
+	}
+
+	byUser := map[uint][]reflect.Value{}
+	for _, value := range statementValues(tx) {
+		if userID := value.FieldByName("UserID").Uint(); userID != 0 {
+			byUser[uint(userID)] = append(byUser[uint(userID)], value)
+		}
+	}
+
+	conn := tx.Session(&gorm.Session{NewDB: true})
+	for userID, values := range byUser {
+		first, err := reserve(conn, userID, len(values))
+		if err != nil {
+			tx.AddError(err)
+			return
+		}
+		for i, value := range values {
+			value.FieldByName("ChangeSeq").SetUint(first + uint64(i))
+		}
+	}
+}
+
+func numberUpdated(tx *gorm.DB) {
+	if !isRecordStatement(tx) || tx.Statement.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+	ids := statementIDs(tx)
+	if len(ids) == 0 {
+		return
+	}
+
+	conn := tx.Session(&gorm.Session{NewDB: true})
+	rows, err := owners(conn, ids)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+	if len(rows) == 0 {
+		return
+	}
+	seq, err := reserve(conn, rows[0].UserID, 1)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+	if seq != 0 {
+		tx.Statement.SetColumn("change_seq", seq)
+	}
+}
+
+func numberDeleted(tx *gorm.DB) {
+	if !isRecordStatement(tx) {
+		return
+	}
+	ids := statementIDs(tx)
+	if len(ids) == 0 {
+		return
+	}
+
+	conn := tx.Session(&gorm.Session{NewDB: true})
+	rows, err := owners(conn, ids)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+	byUser := map[uint][]owner{}
+	for _, row := range rows {
+		byUser[row.UserID] = append(byUser[row.UserID], row)
+	}
+
+	var tombstones []Tombstone
+	now := time.Now()
+	for userID, rows := range byUser {
+		first, err := reserve(conn, userID, len(rows))
+		if err != nil {
+			tx.AddError(err)
+			return
+		}
+		for i, row := range rows {
+			tombstones = append(tombstones, Tombstone{
+				UserID:    userID,
+				ChangeSeq: first + uint64(i),
+				RecordID:  row.ID,
+				ISBN:      row.ISBN,
+				DeletedAt: now,
+			})
+		}
+	}
+	tx.InstanceSet(tombstonesKey, tombstones)
+}
+
+// writeTombstones stores the tombstones of the records a delete removed. A
+// delete with extra conditions may have kept some of them.
+func writeTombstones(tx *gorm.DB) {
+	if tx.Error != nil || tx.RowsAffected == 0 {
+		return
+	}
+	value, ok := tx.InstanceGet(tombstonesKey)
+	if !ok {
+		return
+	}
+	tombstones := value.([]Tombstone)
+	if len(tombstones) == 0 {
+		return
+	}
+
+	conn := tx.Session(&gorm.Session{NewDB: true})
+	if int(tx.RowsAffected) < len(tombstones) {
+		ids := make([]uint, len(tombstones))
+		for i, tombstone := range tombstones {
+			ids[i] = tombstone.RecordID
+		}
+		var kept []uint
+		if err := conn.Table("records").Where("id IN ?", ids).Pluck("id", &kept).Error; err != nil {
+			tx.AddError(err)
+			return
+		}
+		removed := tombstones[:0]
+		for _, tombstone := range tombstones {
+			if !containsID(kept, tombstone.RecordID) {
+				removed = append(removed, tombstone)
+			}
+		}
+		tombstones = removed
+	}
+	if len(tombstones) == 0 {
+		return
+	}
+	if err := conn.Create(&tombstones).Error; err != nil {
+		tx.AddError(err)
+	}
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
@@ -1,37 +1,64 @@
 package db
 
 import (
-	"biblia-be/internal/model"
 	"context"
-	"database/sql"
 	"fmt"
 	"log"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func NewDB(host, user, password, db_name, db_addr string, maxOpenConns, maxIdleConns, maxIdleTime int) (*gorm.DB, error) {
-	addr := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", user, password, host, db_addr, db_name)
-	log.Println("connecting to the database: " + addr)
-	sqlDB, err := sql.Open("mysql", addr)
+// Supported database drivers
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// sqliteOptions enforce foreign keys, wait on locks instead of failing and store
+// times in a format that sorts as text
+const sqliteOptions = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+
+// dialector returns the GORM dialector for a driver. For SQLite, db_name is the
+// path of the database file and the server settings are ignored.
+func dialector(driver, host, user, password, db_name, db_addr string) (gorm.Dialector, string, error) {
+	switch driver {
+	case DriverMySQL:
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", user, password, host, db_addr, db_name)
+		return mysql.Open(dsn), fmt.Sprintf("%s@%s:%s/%s", user, host, db_addr, db_name), nil
+	case DriverPostgres:
+		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s", host, db_addr, user, password, db_name)
+		return postgres.Open(dsn), fmt.Sprintf("%s@%s:%s/%s", user, host, db_addr, db_name), nil
+	case DriverSQLite:
+		return sqlite.Open("file:" + db_name + "?" + sqliteOptions), db_name, nil
+	default:
+		return nil, "", fmt.Errorf("unknown DB_DRIVER %q, expected mysql, postgres or sqlite", driver)
+	}
+}
 
+func NewDB(driver, host, user, password, db_name, db_addr string, maxOpenConns, maxIdleConns, maxIdleTime int) (*gorm.DB, error) {
+	dial, addr, err := dialector(driver, host, user, password, db_name, db_addr)
 	if err != nil {
-		log.Printf("error connecting db with %s", addr)
 		return nil, err
 	}
 
-	db, err := gorm.Open(mysql.New(mysql.Config{
-		Conn: sqlDB,
-	}), &gorm.Config{})
+	log.Printf("connecting to the %s database: %s", driver, addr)
+	db, err := gorm.Open(dial, &gorm.Config{})
+
+	if err != nil {
+		log.Printf("error connecting db with %s", addr)
+		return nil, err
+	}
 
+	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
 
-	db.AutoMigrate(&model.User{}, &model.Record{})
 	sqlDB.SetMaxIdleConns(maxIdleConns)
 	sqlDB.SetMaxOpenConns(maxOpenConns)
 	sqlDB.SetConnMaxIdleTime(time.Duration(maxIdleTime))
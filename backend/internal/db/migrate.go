@@ -0,0 +1,396 @@
+package db
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// ErrPendingMigrations is returned by Check when the database is behind the binary
+var ErrPendingMigrations = errors.New("database has pending migrations")
+
+// ErrDirtySchema is returned when a migration failed halfway. MySQL cannot roll
+// back DDL, so the schema has to be fixed by hand and marked clean with Force.
+var ErrDirtySchema = errors.New("database schema is dirty")
+
+// Migration is a numbered schema change with the SQL that applies and reverts it
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaMigration is a row of schema_migrations. On MySQL it is written before a
+// migration runs and marked clean once it completes; elsewhere it commits in the
+// same transaction as the migration and is never dirty.
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:varchar(255)"`
+	Dirty     bool      `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus reports whether a migration has been applied. Migrations the
+// database has but the binary does not know about are listed as Unknown.
+type MigrationStatus struct {
+	Version   uint
+	Name      string
+	Applied   bool
+	Dirty     bool
+	Unknown   bool
+	AppliedAt *time.Time
+}
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// addColumnIfNotExists matches the ADD COLUMN IF NOT EXISTS that SQLite lacks
+var addColumnIfNotExists = regexp.MustCompile(`(?i)^ALTER TABLE "(\w+)" ADD COLUMN IF NOT EXISTS "(\w+)"`)
+
+// LoadMigrations reads migrations named NNNN_name.up.sql and NNNN_name.down.sql
+// from fsys, in version order. Every migration needs both files.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_name.up.sql", entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d: up and down files have different names", version)
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitStatements splits a migration script into statements ending with a
+// semicolon at the end of a line, dropping comment lines
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// Migrator applies the embedded migrations and records them in schema_migrations
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator loads the migrations embedded in the binary for the database's
+// driver and creates the schema_migrations table if needed. Each driver has its
+// own directory of migrations with the same versions.
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	fsys, err := fs.Sub(migrationFiles, "migrations/"+db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("%s migrations: %w", db.Dialector.Name(), err)
+	}
+
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		if err := db.Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return nil, err
+		}
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func (m *Migrator) applied() ([]SchemaMigration, error) {
+	var applied []SchemaMigration
+	err := m.db.Order("version").Find(&applied).Error
+	return applied, err
+}
+
+// Status lists every known and applied migration in version order
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[uint]SchemaMigration, len(applied))
+	for _, row := range applied {
+		byVersion[row.Version] = row
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	known := make(map[uint]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := byVersion[migration.Version]; ok {
+			status.Applied = true
+			status.Dirty = row.Dirty
+			status.AppliedAt = &row.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		if !known[row.Version] {
+			row := row
+			statuses = append(statuses, MigrationStatus{
+				Version:   row.Version,
+				Name:      row.Name,
+				Applied:   true,
+				Dirty:     row.Dirty,
+				Unknown:   true,
+				AppliedAt: &row.AppliedAt,
+			})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Check returns ErrDirtySchema if a migration failed halfway and
+// ErrPendingMigrations if some have not been applied yet
+func (m *Migrator) Check() error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, status := range statuses {
+		if status.Dirty {
+			return fmt.Errorf("%w: migration %d_%s did not complete", ErrDirtySchema, status.Version, status.Name)
+		}
+		if !status.Applied {
+			pending = append(pending, fmt.Sprintf("%d_%s", status.Version, status.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %s", ErrPendingMigrations, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// Up applies every pending migration in version order and returns the ones it applied
+func (m *Migrator) Up() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]bool, len(statuses))
+	for _, status := range statuses {
+		if status.Dirty {
+			return nil, fmt.Errorf("%w: migration %d_%s did not complete", ErrDirtySchema, status.Version, status.Name)
+		}
+		applied[status.Version] = status.Applied
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if err := m.up(migration); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// up applies a migration and records it
+func (m *Migrator) up(migration Migration) error {
+	row := SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+	if m.transactional() {
+		return m.db.Transaction(func(tx *gorm.DB) error {
+			if err := run(tx, migration.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			return tx.Create(&row).Error
+		})
+	}
+
+	row.Dirty = true
+	if err := m.db.Create(&row).Error; err != nil {
+		return err
+	}
+	if err := m.runOnConnection(migration.Up); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return m.db.Model(&row).Update("dirty", false).Error
+}
+
+// Down reverts the last steps applied migrations and returns the ones it reverted
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[uint]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	var done []Migration
+	for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+		row := applied[i]
+		if row.Dirty {
+			return done, fmt.Errorf("%w: migration %d_%s did not complete", ErrDirtySchema, row.Version, row.Name)
+		}
+		migration, ok := byVersion[row.Version]
+		if !ok {
+			return done, fmt.Errorf("migration %d_%s is not known to this binary", row.Version, row.Name)
+		}
+		if err := m.down(migration, row); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// down reverts a migration and deletes its row
+func (m *Migrator) down(migration Migration, row SchemaMigration) error {
+	if m.transactional() {
+		return m.db.Transaction(func(tx *gorm.DB) error {
+			if err := run(tx, migration.Down); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			return tx.Delete(&row).Error
+		})
+	}
+
+	if err := m.db.Model(&row).Update("dirty", true).Error; err != nil {
+		return err
+	}
+	if err := m.runOnConnection(migration.Down); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return m.db.Delete(&row).Error
+}
+
+// Force marks the schema as migrated up to version, for use once a failed
+// migration has been repaired by hand. Known migrations up to version are
+// recorded as applied and later ones as not applied.
+func (m *Migrator) Force(version uint) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("version > ? OR dirty = ?", version, true).Delete(&SchemaMigration{}).Error; err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			row := SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+			if err := tx.Where(SchemaMigration{Version: migration.Version}).FirstOrCreate(&row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// transactional reports whether the driver can roll back DDL, so a migration
+// and its schema_migrations row commit or fail together
+func (m *Migrator) transactional() bool {
+	name := m.db.Dialector.Name()
+	return name == DriverPostgres || name == DriverSQLite
+}
+
+// runOnConnection executes a script on a single connection, so session
+// variables set by one statement are seen by the next
+func (m *Migrator) runOnConnection(script string) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		return run(conn, script)
+	})
+}
+
+// run executes the statements of a script one after another on conn, which must
+// be a single connection or a transaction
+func run(conn *gorm.DB, script string) error {
+	for _, statement := range splitStatements(script) {
+		statement, skip := emulate(conn, statement)
+		if skip {
+			continue
+		}
+		if err := conn.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emulate rewrites statements the driver does not support. On SQLite, ADD
+// COLUMN IF NOT EXISTS is skipped when the column exists and run without the
+// condition otherwise.
+func emulate(conn *gorm.DB, statement string) (string, bool) {
+	if conn.Dialector.Name() != DriverSQLite {
+		return statement, false
+	}
+	match := addColumnIfNotExists.FindStringSubmatch(statement)
+	if match == nil {
+		return statement, false
+	}
+	if conn.Migrator().HasColumn(match[1], match[2]) {
+		return "", true
+	}
+	return strings.Replace(statement, match[0], fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s"`, match[1], match[2]), 1), false
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB opens an empty SQLite database in a temporary directory
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := NewDB(DriverSQLite, "", "", "", filepath.Join(t.TempDir(), "migrate.db"), "", 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return conn
+}
+
+func TestEmbeddedMigrationsUpAndDown(t *testing.T) {
+	conn := newTestDB(t)
+	migrator, err := NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != len(migrator.migrations) {
+		t.Fatalf("Up: applied %d of %d, %v", len(applied), len(migrator.migrations), err)
+	}
+	if err := migrator.Check(); err != nil {
+		t.Fatalf("Check after Up: %v", err)
+	}
+
+	reverted, err := migrator.Down(len(applied))
+	if err != nil || len(reverted) != len(applied) {
+		t.Fatalf("Down: reverted %d of %d, %v", len(reverted), len(applied), err)
+	}
+	if conn.Migrator().HasTable("records") || conn.Migrator().HasTable("users") {
+		t.Error("tables left after reverting every migration")
+	}
+	if err := migrator.Check(); !errors.Is(err, ErrPendingMigrations) {
+		t.Errorf("Check after Down: got %v, want pending migrations", err)
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	conn := newTestDB(t)
+	migrator, err := NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator.migrations = []Migration{
+		{Version: 1, Name: "shelves", Up: `CREATE TABLE "shelves" ("id" integer);`, Down: `DROP TABLE "shelves";`},
+		{
+			Version: 2,
+			Name:    "labels",
+			Up:      "CREATE TABLE \"labels\" (\"id\" integer);\nINSERT INTO \"missing\" VALUES (1);",
+			Down:    `DROP TABLE "labels";`,
+		},
+	}
+
+	// The table created before the failing statement goes with the migration,
+	// and the schema is left clean rather than dirty
+	applied, err := migrator.Up()
+	if err == nil || len(applied) != 1 {
+		t.Fatalf("Up: applied %d, %v; want the first migration and an error", len(applied), err)
+	}
+	if conn.Migrator().HasTable("labels") {
+		t.Error("labels table left by the failed migration")
+	}
+	if err := migrator.Check(); !errors.Is(err, ErrPendingMigrations) {
+		t.Errorf("Check after failed Up: got %v, want pending migrations", err)
+	}
+
+	migrator.migrations[1].Up = `CREATE TABLE "labels" ("id" integer);`
+	migrator.migrations[1].Down = "DROP TABLE \"labels\";\nDROP TABLE \"missing\";"
+	if applied, err := migrator.Up(); err != nil || len(applied) != 1 {
+		t.Fatalf("Up after the fix: applied %d, %v", len(applied), err)
+	}
+
+	// A failed revert keeps both the table and the record of the migration
+	if reverted, err := migrator.Down(1); err == nil || len(reverted) != 0 {
+		t.Fatalf("Down: reverted %d, %v; want an error", len(reverted), err)
+	}
+	if !conn.Migrator().HasTable("labels") {
+		t.Error("labels table dropped by the failed revert")
+	}
+	if err := migrator.Check(); err != nil {
+		t.Errorf("Check after failed Down: %v", err)
+	}
+}
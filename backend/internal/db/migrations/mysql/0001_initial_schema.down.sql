@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS `search_documents`;
+DROP TABLE IF EXISTS `series_entries`;
+DROP TABLE IF EXISTS `series`;
+DROP TABLE IF EXISTS `queue_items`;
+DROP TABLE IF EXISTS `loans`;
+DROP TABLE IF EXISTS `club_posts`;
+DROP TABLE IF EXISTS `club_milestones`;
+DROP TABLE IF EXISTS `club_memberships`;
+DROP TABLE IF EXISTS `clubs`;
+DROP TABLE IF EXISTS `follows`;
+DROP TABLE IF EXISTS `events`;
+DROP TABLE IF EXISTS `sync_progresses`;
+DROP TABLE IF EXISTS `api_keys`;
+DROP TABLE IF EXISTS `jobs`;
+DROP TABLE IF EXISTS `records`;
+DROP TABLE IF EXISTS `users`;
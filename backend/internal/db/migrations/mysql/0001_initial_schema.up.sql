@@ -0,0 +1,255 @@
+-- Schema as created by AutoMigrate before versioned migrations. Tables are
+-- created only when missing so existing databases can adopt this baseline;
+-- 0007 adds the columns that older users and records tables lack.
+
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `username` longtext,
+  `password` longtext,
+  `sync_key` longtext,
+  `favorite_genres` longtext,
+  `privacy` varchar(16) DEFAULT 'public',
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `records` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned,
+  `isbn` varchar(20),
+  `title` longtext,
+  `author` longtext,
+  `cover` longtext,
+  `genre` longtext,
+  `status` longtext,
+  `current_page` int,
+  `total_pages` int,
+  `date_added` datetime(3) NULL,
+  `date_finished` datetime(3) NULL,
+  `ebook_key` longtext,
+  `document_id` varchar(32),
+  `shelves` longtext,
+  `notes` text,
+  `rating` tinyint,
+  `ownership` varchar(16) DEFAULT 'owned',
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_user_isbn` (`user_id`, `isbn`),
+  INDEX `idx_records_document_id` (`document_id`),
+  CONSTRAINT `fk_users_records` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `jobs` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `type` varchar(64),
+  `user_id` bigint unsigned,
+  `status` varchar(16),
+  `progress` double,
+  `payload` longtext,
+  `result` longtext,
+  `attempts` bigint,
+  `max_attempts` bigint,
+  `last_error` text,
+  `cancel_requested` boolean,
+  `run_at` datetime(3) NULL,
+  `started_at` datetime(3) NULL,
+  `finished_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_jobs_type` (`type`),
+  INDEX `idx_jobs_user_id` (`user_id`),
+  INDEX `idx_job_status_run` (`status`, `run_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `api_keys` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned,
+  `name` longtext,
+  `prefix` varchar(16),
+  `key_hash` char(64),
+  `created_at` datetime(3) NULL,
+  `last_used_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_api_keys_user_id` (`user_id`),
+  UNIQUE INDEX `idx_api_keys_key_hash` (`key_hash`),
+  CONSTRAINT `fk_api_keys_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `sync_progresses` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned,
+  `document` varchar(64),
+  `record_id` bigint unsigned,
+  `progress` text,
+  `percentage` double,
+  `device` longtext,
+  `device_id` longtext,
+  `timestamp` bigint,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_user_document` (`user_id`, `document`),
+  CONSTRAINT `fk_sync_progresses_record` FOREIGN KEY (`record_id`) REFERENCES `records` (`id`) ON DELETE SET NULL ON UPDATE CASCADE,
+  CONSTRAINT `fk_sync_progresses_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `events` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned,
+  `record_id` bigint unsigned,
+  `type` varchar(16),
+  `isbn` varchar(20),
+  `title` longtext,
+  `cover` longtext,
+  `rating` tinyint,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_event_user_created` (`user_id`, `created_at`),
+  CONSTRAINT `fk_events_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_events_record` FOREIGN KEY (`record_id`) REFERENCES `records` (`id`) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `follows` (
+  `follower_id` bigint unsigned,
+  `followee_id` bigint unsigned,
+  `accepted` boolean,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`follower_id`, `followee_id`),
+  INDEX `idx_follows_followee_id` (`followee_id`),
+  CONSTRAINT `fk_follows_follower` FOREIGN KEY (`follower_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_follows_followee` FOREIGN KEY (`followee_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `clubs` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` longtext,
+  `description` text,
+  `isbn` varchar(20),
+  `title` longtext,
+  `total_pages` int,
+  `start_date` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `club_memberships` (
+  `club_id` bigint unsigned,
+  `user_id` bigint unsigned,
+  `role` varchar(16),
+  `joined_at` datetime(3) NULL,
+  PRIMARY KEY (`club_id`, `user_id`),
+  INDEX `idx_club_memberships_user_id` (`user_id`),
+  CONSTRAINT `fk_club_memberships_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_clubs_members` FOREIGN KEY (`club_id`) REFERENCES `clubs` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `club_milestones` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `club_id` bigint unsigned,
+  `label` longtext,
+  `page` int,
+  `due_date` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_club_milestones_club_id` (`club_id`),
+  CONSTRAINT `fk_clubs_schedule` FOREIGN KEY (`club_id`) REFERENCES `clubs` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `club_posts` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `club_id` bigint unsigned,
+  `parent_id` bigint unsigned,
+  `user_id` bigint unsigned,
+  `isbn` varchar(20),
+  `title` longtext,
+  `body` text,
+  `page` int,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_club_posts_club_id` (`club_id`),
+  INDEX `idx_club_posts_parent_id` (`parent_id`),
+  CONSTRAINT `fk_club_posts_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_club_posts_club` FOREIGN KEY (`club_id`) REFERENCES `clubs` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_club_posts_parent` FOREIGN KEY (`parent_id`) REFERENCES `club_posts` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `loans` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `record_id` bigint unsigned,
+  `lender_id` bigint unsigned,
+  `lender_name` longtext,
+  `borrower_id` bigint unsigned,
+  `borrower_name` longtext,
+  `borrower_record_id` bigint unsigned,
+  `isbn` varchar(20),
+  `title` longtext,
+  `lent_at` datetime(3) NULL,
+  `due_at` datetime(3) NULL,
+  `returned_at` datetime(3) NULL,
+  `notes` longtext,
+  PRIMARY KEY (`id`),
+  INDEX `idx_loans_borrower_record_id` (`borrower_record_id`),
+  INDEX `idx_loans_record_id` (`record_id`),
+  INDEX `idx_loans_lender_id` (`lender_id`),
+  INDEX `idx_loans_borrower_id` (`borrower_id`),
+  CONSTRAINT `fk_loans_record` FOREIGN KEY (`record_id`) REFERENCES `records` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_loans_borrower_record` FOREIGN KEY (`borrower_record_id`) REFERENCES `records` (`id`) ON DELETE SET NULL ON UPDATE CASCADE,
+  CONSTRAINT `fk_loans_lender` FOREIGN KEY (`lender_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_loans_borrower` FOREIGN KEY (`borrower_id`) REFERENCES `users` (`id`) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `queue_items` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned,
+  `isbn` varchar(20),
+  `title` longtext,
+  `author` longtext,
+  `cover` longtext,
+  `genre` longtext,
+  `position` varchar(80),
+  `priority` tinyint DEFAULT 2,
+  `reason` text,
+  `added_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_queue_user_isbn` (`user_id`, `isbn`),
+  INDEX `idx_queue_user_position` (`user_id`, `position`),
+  CONSTRAINT `fk_queue_items_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `series` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `name` varchar(191),
+  `author` longtext,
+  `description` text,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_series_name` (`name`)
+);
+
+CREATE TABLE IF NOT EXISTS `series_entries` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `series_id` bigint unsigned,
+  `isbn` varchar(20),
+  `title` longtext,
+  `position` double,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_series_isbn` (`series_id`, `isbn`),
+  INDEX `idx_series_entries_isbn` (`isbn`),
+  CONSTRAINT `fk_series_entries` FOREIGN KEY (`series_id`) REFERENCES `series` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+-- The default stopword list would drop every character pair containing a
+-- stopword such as "a", so the ngram indexes are built without one
+SET SESSION innodb_ft_enable_stopword = OFF;
+
+CREATE TABLE IF NOT EXISTS `search_documents` (
+  `record_id` bigint unsigned,
+  `user_id` bigint unsigned,
+  `isbn` varchar(20),
+  `title` text,
+  `meta` text,
+  `notes` text,
+  PRIMARY KEY (`record_id`),
+  INDEX `idx_search_documents_user_id` (`user_id`),
+  INDEX `idx_search_documents_isbn` (`isbn`),
+  FULLTEXT INDEX `ft_title` (`title`) WITH PARSER ngram,
+  FULLTEXT INDEX `ft_shared` (`title`, `meta`) WITH PARSER ngram,
+  FULLTEXT INDEX `ft_all` (`title`, `meta`, `notes`) WITH PARSER ngram
+);
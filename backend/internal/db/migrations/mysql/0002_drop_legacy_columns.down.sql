@@ -0,0 +1,2 @@
+-- The dropped columns were unused by the backend and their data cannot be
+-- restored, so there is nothing to revert.
@@ -0,0 +1,25 @@
+-- Databases first created by the root module kept its columns after
+-- AutoMigrate added the backend ones. MySQL has no DROP COLUMN IF EXISTS, so
+-- each table's ALTER is built from the columns that are actually there.
+
+SET @drop_users = (
+  SELECT CONCAT('ALTER TABLE `users` ', GROUP_CONCAT(CONCAT('DROP COLUMN `', column_name, '`') SEPARATOR ', '))
+  FROM information_schema.columns
+  WHERE table_schema = DATABASE() AND table_name = 'users'
+    AND column_name IN ('email', 'created_at')
+);
+SET @drop_users = IFNULL(@drop_users, 'DO 0');
+PREPARE drop_users FROM @drop_users;
+EXECUTE drop_users;
+DEALLOCATE PREPARE drop_users;
+
+SET @drop_records = (
+  SELECT CONCAT('ALTER TABLE `records` ', GROUP_CONCAT(CONCAT('DROP COLUMN `', column_name, '`') SEPARATOR ', '))
+  FROM information_schema.columns
+  WHERE table_schema = DATABASE() AND table_name = 'records'
+    AND column_name IN ('curr_progress', 'curr_chapter', 'created_at', 'started_date', 'update_date', 'stop_date', 'finish_date')
+);
+SET @drop_records = IFNULL(@drop_records, 'DO 0');
+PREPARE drop_records FROM @drop_records;
+EXECUTE drop_records;
+DEALLOCATE PREPARE drop_records;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `record_tombstones`;
+DROP INDEX `idx_records_user_change_seq` ON `records`;
+ALTER TABLE `records` DROP COLUMN `change_seq`;
+ALTER TABLE `users` DROP COLUMN `change_seq`;
@@ -0,0 +1,26 @@
+-- Every write to a record takes the next change sequence number of its user,
+-- so clients can sync what changed since the number they last saw. Deleted
+-- records leave a tombstone with the number of their deletion.
+ALTER TABLE `users` ADD COLUMN `change_seq` bigint unsigned NOT NULL DEFAULT 0;
+ALTER TABLE `records` ADD COLUMN `change_seq` bigint unsigned NOT NULL DEFAULT 0;
+
+-- Number the existing records of each user in the order they were added
+UPDATE `records` JOIN (
+  SELECT r.`id`, COUNT(*) AS `seq`
+  FROM `records` r JOIN `records` p ON p.`user_id` = r.`user_id` AND p.`id` <= r.`id`
+  GROUP BY r.`id`
+) AS `numbered` ON `numbered`.`id` = `records`.`id`
+SET `records`.`change_seq` = `numbered`.`seq`;
+UPDATE `users` SET `change_seq` = (SELECT COUNT(*) FROM `records` WHERE `records`.`user_id` = `users`.`id`);
+
+CREATE INDEX `idx_records_user_change_seq` ON `records` (`user_id`, `change_seq`);
+
+CREATE TABLE `record_tombstones` (
+  `user_id` bigint unsigned NOT NULL,
+  `change_seq` bigint unsigned NOT NULL,
+  `record_id` bigint unsigned NOT NULL,
+  `isbn` varchar(20) NOT NULL,
+  `deleted_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`user_id`, `change_seq`),
+  CONSTRAINT `fk_record_tombstones_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
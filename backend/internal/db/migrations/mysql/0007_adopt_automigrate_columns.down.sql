@@ -0,0 +1,2 @@
+-- The columns belong to the baseline schema and are dropped with it by 0001,
+-- so there is nothing to revert.
@@ -0,0 +1,51 @@
+-- Databases created by AutoMigrate before versioned migrations kept their
+-- users and records tables when 0001 adopted them, without the columns added
+-- to the models since. Each ALTER adds the columns and indexes that are
+-- missing, as MySQL has no ADD COLUMN IF NOT EXISTS.
+
+SET @add_users = CONCAT_WS(', ',
+  IF((SELECT COUNT(*) FROM information_schema.columns
+      WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'sync_key') = 0,
+    'ADD COLUMN `sync_key` longtext', NULL),
+  IF((SELECT COUNT(*) FROM information_schema.columns
+      WHERE table_schema = DATABASE() AND table_name = 'users' AND column_name = 'privacy') = 0,
+    'ADD COLUMN `privacy` varchar(16) DEFAULT ''public''', NULL)
+);
+SET @add_users = IF(@add_users = '', 'DO 0', CONCAT('ALTER TABLE `users` ', @add_users));
+PREPARE add_users FROM @add_users;
+EXECUTE add_users;
+DEALLOCATE PREPARE add_users;
+
+SET @add_records = CONCAT_WS(', ',
+  IF((SELECT COUNT(*) FROM information_schema.columns
+      WHERE table_schema = DATABASE() AND table_name = 'records' AND column_name = 'date_finished') = 0,
+    'ADD COLUMN `date_finished` datetime(3) NULL', NULL),
+  IF((SELECT COUNT(*) FROM information_schema.columns
+      WHERE table_schema = DATABASE() AND table_name = 'records' AND column_name = 'ebook_key') = 0,
+    'ADD COLUMN `ebook_key` longtext', NULL),
+  IF((SELECT COUNT(*) FROM information_schema.columns
+      WHERE table_schema = DATABASE() AND table_name = 'records' AND column_name = 'document_id') = 0,
+    'ADD COLUMN `document_id` varchar(32)', NULL),
+  IF((SELECT COUNT(*) FROM information_schema.columns
+      WHERE table_schema = DATABASE() AND table_name = 'records' AND column_name = 'shelves') = 0,
+    'ADD COLUMN `shelves` longtext', NULL),
+  IF((SELECT COUNT(*) FROM information_schema.columns
+      WHERE table_schema = DATABASE() AND table_name = 'records' AND column_name = 'notes') = 0,
+    'ADD COLUMN `notes` text', NULL),
+  IF((SELECT COUNT(*) FROM information_schema.columns
+      WHERE table_schema = DATABASE() AND table_name = 'records' AND column_name = 'rating') = 0,
+    'ADD COLUMN `rating` tinyint', NULL),
+  IF((SELECT COUNT(*) FROM information_schema.columns
+      WHERE table_schema = DATABASE() AND table_name = 'records' AND column_name = 'ownership') = 0,
+    'ADD COLUMN `ownership` varchar(16) DEFAULT ''owned''', NULL),
+  IF((SELECT COUNT(*) FROM information_schema.statistics
+      WHERE table_schema = DATABASE() AND table_name = 'records' AND index_name = 'idx_user_isbn') = 0,
+    'ADD UNIQUE INDEX `idx_user_isbn` (`user_id`, `isbn`)', NULL),
+  IF((SELECT COUNT(*) FROM information_schema.statistics
+      WHERE table_schema = DATABASE() AND table_name = 'records' AND index_name = 'idx_records_document_id') = 0,
+    'ADD INDEX `idx_records_document_id` (`document_id`)', NULL)
+);
+SET @add_records = IF(@add_records = '', 'DO 0', CONCAT('ALTER TABLE `records` ', @add_records));
+PREPARE add_records FROM @add_records;
+EXECUTE add_records;
+DEALLOCATE PREPARE add_records;
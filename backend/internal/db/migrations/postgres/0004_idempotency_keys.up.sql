@@ -0,0 +1,13 @@
+-- Responses of requests sent with an Idempotency-Key header, replayed when a
+-- client retries the request. id is a hash of the key and the endpoint.
+CREATE TABLE "idempotency_keys" (
+  "id" char(64) NOT NULL,
+  "request_hash" char(64) NOT NULL,
+  "status" integer NOT NULL DEFAULT 0,
+  "content_type" varchar(255) NOT NULL DEFAULT '',
+  "body" bytea,
+  "created_at" timestamptz NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_idempotency_keys_expires_at" ON "idempotency_keys" ("expires_at");
@@ -0,0 +1,3 @@
+-- Version of each record, bumped on every write, so clients can update and
+-- delete with If-Match and detect changes made on other devices
+ALTER TABLE "records" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "record_tombstones";
+DROP INDEX IF EXISTS "idx_records_user_change_seq";
+ALTER TABLE "records" DROP COLUMN "change_seq";
+ALTER TABLE "users" DROP COLUMN "change_seq";
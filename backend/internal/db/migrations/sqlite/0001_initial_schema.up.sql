@@ -0,0 +1,236 @@
+-- Initial schema, the same tables as the MySQL baseline. Search uses the
+-- memory index on SQLite, so there is no search_documents table. Databases
+-- on this driver always start from these migrations: only MySQL ones were
+-- created by AutoMigrate, so there are no existing tables to adopt.
+
+CREATE TABLE IF NOT EXISTS "users" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "username" text,
+  "password" text,
+  "sync_key" text,
+  "favorite_genres" text,
+  "privacy" varchar(16) DEFAULT 'public'
+);
+
+CREATE TABLE IF NOT EXISTS "records" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "user_id" integer,
+  "isbn" varchar(20),
+  "title" text,
+  "author" text,
+  "cover" text,
+  "genre" text,
+  "status" text,
+  "current_page" integer,
+  "total_pages" integer,
+  "date_added" datetime,
+  "date_finished" datetime,
+  "ebook_key" text,
+  "document_id" varchar(32),
+  "shelves" text,
+  "notes" text,
+  "rating" integer,
+  "ownership" varchar(16) DEFAULT 'owned',
+  CONSTRAINT "fk_users_records" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE SET NULL ON UPDATE CASCADE
+);
+-- Tables kept from a database created by AutoMigrate lack the columns added
+-- to the models since, and the indexes below need them. SQLite has no ADD
+-- COLUMN IF NOT EXISTS; the migrator skips the columns that are there.
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "sync_key" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "privacy" varchar(16) DEFAULT 'public';
+ALTER TABLE "records" ADD COLUMN IF NOT EXISTS "date_finished" datetime;
+ALTER TABLE "records" ADD COLUMN IF NOT EXISTS "ebook_key" text;
+ALTER TABLE "records" ADD COLUMN IF NOT EXISTS "document_id" varchar(32);
+ALTER TABLE "records" ADD COLUMN IF NOT EXISTS "shelves" text;
+ALTER TABLE "records" ADD COLUMN IF NOT EXISTS "notes" text;
+ALTER TABLE "records" ADD COLUMN IF NOT EXISTS "rating" integer;
+ALTER TABLE "records" ADD COLUMN IF NOT EXISTS "ownership" varchar(16) DEFAULT 'owned';
+CREATE INDEX IF NOT EXISTS "idx_records_document_id" ON "records" ("document_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_isbn" ON "records" ("user_id", "isbn");
+
+CREATE TABLE IF NOT EXISTS "jobs" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "type" varchar(64),
+  "user_id" integer,
+  "status" varchar(16),
+  "progress" real,
+  "payload" text,
+  "result" text,
+  "attempts" integer,
+  "max_attempts" integer,
+  "last_error" text,
+  "cancel_requested" numeric,
+  "run_at" datetime,
+  "started_at" datetime,
+  "finished_at" datetime,
+  "created_at" datetime,
+  "updated_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_jobs_type" ON "jobs" ("type");
+CREATE INDEX IF NOT EXISTS "idx_job_status_run" ON "jobs" ("status", "run_at");
+CREATE INDEX IF NOT EXISTS "idx_jobs_user_id" ON "jobs" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "user_id" integer,
+  "name" text,
+  "prefix" varchar(16),
+  "key_hash" char(64),
+  "created_at" datetime,
+  "last_used_at" datetime,
+  CONSTRAINT "fk_api_keys_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key_hash" ON "api_keys" ("key_hash");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "sync_progresses" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "user_id" integer,
+  "document" varchar(64),
+  "record_id" integer,
+  "progress" text,
+  "percentage" real,
+  "device" text,
+  "device_id" text,
+  "timestamp" integer,
+  "updated_at" datetime,
+  CONSTRAINT "fk_sync_progresses_record" FOREIGN KEY ("record_id") REFERENCES "records" ("id") ON DELETE SET NULL ON UPDATE CASCADE,
+  CONSTRAINT "fk_sync_progresses_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_document" ON "sync_progresses" ("user_id", "document");
+
+CREATE TABLE IF NOT EXISTS "events" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "user_id" integer,
+  "record_id" integer,
+  "type" varchar(16),
+  "isbn" varchar(20),
+  "title" text,
+  "cover" text,
+  "rating" integer,
+  "created_at" datetime,
+  CONSTRAINT "fk_events_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT "fk_events_record" FOREIGN KEY ("record_id") REFERENCES "records" ("id") ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_event_user_created" ON "events" ("user_id", "created_at");
+
+CREATE TABLE IF NOT EXISTS "follows" (
+  "follower_id" integer,
+  "followee_id" integer,
+  "accepted" numeric,
+  "created_at" datetime,
+  PRIMARY KEY ("follower_id", "followee_id"),
+  CONSTRAINT "fk_follows_follower" FOREIGN KEY ("follower_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT "fk_follows_followee" FOREIGN KEY ("followee_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_follows_followee_id" ON "follows" ("followee_id");
+
+CREATE TABLE IF NOT EXISTS "clubs" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "name" text,
+  "description" text,
+  "isbn" varchar(20),
+  "title" text,
+  "total_pages" integer,
+  "start_date" datetime,
+  "created_at" datetime
+);
+
+CREATE TABLE IF NOT EXISTS "club_memberships" (
+  "club_id" integer,
+  "user_id" integer,
+  "role" varchar(16),
+  "joined_at" datetime,
+  PRIMARY KEY ("club_id", "user_id"),
+  CONSTRAINT "fk_club_memberships_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT "fk_clubs_members" FOREIGN KEY ("club_id") REFERENCES "clubs" ("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_club_memberships_user_id" ON "club_memberships" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "club_milestones" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "club_id" integer,
+  "label" text,
+  "page" integer,
+  "due_date" datetime,
+  CONSTRAINT "fk_clubs_schedule" FOREIGN KEY ("club_id") REFERENCES "clubs" ("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_club_milestones_club_id" ON "club_milestones" ("club_id");
+
+CREATE TABLE IF NOT EXISTS "club_posts" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "club_id" integer,
+  "parent_id" integer,
+  "user_id" integer,
+  "isbn" varchar(20),
+  "title" text,
+  "body" text,
+  "page" integer,
+  "created_at" datetime,
+  CONSTRAINT "fk_club_posts_club" FOREIGN KEY ("club_id") REFERENCES "clubs" ("id") ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT "fk_club_posts_parent" FOREIGN KEY ("parent_id") REFERENCES "club_posts" ("id") ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT "fk_club_posts_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_club_posts_parent_id" ON "club_posts" ("parent_id");
+CREATE INDEX IF NOT EXISTS "idx_club_posts_club_id" ON "club_posts" ("club_id");
+
+CREATE TABLE IF NOT EXISTS "loans" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "record_id" integer,
+  "lender_id" integer,
+  "lender_name" text,
+  "borrower_id" integer,
+  "borrower_name" text,
+  "borrower_record_id" integer,
+  "isbn" varchar(20),
+  "title" text,
+  "lent_at" datetime,
+  "due_at" datetime,
+  "returned_at" datetime,
+  "notes" text,
+  CONSTRAINT "fk_loans_record" FOREIGN KEY ("record_id") REFERENCES "records" ("id") ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT "fk_loans_borrower_record" FOREIGN KEY ("borrower_record_id") REFERENCES "records" ("id") ON DELETE SET NULL ON UPDATE CASCADE,
+  CONSTRAINT "fk_loans_lender" FOREIGN KEY ("lender_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT "fk_loans_borrower" FOREIGN KEY ("borrower_id") REFERENCES "users" ("id") ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_loans_borrower_record_id" ON "loans" ("borrower_record_id");
+CREATE INDEX IF NOT EXISTS "idx_loans_borrower_id" ON "loans" ("borrower_id");
+CREATE INDEX IF NOT EXISTS "idx_loans_lender_id" ON "loans" ("lender_id");
+CREATE INDEX IF NOT EXISTS "idx_loans_record_id" ON "loans" ("record_id");
+
+CREATE TABLE IF NOT EXISTS "queue_items" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "user_id" integer,
+  "isbn" varchar(20),
+  "title" text,
+  "author" text,
+  "cover" text,
+  "genre" text,
+  "position" varchar(80),
+  "priority" integer DEFAULT 2,
+  "reason" text,
+  "added_at" datetime,
+  CONSTRAINT "fk_queue_items_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_queue_user_position" ON "queue_items" ("user_id", "position");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_queue_user_isbn" ON "queue_items" ("user_id", "isbn");
+
+CREATE TABLE IF NOT EXISTS "series" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "name" text,
+  "author" text,
+  "description" text,
+  "created_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_series_name" ON "series" ("name");
+
+CREATE TABLE IF NOT EXISTS "series_entries" (
+  "id" integer PRIMARY KEY AUTOINCREMENT,
+  "series_id" integer,
+  "isbn" varchar(20),
+  "title" text,
+  "position" real,
+  CONSTRAINT "fk_series_entries" FOREIGN KEY ("series_id") REFERENCES "series" ("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_series_entries_isbn" ON "series_entries" ("isbn");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_series_isbn" ON "series_entries" ("series_id", "isbn");
@@ -0,0 +1,2 @@
+-- Only MySQL databases can predate the backend schema, so there are no legacy
+-- columns to drop. The migration exists to keep versions in step.
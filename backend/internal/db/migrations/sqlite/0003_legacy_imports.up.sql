@@ -0,0 +1,9 @@
+-- Rows imported from a root-module database, so an interrupted import can be
+-- run again without creating duplicates
+CREATE TABLE "legacy_imports" (
+  "entity" varchar(16) NOT NULL,
+  "legacy_id" varchar(64) NOT NULL,
+  "new_id" integer NOT NULL,
+  "imported_at" datetime NOT NULL,
+  PRIMARY KEY ("entity", "legacy_id")
+);
@@ -0,0 +1,24 @@
+-- Every write to a record takes the next change sequence number of its user,
+-- so clients can sync what changed since the number they last saw. Deleted
+-- records leave a tombstone with the number of their deletion.
+ALTER TABLE "users" ADD COLUMN "change_seq" integer NOT NULL DEFAULT 0;
+ALTER TABLE "records" ADD COLUMN "change_seq" integer NOT NULL DEFAULT 0;
+
+-- Number the existing records of each user in the order they were added
+UPDATE "records" SET "change_seq" = (
+  SELECT COUNT(*) FROM "records" AS "p"
+  WHERE "p"."user_id" = "records"."user_id" AND "p"."id" <= "records"."id"
+);
+UPDATE "users" SET "change_seq" = (SELECT COUNT(*) FROM "records" WHERE "records"."user_id" = "users"."id");
+
+CREATE INDEX "idx_records_user_change_seq" ON "records" ("user_id", "change_seq");
+
+CREATE TABLE "record_tombstones" (
+  "user_id" integer NOT NULL,
+  "change_seq" integer NOT NULL,
+  "record_id" integer NOT NULL,
+  "isbn" varchar(20) NOT NULL,
+  "deleted_at" datetime NOT NULL,
+  PRIMARY KEY ("user_id", "change_seq"),
+  CONSTRAINT "fk_record_tombstones_user" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
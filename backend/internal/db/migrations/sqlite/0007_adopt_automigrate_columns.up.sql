@@ -0,0 +1,2 @@
+-- Only MySQL databases were created by AutoMigrate, so there are no adopted
+-- tables lacking columns. The migration exists to keep versions in step.
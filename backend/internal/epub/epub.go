@@ -0,0 +1,263 @@
+// Package epub extracts publication metadata from EPUB 2 and EPUB 3 files.
+package epub
+
+import (
+	"archive/zip"
+	"biblia-be/internal/isbn"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrInvalid is returned for files that are not a readable EPUB container
+var ErrInvalid = errors.New("invalid EPUB file")
+
+// maxEntrySize caps how much of a single archive entry is read into memory
+const maxEntrySize = 20 << 20
+
+// Identifier is a dc:identifier with its declared scheme, if any
+type Identifier struct {
+	Scheme string `json:"scheme,omitempty"`
+	Value  string `json:"value"`
+}
+
+// Metadata is the publication metadata declared in the OPF package document
+type Metadata struct {
+	Title       string       `json:"title"`
+	Creators    []string     `json:"creators"`
+	Identifiers []Identifier `json:"identifiers"`
+	// ISBN is the first identifier that is a valid ISBN, normalized to ISBN-13
+	ISBN     string   `json:"isbn,omitempty"`
+	Language string   `json:"language,omitempty"`
+	Subjects []string `json:"subjects"`
+
+	CoverPath      string `json:"coverPath,omitempty"`
+	CoverMediaType string `json:"coverMediaType,omitempty"`
+	Cover          []byte `json:"-"`
+}
+
+type container struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Titles      []string `xml:"title"`
+		Creators    []string `xml:"creator"`
+		Languages   []string `xml:"language"`
+		Subjects    []string `xml:"subject"`
+		Identifiers []struct {
+			ID     string `xml:"id,attr"`
+			Scheme string `xml:"scheme,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"identifier"`
+		Metas []struct {
+			Name     string `xml:"name,attr"`
+			Content  string `xml:"content,attr"`
+			Property string `xml:"property,attr"`
+			Refines  string `xml:"refines,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+}
+
+// Parse reads the container and package document of an EPUB and returns its
+// metadata, including the cover image bytes when one is declared
+func Parse(r io.ReaderAt, size int64) (*Metadata, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	containerXML, err := readEntry(files, "META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	var c container
+	if err := xml.Unmarshal(containerXML, &c); err != nil {
+		return nil, fmt.Errorf("%w: container.xml: %v", ErrInvalid, err)
+	}
+
+	opfPath := ""
+	for _, rootfile := range c.Rootfiles {
+		if rootfile.MediaType == "" || rootfile.MediaType == "application/oebps-package+xml" {
+			opfPath = rootfile.FullPath
+			break
+		}
+	}
+	if opfPath == "" {
+		return nil, fmt.Errorf("%w: no package document", ErrInvalid)
+	}
+
+	opfXML, err := readEntry(files, opfPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg opfPackage
+	if err := xml.Unmarshal(opfXML, &pkg); err != nil {
+		return nil, fmt.Errorf("%w: package document: %v", ErrInvalid, err)
+	}
+
+	meta := buildMetadata(&pkg)
+
+	// Manifest hrefs are URLs relative to the package document
+	if href, mediaType := coverItem(&pkg); href != "" {
+		coverPath := resolve(opfPath, href)
+		if data, err := readEntry(files, coverPath); err == nil {
+			meta.CoverPath = coverPath
+			meta.CoverMediaType = mediaType
+			meta.Cover = data
+		}
+	}
+
+	return meta, nil
+}
+
+func buildMetadata(pkg *opfPackage) *Metadata {
+	md := pkg.Metadata
+	meta := &Metadata{
+		Creators:    []string{},
+		Identifiers: []Identifier{},
+		Subjects:    []string{},
+	}
+
+	if len(md.Titles) > 0 {
+		meta.Title = clean(md.Titles[0])
+	}
+	if len(md.Languages) > 0 {
+		meta.Language = clean(md.Languages[0])
+	}
+	for _, creator := range md.Creators {
+		if creator = clean(creator); creator != "" {
+			meta.Creators = append(meta.Creators, creator)
+		}
+	}
+	for _, subject := range md.Subjects {
+		if subject = clean(subject); subject != "" {
+			meta.Subjects = append(meta.Subjects, subject)
+		}
+	}
+
+	// EPUB 3 declares identifier schemes with refining meta elements
+	schemes := map[string]string{}
+	for _, m := range md.Metas {
+		if m.Property == "identifier-type" && strings.HasPrefix(m.Refines, "#") {
+			schemes[m.Refines[1:]] = clean(m.Value)
+		}
+	}
+
+	for _, id := range md.Identifiers {
+		value := clean(id.Value)
+		if value == "" {
+			continue
+		}
+		scheme := id.Scheme
+		if scheme == "" {
+			scheme = schemes[id.ID]
+		}
+		meta.Identifiers = append(meta.Identifiers, Identifier{Scheme: scheme, Value: value})
+
+		if meta.ISBN == "" {
+			candidate := value
+			lower := strings.ToLower(candidate)
+			if strings.HasPrefix(lower, "urn:isbn:") {
+				candidate = candidate[len("urn:isbn:"):]
+			} else if strings.HasPrefix(lower, "isbn:") {
+				candidate = candidate[len("isbn:"):]
+			}
+			if normalized, err := isbn.Normalize(candidate); err == nil {
+				meta.ISBN = normalized
+			}
+		}
+	}
+
+	return meta
+}
+
+// coverItem finds the cover image in the manifest: the EPUB 3 cover-image
+// property, the EPUB 2 cover meta element, or an image whose id names a cover
+func coverItem(pkg *opfPackage) (string, string) {
+	for _, item := range pkg.Manifest {
+		for _, prop := range strings.Fields(item.Properties) {
+			if prop == "cover-image" {
+				return item.Href, item.MediaType
+			}
+		}
+	}
+
+	coverID := ""
+	for _, m := range pkg.Metadata.Metas {
+		if m.Name == "cover" {
+			coverID = m.Content
+		}
+	}
+	for _, item := range pkg.Manifest {
+		if coverID != "" && item.ID == coverID {
+			return item.Href, item.MediaType
+		}
+	}
+
+	for _, item := range pkg.Manifest {
+		if strings.HasPrefix(item.MediaType, "image/") && strings.Contains(strings.ToLower(item.ID), "cover") {
+			return item.Href, item.MediaType
+		}
+	}
+
+	return "", ""
+}
+
+// resolve joins a manifest href onto the directory of the package document
+func resolve(opfPath, href string) string {
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(path.Dir(opfPath), href)
+}
+
+func readEntry(files map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalid, name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	defer rc.Close()
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(rc, maxEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if n > maxEntrySize {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalid, name)
+	}
+	return buf.Bytes(), nil
+}
+
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"biblia-be/internal/service"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefix marks Biblia API keys so they are recognisable in configs and logs
+const apiKeyPrefix = "bbl_"
+
+// authUserKey is the gin context key holding the ID of the authenticated user
+const authUserKey = "authUserID"
+
+// APIKeyHandler manages API keys and authenticates requests made with them
+type APIKeyHandler struct {
+	db *gorm.DB
+}
+
+// Initialize sets up the handler with a database connection
+func (handler *APIKeyHandler) Initialize(db *gorm.DB) {
+	handler.db = db
+}
+
+// CreatedAPIKeyResponse includes the plaintext key, which is only ever returned once
+type CreatedAPIKeyResponse struct {
+	model.APIKey
+	Key string `json:"key"`
+}
+
+// hashAPIKey returns the stored form of a key
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey godoc
+//
+//	@Summary	Create an API key
+//	@Schemes
+//	@Description	Creates an API key for e-reader apps and other non-interactive clients. The user's password is required. The key is only shown in this response.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id	path	int	true	"User ID"
+//	@Param			key	body	model.CreateAPIKey	true	"Key name and the user's password"
+//	@Success		201	{object} Response{data=CreatedAPIKeyResponse} "API key created successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		401	{object} Response "Invalid password"
+//	@Failure		404	{object} Response "User not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/api-keys [post]
+func (handler *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	var createAPIKey model.CreateAPIKey
+	if err := c.ShouldBindJSON(&createAPIKey); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+
+	var user model.User
+	if err := handler.db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, service.CodeUserNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	// Keys grant full read access, so creating one requires the account password
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(createAPIKey.Password)) != nil {
+		c.Error(newError(service.ErrUnauthorized, CodeInvalidPassword))
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		c.Error(err)
+		return
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := model.APIKey{
+		UserID:  user.ID,
+		Name:    createAPIKey.Name,
+		Prefix:  key[:12],
+		KeyHash: hashAPIKey(key),
+	}
+	if err := handler.db.Create(&apiKey).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, Response{
+		Success: true,
+		Data:    CreatedAPIKeyResponse{APIKey: apiKey, Key: key},
+		Message: "API key created successfully",
+	})
+}
+
+// GetAPIKeys godoc
+//
+//	@Summary	List API keys
+//	@Schemes
+//	@Description	Returns the user's API keys without their secrets
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id	path	int	true	"User ID"
+//	@Success		200	{object} Response{data=[]model.APIKey} "Successfully retrieved API keys"
+//	@Failure		400	{object} Response "Invalid user ID"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/api-keys [get]
+func (handler *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	apiKeys := []model.APIKey{}
+	if err := handler.db.Where("user_id = ?", id).Order("id").Find(&apiKeys).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    apiKeys,
+		Message: "API keys retrieved successfully",
+	})
+}
+
+// DeleteAPIKey godoc
+//
+//	@Summary	Revoke an API key
+//	@Schemes
+//	@Description	Permanently revokes one of the user's API keys
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int	true	"User ID"
+//	@Param			keyId	path	int	true	"API key ID"
+//	@Success		200	{object} Response "API key revoked successfully"
+//	@Failure		400	{object} Response "Invalid ID"
+//	@Failure		404	{object} Response "API key not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/api-keys/{keyId} [delete]
+func (handler *APIKeyHandler) DeleteAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+	keyId, err := strconv.ParseUint(c.Param("keyId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidAPIKeyID", nil))
+		return
+	}
+
+	result := handler.db.Where("id = ? AND user_id = ?", keyId, id).Delete(&model.APIKey{})
+	if result.Error != nil {
+		c.Error(result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(newError(service.ErrNotFound, CodeAPIKeyNotFound))
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: "API key revoked successfully",
+	})
+}
+
+// RequireAPIKey returns middleware that authenticates the request with an API key
+// and stores the owner's ID in the context. The key is accepted as a bearer token,
+// an X-API-Key header, the password of HTTP basic auth (which e-reader apps
+// support) or an apikey query parameter. Unauthenticated requests get a basic auth
+// challenge for the given realm.
+func (handler *APIKeyHandler) RequireAPIKey(realm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-API-Key")
+		if key == "" {
+			if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				key = strings.TrimPrefix(auth, "Bearer ")
+			}
+		}
+		if key == "" {
+			if _, password, ok := c.Request.BasicAuth(); ok {
+				key = password
+			}
+		}
+		if key == "" {
+			key = c.Query("apikey")
+		}
+
+		var apiKey model.APIKey
+		if key != "" {
+			result := handler.db.Where("key_hash = ?", hashAPIKey(key)).Limit(1).Find(&apiKey)
+			if result.Error != nil {
+				c.Error(result.Error)
+				c.Abort()
+				return
+			}
+			if result.RowsAffected == 0 {
+				key = ""
+			}
+		}
+
+		if key == "" {
+			c.Header("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+			c.Error(newError(service.ErrUnauthorized, CodeAPIKeyRequired))
+			c.Abort()
+			return
+		}
+
+		// Record usage at most once a minute to keep reads cheap
+		now := time.Now()
+		if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > time.Minute {
+			handler.db.Model(&apiKey).Update("last_used_at", now)
+		}
+
+		c.Set(authUserKey, apiKey.UserID)
+		c.Next()
+	}
+}
+
+// authUserID returns the ID of the user authenticated by RequireAPIKey
+func authUserID(c *gin.Context) uint {
+	return c.GetUint(authUserKey)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"biblia-be/internal/barcode"
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/isbn"
+	"biblia-be/internal/model"
+	"biblia-be/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BookHandler manages operations on the shared book catalog
+type BookHandler struct {
+	db           *gorm.DB
+	scanMaxBytes int64
+}
+
+// Initialize sets up the handler with a database connection and the photo upload size limit
+func (handler *BookHandler) Initialize(db *gorm.DB, scanMaxBytes int64) {
+	handler.db = db
+	handler.scanMaxBytes = scanMaxBytes
+}
+
+// ScanResponse is the ISBN decoded from a barcode photo
+type ScanResponse struct {
+	ISBN   string             `json:"isbn"`
+	ISBN10 string             `json:"isbn10,omitempty"`
+	Book   *model.CatalogBook `json:"book,omitempty"`
+}
+
+// findCatalogBook returns the catalog metadata of a book from the most recently
+// added record with any of the given ISBN forms, or nil when nobody has it
+func findCatalogBook(db *gorm.DB, isbns []string) (*model.CatalogBook, error) {
+	var record model.Record
+	result := db.Where("isbn IN ?", isbns).Order("date_added DESC").Limit(1).Find(&record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	book := catalogBook(record)
+	return &book, nil
+}
+
+// catalogBook returns the shared metadata of a record, leaving out the owner's progress and notes
+func catalogBook(record model.Record) model.CatalogBook {
+	return model.CatalogBook{
+		ISBN:       record.ISBN,
+		Title:      record.Title,
+		Author:     record.Author,
+		Cover:      record.Cover,
+		Genre:      record.Genre,
+		TotalPages: record.TotalPages,
+	}
+}
+
+// ScanBook godoc
+//
+//	@Summary	Scan a book barcode
+//	@Schemes
+//	@Description	Decodes the EAN-13 barcode in a JPEG or PNG photo, validates its checksum and returns the normalized ISBN with catalog metadata when the book is known
+//	@Tags			books
+//	@Accept			multipart/form-data
+//	@Produce		json
+//
+//	@Param			photo	formData	file	true	"Photo of the barcode"
+//	@Success		200	{object} Response{data=ScanResponse} "Barcode decoded successfully"
+//	@Failure		400	{object} Response "Invalid image"
+//	@Failure		413	{object} Response "Image too large"
+//	@Failure		415	{object} Response "Unsupported image type"
+//	@Failure		422	{object} Response "No ISBN barcode found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/books/scan [post]
+func (handler *BookHandler) ScanBook(c *gin.Context) {
+	data, err := readImageUpload(c, "photo", handler.scanMaxBytes)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	img, _, err := decodeImage(data)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Decode the barcode; the decoder only returns codes with a valid check digit
+	ean, err := barcode.DecodeEAN13(img)
+	if err != nil {
+		c.Error(newError(errUnprocessable, CodeBarcodeNotFound))
+		return
+	}
+
+	isbn13, err := isbn.Normalize(ean)
+	if err != nil {
+		c.Error(service.NewError(errUnprocessable, CodeBarcodeNotISBN, "", i18n.Params{"barcode": ean}))
+		return
+	}
+
+	book, err := findCatalogBook(handler.db, isbn.Variants(isbn13))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: ScanResponse{
+			ISBN:   isbn13,
+			ISBN10: isbn.ToISBN10(isbn13),
+			Book:   book,
+		},
+		Message: "Barcode decoded successfully",
+	})
+}
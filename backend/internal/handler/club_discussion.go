@@ -0,0 +1,349 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"biblia-be/internal/service"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ClubPostResponse is a post as seen by one member. Posts beyond the member's
+// current page in the book are spoilers: their body is withheld until the member
+// reads that far. Titles are always shown and should not give anything away.
+type ClubPostResponse struct {
+	model.ClubPost
+	Username string `json:"username"`
+	Spoiler  bool   `json:"spoiler"`
+	Replies  int64  `json:"replies"`
+}
+
+// ClubThreadResponse is a thread with its replies in posting order
+type ClubThreadResponse struct {
+	ClubPostResponse
+	Posts []ClubPostResponse `json:"posts"`
+}
+
+// readerPages returns how far userId has read each of the given books. Finished
+// books count as read to the end.
+func (handler *ClubHandler) readerPages(userId uint, isbns []string) (map[string]int32, error) {
+	var records []model.Record
+	if err := handler.db.Select("isbn", "status", "current_page").
+		Where("user_id = ? AND isbn IN ?", userId, isbns).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	pages := make(map[string]int32, len(records))
+	for _, record := range records {
+		pages[record.ISBN] = record.CurrentPage
+		if record.Status == model.StatusFinished {
+			pages[record.ISBN] = math.MaxInt32
+		}
+	}
+	return pages, nil
+}
+
+// gatePosts prepares posts for a reader, hiding the text of spoilers
+func (handler *ClubHandler) gatePosts(userId uint, posts []model.ClubPost) ([]ClubPostResponse, error) {
+	responses := make([]ClubPostResponse, len(posts))
+	if len(posts) == 0 {
+		return responses, nil
+	}
+
+	isbns := make([]string, 0, len(posts))
+	authorIds := make([]uint, 0, len(posts))
+	for _, post := range posts {
+		isbns = append(isbns, post.ISBN)
+		authorIds = append(authorIds, post.UserID)
+	}
+
+	pages, err := handler.readerPages(userId, isbns)
+	if err != nil {
+		return nil, err
+	}
+
+	var authors []model.User
+	if err := handler.db.Select("id", "username").Where("id IN ?", authorIds).Find(&authors).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[uint]string, len(authors))
+	for _, author := range authors {
+		usernames[author.ID] = author.Username
+	}
+
+	for i, post := range posts {
+		responses[i] = ClubPostResponse{ClubPost: post, Username: usernames[post.UserID]}
+		if post.UserID != userId && post.Page > pages[post.ISBN] {
+			responses[i].Spoiler = true
+			responses[i].Body = ""
+		}
+	}
+	return responses, nil
+}
+
+// bindClubPost reads and validates a new post, writing a response if it is invalid
+func bindClubPost(c *gin.Context) (model.CreateClubPost, bool) {
+	var createPost model.CreateClubPost
+	if err := c.ShouldBindJSON(&createPost); err != nil {
+		c.Error(service.InvalidInput(err))
+		return createPost, false
+	}
+	return createPost, true
+}
+
+// GetClubThreads godoc
+//
+//	@Summary	List discussion threads
+//	@Schemes
+//	@Description	Returns the club's threads, newest first, with reply counts. Threads about pages the member has not reached yet are returned as spoilers without their text.
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int	true	"Club ID"
+//	@Param			userId	query	int	true	"Member reading the threads"
+//	@Success		200	{object} Response{data=[]ClubPostResponse} "Successfully retrieved threads"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		403	{object} Response "Not a member"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs/{id}/threads [get]
+func (handler *ClubHandler) GetClubThreads(c *gin.Context) {
+	id, ok := parseClubID(c)
+	if !ok {
+		return
+	}
+	userId, ok := parseActingUser(c)
+	if !ok {
+		return
+	}
+	if _, ok := handler.requireRole(c, id, userId, model.ClubMember); !ok {
+		return
+	}
+
+	var threads []model.ClubPost
+	if err := handler.db.Where("club_id = ? AND parent_id IS NULL", id).Order("created_at DESC").Find(&threads).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	responses, err := handler.gatePosts(userId, threads)
+	if err == nil && len(threads) > 0 {
+		var counts []struct {
+			ParentID uint
+			Replies  int64
+		}
+		err = handler.db.Model(&model.ClubPost{}).
+			Select("parent_id, COUNT(*) AS replies").
+			Where("club_id = ? AND parent_id IS NOT NULL", id).
+			Group("parent_id").
+			Scan(&counts).Error
+		replies := make(map[uint]int64, len(counts))
+		for _, count := range counts {
+			replies[count.ParentID] = count.Replies
+		}
+		for i := range responses {
+			responses[i].Replies = replies[responses[i].ID]
+		}
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    responses,
+		Message: "Threads retrieved successfully",
+	})
+}
+
+// GetClubThread godoc
+//
+//	@Summary	Get a discussion thread
+//	@Schemes
+//	@Description	Returns a thread with its replies. Posts about pages the member has not reached yet are returned as spoilers without their text.
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int	true	"Club ID"
+//	@Param			threadId	path	int	true	"Thread ID"
+//	@Param			userId		query	int	true	"Member reading the thread"
+//	@Success		200	{object} Response{data=ClubThreadResponse} "Successfully retrieved thread"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		403	{object} Response "Not a member"
+//	@Failure		404	{object} Response "Thread not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs/{id}/threads/{threadId} [get]
+func (handler *ClubHandler) GetClubThread(c *gin.Context) {
+	id, ok := parseClubID(c)
+	if !ok {
+		return
+	}
+	userId, ok := parseActingUser(c)
+	if !ok {
+		return
+	}
+	thread, ok := handler.loadThread(c, id)
+	if !ok {
+		return
+	}
+	if _, ok := handler.requireRole(c, id, userId, model.ClubMember); !ok {
+		return
+	}
+
+	var replies []model.ClubPost
+	if err := handler.db.Where("parent_id = ?", thread.ID).Order("created_at, id").Find(&replies).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	posts, err := handler.gatePosts(userId, append([]model.ClubPost{thread}, replies...))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response := ClubThreadResponse{ClubPostResponse: posts[0], Posts: posts[1:]}
+	response.Replies = int64(len(replies))
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    response,
+		Message: "Thread retrieved successfully",
+	})
+}
+
+// loadThread fetches the thread named in the path, writing a response if it cannot
+func (handler *ClubHandler) loadThread(c *gin.Context, clubId uint) (model.ClubPost, bool) {
+	var thread model.ClubPost
+	threadId, err := strconv.ParseUint(c.Param("threadId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidThreadID", nil))
+		return thread, false
+	}
+
+	err = handler.db.Where("id = ? AND club_id = ? AND parent_id IS NULL", threadId, clubId).First(&thread).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeThreadNotFound))
+			return thread, false
+		}
+
+		c.Error(err)
+		return thread, false
+	}
+	return thread, true
+}
+
+// CreateClubThread godoc
+//
+//	@Summary	Start a discussion thread
+//	@Schemes
+//	@Description	Starts a thread about the club's current book. Page marks how far into the book the post goes; members who have not read that far see it as a spoiler.
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int						true	"Club ID"
+//	@Param			post	body	model.CreateClubPost	true	"Thread title, text and page"
+//	@Success		201	{object} Response{data=model.ClubPost} "Thread created successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		403	{object} Response "Not a member"
+//	@Failure		404	{object} Response "Club not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs/{id}/threads [post]
+func (handler *ClubHandler) CreateClubThread(c *gin.Context) {
+	id, ok := parseClubID(c)
+	if !ok {
+		return
+	}
+	createPost, ok := bindClubPost(c)
+	if !ok {
+		return
+	}
+	if createPost.Title == "" {
+		c.Error(newError(service.ErrValidation, CodeThreadTitleRequired))
+		return
+	}
+
+	club, ok := handler.loadClub(c, id)
+	if !ok {
+		return
+	}
+	if _, ok := handler.requireRole(c, id, createPost.UserID, model.ClubMember); !ok {
+		return
+	}
+
+	post := model.ClubPost{
+		ClubID: id,
+		UserID: createPost.UserID,
+		ISBN:   club.ISBN,
+		Title:  createPost.Title,
+		Body:   createPost.Body,
+		Page:   createPost.Page,
+	}
+	handler.createPost(c, post, "Thread created successfully")
+}
+
+// ReplyToClubThread godoc
+//
+//	@Summary	Reply to a discussion thread
+//	@Schemes
+//	@Description	Adds a reply to a thread. Page marks how far into the book the reply goes; members who have not read that far see it as a spoiler.
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int						true	"Club ID"
+//	@Param			threadId	path	int						true	"Thread ID"
+//	@Param			post		body	model.CreateClubPost	true	"Reply text and page"
+//	@Success		201	{object} Response{data=model.ClubPost} "Reply created successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		403	{object} Response "Not a member"
+//	@Failure		404	{object} Response "Thread not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs/{id}/threads/{threadId}/replies [post]
+func (handler *ClubHandler) ReplyToClubThread(c *gin.Context) {
+	id, ok := parseClubID(c)
+	if !ok {
+		return
+	}
+	createPost, ok := bindClubPost(c)
+	if !ok {
+		return
+	}
+	thread, ok := handler.loadThread(c, id)
+	if !ok {
+		return
+	}
+	if _, ok := handler.requireRole(c, id, createPost.UserID, model.ClubMember); !ok {
+		return
+	}
+
+	post := model.ClubPost{
+		ClubID:   id,
+		ParentID: &thread.ID,
+		UserID:   createPost.UserID,
+		ISBN:     thread.ISBN,
+		Body:     createPost.Body,
+		Page:     createPost.Page,
+	}
+	handler.createPost(c, post, "Reply created successfully")
+}
+
+func (handler *ClubHandler) createPost(c *gin.Context, post model.ClubPost, message string) {
+	if err := handler.db.Create(&post).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, Response{
+		Success: true,
+		Data:    post,
+		Message: message,
+	})
+}
@@ -0,0 +1,615 @@
+package handler
+
+import (
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/model"
+	"biblia-be/internal/service"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// clubRoleRank orders roles by privilege
+var clubRoleRank = map[string]int{
+	model.ClubMember:    1,
+	model.ClubModerator: 2,
+	model.ClubOwner:     3,
+}
+
+// ClubHandler manages book clubs, their reading schedules and discussions.
+// Requests act as the user given by the userId query parameter or body field.
+type ClubHandler struct {
+	db *gorm.DB
+}
+
+// Initialize sets up the handler with a database connection
+func (handler *ClubHandler) Initialize(db *gorm.DB) {
+	handler.db = db
+}
+
+// MemberProgress is a member's position in the club's book compared to the schedule
+type MemberProgress struct {
+	UserID      uint   `json:"userID"`
+	Username    string `json:"username"`
+	Role        string `json:"role"`
+	CurrentPage int32  `json:"currentPage"`
+	// ExpectedPage is where the schedule says members should be today
+	ExpectedPage *int32 `json:"expectedPage,omitempty"`
+	// Pace is ahead, on_track or behind, or empty without a schedule
+	Pace string `json:"pace,omitempty"`
+}
+
+// parseClubID reads the club ID from the path
+func parseClubID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidClubID", nil))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseActingUser reads the userId query parameter
+func parseActingUser(c *gin.Context) (uint, bool) {
+	userId, err := strconv.ParseUint(c.Query("userId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return 0, false
+	}
+	return uint(userId), true
+}
+
+// loadClub fetches a club, writing a response if it cannot
+func (handler *ClubHandler) loadClub(c *gin.Context, id uint) (model.Club, bool) {
+	var club model.Club
+	if err := handler.db.First(&club, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeClubNotFound))
+			return club, false
+		}
+
+		c.Error(err)
+		return club, false
+	}
+	return club, true
+}
+
+// requireRole checks that userId belongs to the club with at least the given role,
+// writing a response if not
+func (handler *ClubHandler) requireRole(c *gin.Context, clubId, userId uint, role string) (model.ClubMembership, bool) {
+	var membership model.ClubMembership
+	result := handler.db.Where("club_id = ? AND user_id = ?", clubId, userId).Limit(1).Find(&membership)
+	if result.Error != nil {
+		c.Error(result.Error)
+		return membership, false
+	}
+	if result.RowsAffected == 0 || clubRoleRank[membership.Role] < clubRoleRank[role] {
+		c.Error(service.NewError(service.ErrForbidden, CodeClubRoleRequired, "", i18n.Params{"role": role}))
+		return membership, false
+	}
+	return membership, true
+}
+
+// CreateClub godoc
+//
+//	@Summary	Create a book club
+//	@Schemes
+//	@Description	Creates a club owned by the given user
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			club	body	model.CreateClub	true	"Club details and owner"
+//	@Success		201	{object} Response{data=model.Club} "Club created successfully"
+//	@Failure		400	{object} Response "Invalid request body"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs [post]
+func (handler *ClubHandler) CreateClub(c *gin.Context) {
+	var createClub model.CreateClub
+	if err := c.ShouldBindJSON(&createClub); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+
+	club := model.Club{
+		Name:        createClub.Name,
+		Description: createClub.Description,
+		Members: []model.ClubMembership{{
+			UserID:   createClub.UserID,
+			Role:     model.ClubOwner,
+			JoinedAt: time.Now(),
+		}},
+	}
+	if err := handler.db.Create(&club).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, Response{
+		Success: true,
+		Data:    club,
+		Message: "Club created successfully",
+	})
+}
+
+// GetClubs godoc
+//
+//	@Summary	List a user's clubs
+//	@Schemes
+//	@Description	Returns the clubs the user is a member of
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			userId	query	int	true	"User ID"
+//	@Success		200	{object} Response{data=[]model.Club} "Successfully retrieved clubs"
+//	@Failure		400	{object} Response "Invalid userId"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs [get]
+func (handler *ClubHandler) GetClubs(c *gin.Context) {
+	userId, ok := parseActingUser(c)
+	if !ok {
+		return
+	}
+
+	clubs := []model.Club{}
+	err := handler.db.Where("id IN (?)",
+		handler.db.Model(&model.ClubMembership{}).Select("club_id").Where("user_id = ?", userId),
+	).Order("name").Find(&clubs).Error
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    clubs,
+		Message: "Clubs retrieved successfully",
+	})
+}
+
+// GetClub godoc
+//
+//	@Summary	Get a book club
+//	@Schemes
+//	@Description	Returns a club with its members and reading schedule
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id	path	int	true	"Club ID"
+//	@Success		200	{object} Response{data=model.Club} "Successfully retrieved club"
+//	@Failure		400	{object} Response "Invalid club ID"
+//	@Failure		404	{object} Response "Club not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs/{id} [get]
+func (handler *ClubHandler) GetClub(c *gin.Context) {
+	id, ok := parseClubID(c)
+	if !ok {
+		return
+	}
+
+	var club model.Club
+	err := handler.db.
+		Preload("Members", func(db *gorm.DB) *gorm.DB { return db.Order("joined_at") }).
+		Preload("Schedule", func(db *gorm.DB) *gorm.DB { return db.Order("due_date") }).
+		First(&club, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeClubNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    club,
+		Message: "Club retrieved successfully",
+	})
+}
+
+// JoinClub godoc
+//
+//	@Summary	Join a book club
+//	@Schemes
+//	@Description	Adds the user to the club as a member
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int	true	"Club ID"
+//	@Param			userId	query	int	true	"User joining the club"
+//	@Success		201	{object} Response{data=model.ClubMembership} "Joined club successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Club not found"
+//	@Failure		409	{object} Response "Already a member"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs/{id}/members [post]
+func (handler *ClubHandler) JoinClub(c *gin.Context) {
+	id, ok := parseClubID(c)
+	if !ok {
+		return
+	}
+	userId, ok := parseActingUser(c)
+	if !ok {
+		return
+	}
+	if _, ok := handler.loadClub(c, id); !ok {
+		return
+	}
+
+	var existing int64
+	if err := handler.db.Model(&model.ClubMembership{}).Where("club_id = ? AND user_id = ?", id, userId).Count(&existing).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if existing > 0 {
+		c.Error(newError(service.ErrConflict, CodeAlreadyMember))
+		return
+	}
+
+	membership := model.ClubMembership{ClubID: id, UserID: userId, Role: model.ClubMember, JoinedAt: time.Now()}
+	if err := handler.db.Create(&membership).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, Response{
+		Success: true,
+		Data:    membership,
+		Message: "Joined club successfully",
+	})
+}
+
+// UpdateClubMember godoc
+//
+//	@Summary	Change a member's role
+//	@Schemes
+//	@Description	Makes a member a moderator or a plain member, or hands ownership over, in which case the current owner becomes a moderator. Only the owner can change roles.
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int						true	"Club ID"
+//	@Param			memberId	path	int						true	"User ID of the member"
+//	@Param			userId		query	int						true	"Club owner making the change"
+//	@Param			role		body	model.UpdateClubMember	true	"New role"
+//	@Success		200	{object} Response{data=model.ClubMembership} "Role updated successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		403	{object} Response "Not the club owner"
+//	@Failure		404	{object} Response "Member not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs/{id}/members/{memberId} [put]
+func (handler *ClubHandler) UpdateClubMember(c *gin.Context) {
+	id, ok := parseClubID(c)
+	if !ok {
+		return
+	}
+	userId, ok := parseActingUser(c)
+	if !ok {
+		return
+	}
+	memberId, err := strconv.ParseUint(c.Param("memberId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidMemberID", nil))
+		return
+	}
+
+	var update model.UpdateClubMember
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+
+	owner, ok := handler.requireRole(c, id, userId, model.ClubOwner)
+	if !ok {
+		return
+	}
+	if uint(memberId) == owner.UserID {
+		c.Error(newError(service.ErrValidation, CodeOwnerRoleFixed))
+		return
+	}
+
+	var membership model.ClubMembership
+	if err := handler.db.Where("club_id = ? AND user_id = ?", id, memberId).First(&membership).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeMemberNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	membership.Role = update.Role
+	err = handler.db.Transaction(func(tx *gorm.DB) error {
+		if update.Role == model.ClubOwner {
+			if err := tx.Model(&owner).Update("role", model.ClubModerator).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&membership).Update("role", membership.Role).Error
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    membership,
+		Message: "Role updated successfully",
+	})
+}
+
+// RemoveClubMember godoc
+//
+//	@Summary	Leave a club or remove a member
+//	@Schemes
+//	@Description	Members can leave a club; moderators and the owner can remove members with a lower role. The owner has to hand ownership over before leaving.
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int	true	"Club ID"
+//	@Param			memberId	path	int	true	"User ID of the member"
+//	@Param			userId		query	int	true	"User making the change"
+//	@Success		200	{object} Response "Member removed successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		403	{object} Response "Not allowed"
+//	@Failure		404	{object} Response "Member not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs/{id}/members/{memberId} [delete]
+func (handler *ClubHandler) RemoveClubMember(c *gin.Context) {
+	id, ok := parseClubID(c)
+	if !ok {
+		return
+	}
+	userId, ok := parseActingUser(c)
+	if !ok {
+		return
+	}
+	memberId, err := strconv.ParseUint(c.Param("memberId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidMemberID", nil))
+		return
+	}
+
+	actor, ok := handler.requireRole(c, id, userId, model.ClubMember)
+	if !ok {
+		return
+	}
+
+	var membership model.ClubMembership
+	if err := handler.db.Where("club_id = ? AND user_id = ?", id, memberId).First(&membership).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeMemberNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	if membership.Role == model.ClubOwner {
+		c.Error(newError(service.ErrValidation, CodeOwnerCannotLeave))
+		return
+	}
+	leaving := membership.UserID == actor.UserID
+	if !leaving && (actor.Role == model.ClubMember || clubRoleRank[actor.Role] <= clubRoleRank[membership.Role]) {
+		c.Error(newError(service.ErrForbidden, CodeCannotRemoveMember))
+		return
+	}
+
+	if err := handler.db.Where("club_id = ? AND user_id = ?", id, memberId).Delete(&model.ClubMembership{}).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: "Member removed successfully",
+	})
+}
+
+// SetClubBook godoc
+//
+//	@Summary	Set the club's current book
+//	@Schemes
+//	@Description	Sets the book the club is reading and replaces its schedule, a list of pages to reach by given dates. Requires the moderator or owner role.
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int					true	"Club ID"
+//	@Param			userId	query	int					true	"Moderator making the change"
+//	@Param			book	body	model.SetClubBook	true	"Book and schedule"
+//	@Success		200	{object} Response{data=model.Club} "Book set successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		403	{object} Response "Not a moderator"
+//	@Failure		404	{object} Response "Club not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs/{id}/book [put]
+func (handler *ClubHandler) SetClubBook(c *gin.Context) {
+	id, ok := parseClubID(c)
+	if !ok {
+		return
+	}
+	userId, ok := parseActingUser(c)
+	if !ok {
+		return
+	}
+
+	var setBook model.SetClubBook
+	if err := c.ShouldBindJSON(&setBook); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+
+	club, ok := handler.loadClub(c, id)
+	if !ok {
+		return
+	}
+	if _, ok := handler.requireRole(c, id, userId, model.ClubModerator); !ok {
+		return
+	}
+
+	start := time.Now()
+	if setBook.StartDate != nil {
+		start = *setBook.StartDate
+	}
+
+	sort.Slice(setBook.Schedule, func(i, j int) bool {
+		return setBook.Schedule[i].DueDate.Before(setBook.Schedule[j].DueDate)
+	})
+	schedule := make([]model.ClubMilestone, len(setBook.Schedule))
+	lastPage := int32(0)
+	for i, milestone := range setBook.Schedule {
+		if milestone.Page <= lastPage || milestone.Page > setBook.TotalPages || milestone.DueDate.Before(start) {
+			c.Error(newError(service.ErrValidation, CodeInvalidSchedule))
+			return
+		}
+		lastPage = milestone.Page
+		schedule[i] = model.ClubMilestone{ClubID: id, Label: milestone.Label, Page: milestone.Page, DueDate: milestone.DueDate}
+	}
+
+	club.ISBN = setBook.ISBN
+	club.Title = setBook.Title
+	club.TotalPages = setBook.TotalPages
+	club.StartDate = &start
+	club.Schedule = schedule
+
+	err := handler.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("club_id = ?", id).Delete(&model.ClubMilestone{}).Error; err != nil {
+			return err
+		}
+		return tx.Save(&club).Error
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    club,
+		Message: "Book set successfully",
+	})
+}
+
+// expectedPage interpolates the schedule to the page members should have reached
+// at the given time. It returns nil when the club has no schedule.
+func expectedPage(club model.Club, now time.Time) *int32 {
+	if len(club.Schedule) == 0 || club.StartDate == nil {
+		return nil
+	}
+
+	prevPage, prevDate := int32(0), *club.StartDate
+	page := int32(0)
+	for _, milestone := range club.Schedule {
+		if !now.After(milestone.DueDate) {
+			if span := milestone.DueDate.Sub(prevDate); span > 0 && now.After(prevDate) {
+				fraction := float64(now.Sub(prevDate)) / float64(span)
+				page = prevPage + int32(math.Round(fraction*float64(milestone.Page-prevPage)))
+			} else {
+				page = prevPage
+			}
+			return &page
+		}
+		prevPage, prevDate = milestone.Page, milestone.DueDate
+	}
+	page = prevPage
+	return &page
+}
+
+// pace compares a member's page with the expected page, allowing a margin of 2%
+// of the book so members a page or two apart are still on track
+func pace(current, expected, totalPages int32) string {
+	margin := int32(math.Max(1, math.Round(float64(totalPages)*0.02)))
+	switch {
+	case current > expected+margin:
+		return "ahead"
+	case current < expected-margin:
+		return "behind"
+	default:
+		return "on_track"
+	}
+}
+
+// GetClubProgress godoc
+//
+//	@Summary	Get members' progress
+//	@Schemes
+//	@Description	Returns each member's current page in the club's book, taken from their reading record, compared to where the schedule expects them to be today
+//	@Tags			clubs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int	true	"Club ID"
+//	@Param			userId	query	int	true	"Member asking"
+//	@Success		200	{object} Response{data=[]MemberProgress} "Successfully retrieved progress"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		403	{object} Response "Not a member"
+//	@Failure		404	{object} Response "Club not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/clubs/{id}/progress [get]
+func (handler *ClubHandler) GetClubProgress(c *gin.Context) {
+	id, ok := parseClubID(c)
+	if !ok {
+		return
+	}
+	userId, ok := parseActingUser(c)
+	if !ok {
+		return
+	}
+
+	var club model.Club
+	err := handler.db.Preload("Schedule", func(db *gorm.DB) *gorm.DB { return db.Order("due_date") }).First(&club, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeClubNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+	if _, ok := handler.requireRole(c, id, userId, model.ClubMember); !ok {
+		return
+	}
+
+	progress := []MemberProgress{}
+	err = handler.db.Model(&model.ClubMembership{}).
+		Select("club_memberships.user_id, users.username, club_memberships.role, COALESCE(records.current_page, 0) AS current_page").
+		Joins("JOIN users ON users.id = club_memberships.user_id").
+		Joins("LEFT JOIN records ON records.user_id = club_memberships.user_id AND records.isbn = ?", club.ISBN).
+		Where("club_memberships.club_id = ?", id).
+		Order("current_page DESC, users.username").
+		Scan(&progress).Error
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if expected := expectedPage(club, time.Now()); expected != nil {
+		for i := range progress {
+			progress[i].ExpectedPage = expected
+			progress[i].Pace = pace(progress[i].CurrentPage, *expected, club.TotalPages)
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    progress,
+		Message: "Progress retrieved successfully",
+	})
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"biblia-be/internal/imaging"
+	"biblia-be/internal/model"
+	"biblia-be/internal/repository"
+	"biblia-be/internal/service"
+	"biblia-be/internal/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// coverPrefix is the blob store namespace and URL path of cover images
+const coverPrefix = "covers/"
+
+// thumbnailWidths are the generated thumbnail sizes in pixels
+var thumbnailWidths = []int{160, 320}
+
+// coverKeyPattern matches content addressed cover keys and their thumbnails
+var coverKeyPattern = regexp.MustCompile(`^[0-9a-f]{64}(-[0-9]+)?\.(jpg|png)$`)
+
+// CoverHandler manages cover image uploads and serving
+type CoverHandler struct {
+	db       *gorm.DB
+	store    storage.BlobStore
+	maxBytes int64
+}
+
+// Initialize sets up the handler with a database connection, a blob store and the upload size limit
+func (handler *CoverHandler) Initialize(db *gorm.DB, store storage.BlobStore, maxBytes int64) {
+	handler.db = db
+	handler.store = store
+	handler.maxBytes = maxBytes
+}
+
+// CoverResponse is a record together with the URLs of its stored cover
+type CoverResponse struct {
+	Record     model.Record      `json:"record"`
+	Cover      string            `json:"cover"`
+	Thumbnails map[string]string `json:"thumbnails"`
+}
+
+// coverURL returns the public URL of a cover key
+func coverURL(key string) string {
+	return "/" + coverPrefix + key
+}
+
+// coverThumbnailURL returns the URL of a thumbnail of an uploaded cover, or the
+// cover itself when it is an external URL supplied by the client
+func coverThumbnailURL(cover string, width int) string {
+	key, ok := strings.CutPrefix(cover, "/"+coverPrefix)
+	if !ok || !coverKeyPattern.MatchString(key) {
+		return cover
+	}
+	hash, _, _ := strings.Cut(key, ".")
+	hash, _, _ = strings.Cut(hash, "-")
+	return coverURL(fmt.Sprintf("%s-%d.jpg", hash, width))
+}
+
+// UploadCover godoc
+//
+//	@Summary	Upload a cover image
+//	@Schemes
+//	@Description	Stores a JPEG or PNG cover for a record, generates thumbnails and points the record's cover at the upload
+//	@Tags			records
+//	@Accept			multipart/form-data
+//	@Produce		json
+//
+//	@Param			id		path		int		true	"Record ID"
+//	@Param			cover	formData	file	true	"JPEG or PNG image"
+//	@Success		200	{object} Response{data=CoverResponse} "Cover uploaded successfully"
+//	@Failure		400	{object} Response "Invalid record ID or image"
+//	@Failure		404	{object} Response "Record not found"
+//	@Failure		413	{object} Response "Image too large"
+//	@Failure		415	{object} Response "Unsupported image type"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/records/{id}/cover [post]
+func (handler *CoverHandler) UploadCover(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidRecordID", nil))
+		return
+	}
+
+	var record model.Record
+	if err := handler.db.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(recordNotFound())
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	data, err := readImageUpload(c, "cover", handler.maxBytes)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	upload, err := storeCoverImage(c.Request.Context(), handler.store, data)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Point the record at the uploaded cover unless it changed since it was read
+	result := handler.db.Model(&record).Where("version = ?", record.Version).
+		Updates(map[string]interface{}{"cover": upload.Cover, "version": repository.NextVersion()})
+	if result.Error != nil {
+		c.Error(result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(service.NewError(service.ErrConflict, service.CodeRecordModified, "", nil))
+		return
+	}
+	record.Cover = upload.Cover
+	record.Version++
+
+	upload.Record = record
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    upload,
+		Message: "Cover uploaded successfully",
+	})
+}
+
+// storeCoverImage decodes an image, stores it with its thumbnails and returns their URLs
+func storeCoverImage(ctx context.Context, store storage.BlobStore, data []byte) (CoverResponse, error) {
+	img, format, err := decodeImage(data)
+	if err != nil {
+		return CoverResponse{}, err
+	}
+
+	ext := "jpg"
+	contentType := "image/jpeg"
+	if format == "png" {
+		ext = "png"
+		contentType = "image/png"
+	}
+
+	// Keys are content addressed so identical uploads share storage and URLs never go stale
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	key := fmt.Sprintf("%s.%s", hash, ext)
+
+	if err := store.Put(ctx, coverPrefix+key, bytes.NewReader(data), contentType); err != nil {
+		return CoverResponse{}, fmt.Errorf("store cover: %w", err)
+	}
+
+	thumbnails := make(map[string]string, len(thumbnailWidths))
+	for _, width := range thumbnailWidths {
+		var buf bytes.Buffer
+		if err := imaging.EncodeJPEG(&buf, imaging.Thumbnail(img, width)); err != nil {
+			return CoverResponse{}, fmt.Errorf("generate thumbnail: %w", err)
+		}
+
+		thumbKey := fmt.Sprintf("%s-%d.jpg", hash, width)
+		if err := store.Put(ctx, coverPrefix+thumbKey, &buf, "image/jpeg"); err != nil {
+			return CoverResponse{}, fmt.Errorf("store thumbnail: %w", err)
+		}
+		thumbnails[strconv.Itoa(width)] = coverURL(thumbKey)
+	}
+
+	return CoverResponse{Cover: coverURL(key), Thumbnails: thumbnails}, nil
+}
+
+// decodeImage decodes an uploaded JPEG or PNG image
+func decodeImage(data []byte) (image.Image, string, error) {
+	img, format, err := imaging.Decode(data)
+	if err != nil {
+		if errors.Is(err, imaging.ErrTooLarge) {
+			return nil, "", newError(errTooLarge, CodeImageTooLarge)
+		}
+		return nil, "", newError(service.ErrValidation, CodeInvalidImage)
+	}
+	return img, format, nil
+}
+
+// readImageUpload reads a JPEG or PNG file from a multipart form field, enforcing
+// the size limit and sniffing the content rather than trusting the client's type
+func readImageUpload(c *gin.Context, field string, maxBytes int64) ([]byte, error) {
+	// Leave room for the multipart envelope around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+
+	fileHeader, err := c.FormFile(field)
+	if err != nil {
+		return nil, formFileError(err, field)
+	}
+
+	if fileHeader.Size > maxBytes {
+		return nil, newError(errTooLarge, CodeFileTooLarge)
+	}
+
+	declared := fileHeader.Header.Get("Content-Type")
+	if declared != "" && declared != "image/jpeg" && declared != "image/png" && declared != "application/octet-stream" {
+		return nil, newError(errUnsupportedMedia, CodeUnsupportedImageType)
+	}
+
+	data, err := readUpload(fileHeader, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	sniffed := http.DetectContentType(data)
+	if sniffed != "image/jpeg" && sniffed != "image/png" {
+		return nil, newError(errUnsupportedMedia, CodeUnsupportedImageType)
+	}
+
+	return data, nil
+}
+
+// GetCover godoc
+//
+//	@Summary	Get a cover image
+//	@Schemes
+//	@Description	Serves an uploaded cover or thumbnail. Keys are content addressed, so responses are cacheable forever.
+//	@Tags			covers
+//	@Produce		image/jpeg
+//	@Produce		image/png
+//
+//	@Param			key	path	string	true	"Cover key"
+//	@Success		200	{file}	binary	"Cover image"
+//	@Success		304	"Not modified"
+//	@Failure		404	{object} Response "Cover not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/covers/{key} [get]
+func (handler *CoverHandler) GetCover(c *gin.Context) {
+	key := c.Param("key")
+	if !coverKeyPattern.MatchString(key) {
+		c.Error(newError(service.ErrNotFound, CodeCoverNotFound))
+		return
+	}
+
+	etag := `"` + key + `"`
+	if match := c.GetHeader("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		c.Header("ETag", etag)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	reader, info, err := handler.store.Get(c.Request.Context(), coverPrefix+key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeCoverNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, info.Size, info.ContentType, reader, map[string]string{
+		"Cache-Control": "public, max-age=31536000, immutable",
+		"ETag":          etag,
+		"Last-Modified": info.ModTime.UTC().Format(http.TimeFormat),
+	})
+}
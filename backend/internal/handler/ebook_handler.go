@@ -0,0 +1,325 @@
+package handler
+
+import (
+	"biblia-be/internal/epub"
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/isbn"
+	"biblia-be/internal/kosync"
+	"biblia-be/internal/model"
+	"biblia-be/internal/repository"
+	"biblia-be/internal/service"
+	"biblia-be/internal/storage"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ebookPrefix is the blob store namespace of uploaded ebooks
+const ebookPrefix = "ebooks/"
+
+// EbookHandler manages EPUB uploads and downloads
+type EbookHandler struct {
+	db       *gorm.DB
+	store    storage.BlobStore
+	maxBytes int64
+}
+
+// Initialize sets up the handler with a database connection, a blob store and the upload size limit
+func (handler *EbookHandler) Initialize(db *gorm.DB, store storage.BlobStore, maxBytes int64) {
+	handler.db = db
+	handler.store = store
+	handler.maxBytes = maxBytes
+}
+
+// EbookUploadResponse is the metadata extracted from an EPUB and the record it was attached to
+type EbookUploadResponse struct {
+	Metadata *epub.Metadata     `json:"metadata"`
+	Prefill  model.CreateRecord `json:"prefill"`
+	Record   *model.Record      `json:"record,omitempty"`
+	Created  bool               `json:"created"`
+}
+
+// UploadEbook godoc
+//
+//	@Summary	Upload an EPUB
+//	@Schemes
+//	@Description	Extracts title, creators, ISBN, language, subjects and cover from a DRM-free EPUB and stores the file. The user's record for the ISBN is created, or an existing one is linked to the file and has its empty fields filled. With create=false nothing is written and only the pre-filled record is returned.
+//	@Tags			records
+//	@Accept			multipart/form-data
+//	@Produce		json
+//
+//	@Param			userId	formData	int		true	"User ID of the record owner"
+//	@Param			epub	formData	file	true	"EPUB file"
+//	@Param			isbn	formData	string	false	"ISBN to use when the EPUB does not declare one"
+//	@Param			create	formData	bool	false	"Create or update the record (default true)"
+//	@Success		200	{object} Response{data=EbookUploadResponse} "Existing record linked or metadata extracted"
+//	@Success		201	{object} Response{data=EbookUploadResponse} "Record created"
+//	@Failure		400	{object} Response "Invalid request or EPUB"
+//	@Failure		413	{object} Response "File too large"
+//	@Failure		422	{object} Response "No ISBN available"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/records/epub [post]
+func (handler *EbookHandler) UploadEbook(c *gin.Context) {
+	// Leave room for the multipart envelope around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, handler.maxBytes+1<<20)
+
+	fileHeader, err := c.FormFile("epub")
+	if err != nil {
+		c.Error(formFileError(err, "epub"))
+		return
+	}
+
+	userId, err := strconv.ParseUint(c.PostForm("userId"), 10, 32)
+	if err != nil || userId == 0 {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+	create := c.DefaultPostForm("create", "true") != "false"
+
+	data, err := readUpload(fileHeader, handler.maxBytes)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	meta, err := epub.Parse(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		c.Error(newError(service.ErrValidation, CodeInvalidEbook))
+		return
+	}
+
+	// An explicit ISBN wins over the one declared in the package document
+	if override := c.PostForm("isbn"); override != "" {
+		normalized, err := isbn.Normalize(override)
+		if err != nil {
+			c.Error(invalidParameter("param.invalidISBN", nil))
+			return
+		}
+		meta.ISBN = normalized
+	}
+
+	prefill := model.CreateRecord{
+		UserID: uint(userId),
+		ISBN:   meta.ISBN,
+		Title:  meta.Title,
+		Author: strings.Join(meta.Creators, ", "),
+	}
+	if len(meta.Subjects) > 0 {
+		prefill.Genre = meta.Subjects[0]
+	}
+
+	if !create {
+		c.JSON(http.StatusOK, Response{
+			Success: true,
+			Data:    EbookUploadResponse{Metadata: meta, Prefill: prefill},
+			Message: "EPUB metadata extracted successfully",
+		})
+		return
+	}
+
+	if meta.ISBN == "" {
+		c.Error(newError(errUnprocessable, CodeEbookISBNMissing))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Store the cover first so the record can point at it
+	if len(meta.Cover) > 0 && (meta.CoverMediaType == "image/jpeg" || meta.CoverMediaType == "image/png") {
+		if upload, err := storeCoverImage(ctx, handler.store, meta.Cover); err == nil {
+			prefill.Cover = upload.Cover
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	ebookKey := ebookPrefix + hex.EncodeToString(sum[:]) + ".epub"
+	if err := handler.store.Put(ctx, ebookKey, bytes.NewReader(data), "application/epub+zip"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Link an existing record, filling only the fields the user left empty
+	var record model.Record
+	result := handler.db.Where("user_id = ? AND isbn IN ?", userId, isbn.Variants(meta.ISBN)).Limit(1).Find(&record)
+	if result.Error != nil {
+		c.Error(result.Error)
+		return
+	}
+
+	documentID := kosync.PartialMD5(bytes.NewReader(data), int64(len(data)))
+	created := result.RowsAffected == 0
+	if created {
+		record = service.NewRecord(prefill)
+		record.EbookKey = ebookKey
+		record.DocumentID = documentID
+		if err := handler.db.Create(&record).Error; err != nil {
+			c.Error(err)
+			return
+		}
+	} else {
+		updates := map[string]interface{}{
+			"ebook_key":   ebookKey,
+			"document_id": documentID,
+			"version":     repository.NextVersion(),
+		}
+		if record.Title == "" {
+			updates["title"] = prefill.Title
+		}
+		if record.Author == "" {
+			updates["author"] = prefill.Author
+		}
+		if record.Genre == "" {
+			updates["genre"] = prefill.Genre
+		}
+		if record.Cover == "" {
+			updates["cover"] = prefill.Cover
+		}
+
+		// Only the fields above change, and only if the record is as it was read
+		result := handler.db.Model(&record).Where("version = ?", record.Version).Updates(updates)
+		if result.Error != nil {
+			c.Error(result.Error)
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.Error(service.NewError(service.ErrConflict, service.CodeRecordModified, "", nil))
+			return
+		}
+		if err := handler.db.First(&record, record.ID).Error; err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	status := http.StatusOK
+	message := "EPUB linked to existing record"
+	if created {
+		status = http.StatusCreated
+		message = "Record created from EPUB"
+	}
+	c.JSON(status, Response{
+		Success: true,
+		Data:    EbookUploadResponse{Metadata: meta, Prefill: prefill, Record: &record, Created: created},
+		Message: message,
+	})
+}
+
+// DownloadEbook godoc
+//
+//	@Summary	Download a record's EPUB
+//	@Schemes
+//	@Description	Streams the EPUB file uploaded for a record
+//	@Tags			records
+//	@Produce		application/epub+zip
+//
+//	@Param			id	path	int	true	"Record ID"
+//	@Success		200	{file}	binary	"EPUB file"
+//	@Failure		400	{object} Response "Invalid record ID"
+//	@Failure		404	{object} Response "Record or EPUB not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/records/{id}/epub [get]
+func (handler *EbookHandler) DownloadEbook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidRecordID", nil))
+		return
+	}
+
+	var record model.Record
+	if err := handler.db.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(recordNotFound())
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	serveEbook(c, handler.store, record)
+}
+
+// serveEbook streams the EPUB stored for a record as an attachment
+func serveEbook(c *gin.Context, store storage.BlobStore, record model.Record) {
+	if record.EbookKey == "" {
+		c.Error(newError(service.ErrNotFound, CodeEbookNotFound))
+		return
+	}
+
+	reader, info, err := store.Get(c.Request.Context(), record.EbookKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeEbookNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, info.Size, "application/epub+zip", reader, map[string]string{
+		"Content-Disposition": `attachment; filename="` + ebookFilename(record) + `"`,
+	})
+}
+
+// ebookFilename builds an ASCII download name from the record title, or its
+// ISBN when the title has no usable characters
+func ebookFilename(record model.Record) string {
+	name := strings.Map(filenameRune, record.Title)
+	if name == "" {
+		name = strings.Map(filenameRune, record.ISBN)
+	}
+	if name == "" {
+		name = "book"
+	}
+	return name + ".epub"
+}
+
+// filenameRune keeps the characters that are safe in a quoted
+// Content-Disposition filename
+func filenameRune(r rune) rune {
+	if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+		return r
+	}
+	if r == ' ' {
+		return '_'
+	}
+	return -1
+}
+
+// formFileError reports why the file of a multipart form field could not be had
+func formFileError(err error, field string) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return newError(errTooLarge, CodeFileTooLarge)
+	}
+	return service.NewError(service.ErrValidation, CodeFileMissing, "", i18n.Params{"field": field})
+}
+
+// readUpload reads an uploaded file up to maxBytes
+func readUpload(fileHeader *multipart.FileHeader, maxBytes int64) ([]byte, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, newError(service.ErrValidation, CodeFileUnreadable)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, newError(service.ErrValidation, CodeFileUnreadable)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, newError(errTooLarge, CodeFileTooLarge)
+	}
+	return data, nil
+}
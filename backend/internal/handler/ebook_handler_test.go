@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"testing"
+)
+
+func TestEbookFilename(t *testing.T) {
+	tests := []struct {
+		name   string
+		record model.Record
+		want   string
+	}{
+		{"title", model.Record{Title: "Khu Kam: Sunset", ISBN: "9786161851125"}, "Khu_Kam_Sunset.epub"},
+		{"thai title", model.Record{Title: "คู่กรรม", ISBN: "9786161851125"}, "9786161851125.epub"},
+		{"quoted isbn", model.Record{Title: "คู่กรรม", ISBN: `97861"; filename="x.exe`}, "97861_filenamexexe.epub"},
+		{"legacy isbn", model.Record{ISBN: "legacy-42"}, "legacy-42.epub"},
+		{"nothing usable", model.Record{Title: "คู่กรรม", ISBN: `"";`}, "book.epub"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ebookFilename(tc.record); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
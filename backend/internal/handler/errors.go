@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/service"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Error codes of failures found by the handlers themselves
+const (
+	CodeInvalidParameter = "INVALID_PARAMETER"
+	CodeInternal         = "INTERNAL_ERROR"
+
+	// Uploads
+	CodeFileMissing          = "FILE_MISSING"
+	CodeFileUnreadable       = "FILE_UNREADABLE"
+	CodeFileTooLarge         = "FILE_TOO_LARGE"
+	CodeUnsupportedImageType = "UNSUPPORTED_IMAGE_TYPE"
+	CodeImageTooLarge        = "IMAGE_TOO_LARGE"
+	CodeInvalidImage         = "INVALID_IMAGE"
+	CodeInvalidEbook         = "INVALID_EPUB"
+	CodeEbookISBNMissing     = "EPUB_ISBN_MISSING"
+	CodeEbookNotFound        = "EPUB_NOT_FOUND"
+	CodeCoverNotFound        = "COVER_NOT_FOUND"
+	CodeBarcodeNotFound      = "BARCODE_NOT_FOUND"
+	CodeBarcodeNotISBN       = "BARCODE_NOT_ISBN"
+
+	// API keys
+	CodeInvalidPassword = "INVALID_PASSWORD"
+	CodeAPIKeyRequired  = "API_KEY_REQUIRED"
+	CodeAPIKeyNotFound  = "API_KEY_NOT_FOUND"
+
+	// Jobs
+	CodeJobNotFound = "JOB_NOT_FOUND"
+
+	// Clubs
+	CodeClubNotFound        = "CLUB_NOT_FOUND"
+	CodeMemberNotFound      = "MEMBER_NOT_FOUND"
+	CodeThreadNotFound      = "THREAD_NOT_FOUND"
+	CodeAlreadyMember       = "ALREADY_MEMBER"
+	CodeClubRoleRequired    = "CLUB_ROLE_REQUIRED"
+	CodeCannotRemoveMember  = "CANNOT_REMOVE_MEMBER"
+	CodeOwnerRoleFixed      = "OWNER_ROLE_FIXED"
+	CodeOwnerCannotLeave    = "OWNER_CANNOT_LEAVE"
+	CodeInvalidSchedule     = "INVALID_SCHEDULE"
+	CodeThreadTitleRequired = "THREAD_TITLE_REQUIRED"
+
+	// Loans
+	CodeLoanNotFound      = "LOAN_NOT_FOUND"
+	CodeBorrowerNotFound  = "BORROWER_NOT_FOUND"
+	CodeBorrowerRequired  = "BORROWER_REQUIRED"
+	CodeBorrowerHasBook   = "BORROWER_HAS_BOOK"
+	CodeSelfLoan          = "SELF_LOAN"
+	CodeNotOnShelf        = "NOT_ON_SHELF"
+	CodeLoanReturned      = "LOAN_RETURNED"
+	CodeInvalidDueDate    = "INVALID_DUE_DATE"
+	CodeInvalidReturnDate = "INVALID_RETURN_DATE"
+
+	// To-be-read queues
+	CodeQueueItemNotFound = "QUEUE_ITEM_NOT_FOUND"
+	CodeQueueEmpty        = "QUEUE_EMPTY"
+	CodeAlreadyQueued     = "ALREADY_QUEUED"
+	CodeInvalidQueueMove  = "INVALID_QUEUE_MOVE"
+
+	// Series
+	CodeSeriesNotFound       = "SERIES_NOT_FOUND"
+	CodeVolumeNotFound       = "VOLUME_NOT_FOUND"
+	CodeInvalidSeriesEntry   = "INVALID_SERIES_ENTRY"
+	CodeDuplicateSeriesEntry = "DUPLICATE_SERIES_ENTRY"
+	CodeAlreadyInSeries      = "ALREADY_IN_SERIES"
+
+	// Follows
+	CodeSelfFollow            = "SELF_FOLLOW"
+	CodeProfilePrivate        = "PROFILE_PRIVATE"
+	CodeNotFollowing          = "NOT_FOLLOWING"
+	CodeFollowRequestNotFound = "FOLLOW_REQUEST_NOT_FOUND"
+)
+
+// Kinds of failures that only the HTTP layer knows about
+var (
+	errTooLarge         = errors.New("payload too large")
+	errUnsupportedMedia = errors.New("unsupported media type")
+	errUnprocessable    = errors.New("unprocessable")
+)
+
+// Name the fields of gin's binding errors after their JSON keys, as the
+// services do
+func init() {
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterTagNameFunc(service.JSONFieldName)
+	}
+}
+
+// errorStatuses map the kinds of domain errors to HTTP statuses
+var errorStatuses = map[error]int{
+	service.ErrNotFound:           http.StatusNotFound,
+	service.ErrConflict:           http.StatusConflict,
+	service.ErrValidation:         http.StatusBadRequest,
+	service.ErrUnauthorized:       http.StatusUnauthorized,
+	service.ErrForbidden:          http.StatusForbidden,
+	service.ErrPreconditionFailed: http.StatusPreconditionFailed,
+	errTooLarge:                   http.StatusRequestEntityTooLarge,
+	errUnsupportedMedia:           http.StatusUnsupportedMediaType,
+	errUnprocessable:              http.StatusUnprocessableEntity,
+}
+
+// invalidParameter reports a missing or malformed path or query parameter with
+// the catalog message of key
+func invalidParameter(key string, params i18n.Params) *service.Error {
+	return service.NewError(service.ErrValidation, CodeInvalidParameter, key, params)
+}
+
+// localizeFields translates the messages of field errors
+func localizeFields(locale string, fields []service.FieldError) []service.FieldError {
+	if len(fields) == 0 {
+		return nil
+	}
+	localized := make([]service.FieldError, len(fields))
+	for i, field := range fields {
+		localized[i] = field
+		localized[i].Message = field.Localize(locale)
+	}
+	return localized
+}
+
+// newError reports a failure of a kind with the catalog message of its code
+func newError(kind error, code string) *service.Error {
+	return service.NewError(kind, code, "", nil)
+}
+
+// recordNotFound reports a record looked up by its ID
+func recordNotFound() *service.Error {
+	return service.NewError(service.ErrNotFound, service.CodeRecordNotFound, "record.notFound", nil)
+}
+
+// ErrorHandler writes the error a handler attached with c.Error as a Response
+// carrying its code, field errors and the request ID, in the language of the
+// response. Domain errors keep their message; anything else is logged and
+// reported as an internal error so database details never reach clients.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+
+		var domainErr *service.Error
+		if errors.As(last.Err, &domainErr) {
+			status, ok := errorStatuses[domainErr.Kind]
+			if !ok {
+				status = http.StatusInternalServerError
+			}
+			c.JSON(status, Response{
+				Success:   false,
+				Error:     domainErr.Localize(locale(c)),
+				Code:      domainErr.Code,
+				Fields:    localizeFields(locale(c), domainErr.Fields),
+				RequestID: requestID(c),
+			})
+			return
+		}
+
+		log.Printf("[%s] %s %s: %v", requestID(c), c.Request.Method, c.Request.URL.Path, last.Err)
+		c.JSON(http.StatusInternalServerError, Response{
+			Success:   false,
+			Error:     translate(c, "error."+CodeInternal),
+			Code:      CodeInternal,
+			RequestID: requestID(c),
+		})
+	}
+}
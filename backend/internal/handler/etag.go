@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"biblia-be/internal/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setETag announces the version of a record, for clients to send back in
+// If-Match when they change it
+func setETag(c *gin.Context, record model.Record) {
+	c.Header("ETag", record.ETag())
+}
+
+// ifMatch reads the ETags of the If-Match header. Weak tags are kept but never
+// match, since If-Match compares tags strongly.
+func ifMatch(c *gin.Context) service.Precondition {
+	var tags service.Precondition
+	for _, tag := range strings.Split(c.GetHeader("If-Match"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
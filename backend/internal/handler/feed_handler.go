@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// FeedHandler serves activity feeds built from the events table
+type FeedHandler struct {
+	db *gorm.DB
+}
+
+// Initialize sets up the handler with a database connection
+func (handler *FeedHandler) Initialize(db *gorm.DB) {
+	handler.db = db
+}
+
+// FeedEvent is an event with the name of the user who caused it
+type FeedEvent struct {
+	model.Event
+	Username string `json:"username"`
+}
+
+// eventSorts are the orderings supported by GET /feed
+var eventSorts = map[string]sortSpec[model.Event]{
+	"createdAt": {
+		expr:  "created_at",
+		desc:  true,
+		value: func(e model.Event) interface{} { return e.CreatedAt },
+		parse: parseTimeCursor,
+		id:    func(e model.Event) uint { return e.ID },
+	},
+}
+
+// recordEvents derives the feed events of a change from before to after. before is
+// nil for a new record.
+func recordEvents(before *model.Record, after model.Record) []model.Event {
+	var types []string
+	if before == nil {
+		before = &model.Record{}
+		types = append(types, model.EventAdded)
+	}
+	if after.Status != before.Status {
+		switch after.Status {
+		case model.StatusReading:
+			types = append(types, model.EventStarted)
+		case model.StatusFinished:
+			types = append(types, model.EventFinished)
+		}
+	}
+	if after.Rating > 0 && after.Rating != before.Rating {
+		types = append(types, model.EventRated)
+	}
+
+	events := make([]model.Event, len(types))
+	for i, eventType := range types {
+		events[i] = model.Event{
+			UserID:   after.UserID,
+			RecordID: &after.ID,
+			Type:     eventType,
+			ISBN:     after.ISBN,
+			Title:    after.Title,
+			Cover:    after.Cover,
+		}
+		if eventType == model.EventRated {
+			events[i].Rating = after.Rating
+		}
+	}
+	return events
+}
+
+// writeRecordEvents stores the feed events of a record change within tx
+func writeRecordEvents(tx *gorm.DB, before *model.Record, after model.Record) error {
+	events := recordEvents(before, after)
+	if len(events) == 0 {
+		return nil
+	}
+	return tx.Create(&events).Error
+}
+
+// GetFeed godoc
+//
+//	@Summary	Get activity feed
+//	@Schemes
+//	@Description	Returns the latest events of the users a user follows: books added, started, finished and rated. Pending follow requests and private profiles are left out. Pass meta.next_cursor back as cursor to fetch older events.
+//	@Tags			feed
+//	@Accept			json
+//	@Produce		json
+//
+// @Param userId query int true "User whose feed to show"
+// @Param limit query int false "Page size (1-200, default 50)"
+// @Param cursor query string false "Cursor from the previous page"
+//
+//	@Success		200	{object} Response{data=[]FeedEvent,meta=Meta} "Successfully retrieved feed"
+//	@Failure		400	{object} Response "Invalid request parameters"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/feed [get]
+func (handler *FeedHandler) GetFeed(c *gin.Context) {
+	userId, err := strconv.ParseUint(c.Query("userId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	page, err := parsePageRequest(c, eventSorts, "createdAt")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	followed := handler.db.Model(&model.Follow{}).
+		Select("follows.followee_id").
+		Joins("JOIN users ON users.id = follows.followee_id").
+		Where("follows.follower_id = ? AND follows.accepted = ? AND users.privacy <> ?", userId, true, model.PrivacyPrivate)
+
+	events := []model.Event{}
+	if err := page.apply(handler.db.Where("user_id IN (?)", followed)).Find(&events).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	meta := &Meta{Limit: page.limit}
+	events, meta.NextCursor = page.trim(events)
+
+	userIds := make([]uint, 0, len(events))
+	for _, event := range events {
+		userIds = append(userIds, event.UserID)
+	}
+	var users []model.User
+	if len(userIds) > 0 {
+		if err := handler.db.Select("id", "username").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	feed := make([]FeedEvent, len(events))
+	for i, event := range events {
+		feed[i] = FeedEvent{Event: event, Username: usernames[event.UserID]}
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    feed,
+		Meta:    meta,
+		Message: "Feed retrieved successfully",
+	})
+}
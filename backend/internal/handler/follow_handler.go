@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"biblia-be/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// FollowHandler manages who follows whom
+type FollowHandler struct {
+	db *gorm.DB
+}
+
+// Initialize sets up the handler with a database connection
+func (handler *FollowHandler) Initialize(db *gorm.DB) {
+	handler.db = db
+}
+
+// FollowResponse describes the other side of a follow
+type FollowResponse struct {
+	UserID    uint      `json:"userID"`
+	Username  string    `json:"username"`
+	Accepted  bool      `json:"accepted"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// parseUserPair reads the user ID and the other user's ID from the path
+func parseUserPair(c *gin.Context, other string) (uint, uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return 0, 0, false
+	}
+	otherId, err := strconv.ParseUint(c.Param(other), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return 0, 0, false
+	}
+	return uint(id), uint(otherId), true
+}
+
+// Follow godoc
+//
+//	@Summary	Follow a user
+//	@Schemes
+//	@Description	Follows another user's activity. Following a public profile takes effect immediately; following a followers-only profile creates a request the other user has to approve. Private profiles cannot be followed.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int	true	"ID of the follower"
+//	@Param			targetId	path	int	true	"ID of the user to follow"
+//	@Success		200	{object} Response{data=model.Follow} "Already following"
+//	@Success		201	{object} Response{data=model.Follow} "Followed successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		403	{object} Response "Profile is private"
+//	@Failure		404	{object} Response "User not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/following/{targetId} [post]
+func (handler *FollowHandler) Follow(c *gin.Context) {
+	id, targetId, ok := parseUserPair(c, "targetId")
+	if !ok {
+		return
+	}
+	if id == targetId {
+		c.Error(newError(service.ErrValidation, CodeSelfFollow))
+		return
+	}
+
+	var users []model.User
+	if err := handler.db.Select("id", "privacy").Where("id IN ?", []uint{id, targetId}).Find(&users).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if len(users) != 2 {
+		c.Error(newError(service.ErrNotFound, service.CodeUserNotFound))
+		return
+	}
+	target := users[0]
+	if target.ID != targetId {
+		target = users[1]
+	}
+
+	if target.Privacy == model.PrivacyPrivate {
+		c.Error(newError(service.ErrForbidden, CodeProfilePrivate))
+		return
+	}
+
+	var follow model.Follow
+	result := handler.db.Where("follower_id = ? AND followee_id = ?", id, targetId).Limit(1).Find(&follow)
+	if result.Error != nil {
+		c.Error(result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		c.JSON(http.StatusOK, Response{
+			Success: true,
+			Data:    follow,
+			Message: "Already following",
+		})
+		return
+	}
+
+	follow = model.Follow{
+		FollowerID: id,
+		FolloweeID: targetId,
+		Accepted:   target.Privacy != model.PrivacyFollowers,
+	}
+	if err := handler.db.Create(&follow).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	message := "Followed successfully"
+	if !follow.Accepted {
+		message = "Follow request sent"
+	}
+	c.JSON(http.StatusCreated, Response{
+		Success: true,
+		Data:    follow,
+		Message: message,
+	})
+}
+
+// Unfollow godoc
+//
+//	@Summary	Unfollow a user
+//	@Schemes
+//	@Description	Stops following a user or withdraws a pending follow request
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int	true	"ID of the follower"
+//	@Param			targetId	path	int	true	"ID of the followed user"
+//	@Success		200	{object} Response "Unfollowed successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Not following"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/following/{targetId} [delete]
+func (handler *FollowHandler) Unfollow(c *gin.Context) {
+	id, targetId, ok := parseUserPair(c, "targetId")
+	if !ok {
+		return
+	}
+	handler.deleteFollow(c, id, targetId, "Unfollowed successfully")
+}
+
+// RemoveFollower godoc
+//
+//	@Summary	Remove a follower
+//	@Schemes
+//	@Description	Removes a follower or rejects a pending follow request
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int	true	"ID of the followed user"
+//	@Param			followerId	path	int	true	"ID of the follower"
+//	@Success		200	{object} Response "Follower removed successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Not following"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/followers/{followerId} [delete]
+func (handler *FollowHandler) RemoveFollower(c *gin.Context) {
+	id, followerId, ok := parseUserPair(c, "followerId")
+	if !ok {
+		return
+	}
+	handler.deleteFollow(c, followerId, id, "Follower removed successfully")
+}
+
+func (handler *FollowHandler) deleteFollow(c *gin.Context, followerId, followeeId uint, message string) {
+	result := handler.db.Where("follower_id = ? AND followee_id = ?", followerId, followeeId).Delete(&model.Follow{})
+	if result.Error != nil {
+		c.Error(result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(newError(service.ErrNotFound, CodeNotFollowing))
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: message,
+	})
+}
+
+// ApproveFollower godoc
+//
+//	@Summary	Approve a follow request
+//	@Schemes
+//	@Description	Accepts a pending request to follow a followers-only profile
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int	true	"ID of the followed user"
+//	@Param			followerId	path	int	true	"ID of the follower"
+//	@Success		200	{object} Response{data=model.Follow} "Follow request approved"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Follow request not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/followers/{followerId} [put]
+func (handler *FollowHandler) ApproveFollower(c *gin.Context) {
+	id, followerId, ok := parseUserPair(c, "followerId")
+	if !ok {
+		return
+	}
+
+	var follow model.Follow
+	if err := handler.db.Where("follower_id = ? AND followee_id = ?", followerId, id).First(&follow).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeFollowRequestNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	if !follow.Accepted {
+		follow.Accepted = true
+		if err := handler.db.Model(&follow).Update("accepted", true).Error; err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    follow,
+		Message: "Follow request approved",
+	})
+}
+
+// GetFollowers godoc
+//
+//	@Summary	List followers
+//	@Schemes
+//	@Description	Returns the users following a user, including pending requests
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id	path	int	true	"User ID"
+//	@Success		200	{object} Response{data=[]FollowResponse} "Successfully retrieved followers"
+//	@Failure		400	{object} Response "Invalid user ID"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/followers [get]
+func (handler *FollowHandler) GetFollowers(c *gin.Context) {
+	handler.listFollows(c, "followee_id", "follower_id", "Followers retrieved successfully")
+}
+
+// GetFollowing godoc
+//
+//	@Summary	List followed users
+//	@Schemes
+//	@Description	Returns the users a user follows, including pending requests
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id	path	int	true	"User ID"
+//	@Success		200	{object} Response{data=[]FollowResponse} "Successfully retrieved followed users"
+//	@Failure		400	{object} Response "Invalid user ID"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/following [get]
+func (handler *FollowHandler) GetFollowing(c *gin.Context) {
+	handler.listFollows(c, "follower_id", "followee_id", "Followed users retrieved successfully")
+}
+
+// listFollows lists the follows where column is the user, describing the user in other
+func (handler *FollowHandler) listFollows(c *gin.Context, column, other, message string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	follows := []FollowResponse{}
+	err = handler.db.Model(&model.Follow{}).
+		Select("users.id AS user_id, users.username, follows.accepted, follows.created_at").
+		Joins("JOIN users ON users.id = follows."+other).
+		Where("follows."+column+" = ?", id).
+		Order("follows.created_at DESC").
+		Scan(&follows).Error
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    follows,
+		Message: message,
+	})
+}
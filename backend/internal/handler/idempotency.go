@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"biblia-be/internal/idempotency"
+	"biblia-be/internal/service"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader carries the key a client picks to retry a request safely
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks a response replayed from an earlier request
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// Error codes of idempotency keys
+const (
+	CodeInvalidIdempotencyKey = "IDEMPOTENCY_KEY_INVALID"
+	CodeIdempotencyKeyInUse   = "IDEMPOTENCY_KEY_IN_USE"
+	CodeIdempotencyKeyReused  = "IDEMPOTENCY_KEY_REUSED"
+)
+
+// validIdempotencyKey accepts up to 255 visible ASCII characters, enough for
+// UUIDs and the keys of common client libraries
+var validIdempotencyKey = regexp.MustCompile(`^[\x21-\x7e]{1,255}$`)
+
+// Idempotency makes a route safe to retry. A request carrying an
+// Idempotency-Key header has its response stored for ttl and replayed when the
+// same key is sent again to the same method and path. Reusing the key with a
+// different body is rejected with 422, and a retry arriving while the first
+// request still runs gets 409. Failures reported through ErrorHandler and
+// server errors are not stored, so those requests can be corrected and sent
+// again with the same key. Requests without the header pass through.
+func Idempotency(store idempotency.Store, ttl time.Duration) gin.HandlerFunc {
+	if ttl <= 0 {
+		ttl = idempotency.DefaultTTL
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey.MatchString(key) {
+			abortWithCode(c, http.StatusBadRequest, CodeInvalidIdempotencyKey)
+			return
+		}
+
+		// Read the body for its hash and hand the handler a fresh copy
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Error(service.InvalidInput(err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keep the outcome even when the client hangs up mid-request
+		ctx := context.WithoutCancel(c.Request.Context())
+		scopedKey := digest(c.Request.Method, c.Request.URL.Path, key)
+		requestHash := digest(c.Request.URL.RawQuery, string(body))
+
+		existing, reserved, err := store.Reserve(ctx, scopedKey, idempotency.Entry{
+			RequestHash: requestHash,
+			ExpiresAt:   time.Now().Add(idempotency.LockTimeout),
+		})
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if !reserved {
+			replay(c, existing, requestHash)
+			return
+		}
+
+		// Free the key unless the response is stored, including when the
+		// handler panics
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := store.Release(ctx, scopedKey); err != nil {
+				log.Printf("[%s] failed to release idempotency key: %v", requestID(c), err)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if !recorder.Written() || recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		err = store.Complete(ctx, scopedKey, idempotency.Entry{
+			RequestHash: requestHash,
+			Status:      recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+			ExpiresAt:   time.Now().Add(ttl),
+		})
+		if err != nil {
+			log.Printf("[%s] failed to store idempotent response: %v", requestID(c), err)
+			return
+		}
+		completed = true
+	}
+}
+
+// replay answers a retry with the stored response, or rejects it when the key
+// belongs to a different request or one that has not finished
+func replay(c *gin.Context, entry idempotency.Entry, requestHash string) {
+	switch {
+	case entry.RequestHash != requestHash:
+		abortWithCode(c, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused)
+	case !entry.Completed():
+		abortWithCode(c, http.StatusConflict, CodeIdempotencyKeyInUse)
+	default:
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Data(entry.Status, entry.ContentType, entry.Body)
+		c.Abort()
+	}
+}
+
+// abortWithCode ends the request with a failure and its catalog message
+func abortWithCode(c *gin.Context, status int, code string) {
+	c.AbortWithStatusJSON(status, Response{
+		Success:   false,
+		Error:     translate(c, "error."+code),
+		Code:      code,
+		RequestID: requestID(c),
+	})
+}
+
+// digest returns the hex SHA-256 of parts, separated so that their boundaries
+// cannot shift
+func digest(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		io.WriteString(h, part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder copies the body of a response as it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
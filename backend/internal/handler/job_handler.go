@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"biblia-be/internal/jobs"
+	"biblia-be/internal/model"
+	"biblia-be/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// JobHandler exposes the status of background jobs
+type JobHandler struct {
+	queue *jobs.Queue
+}
+
+// Initialize sets up the handler with a job queue
+func (handler *JobHandler) Initialize(queue *jobs.Queue) {
+	handler.queue = queue
+}
+
+// JobResponse is a job together with its decoded result
+type JobResponse struct {
+	model.Job
+	Result json.RawMessage `json:"result,omitempty" swaggertype:"object"`
+}
+
+// toJobResponse converts a Job model to a JobResponse
+func toJobResponse(job model.Job) JobResponse {
+	response := JobResponse{Job: job}
+	if job.Result != "" {
+		response.Result = json.RawMessage(job.Result)
+	}
+	return response
+}
+
+// GetJob godoc
+//
+//	@Summary	Get a background job
+//	@Schemes
+//	@Description	Returns the status, progress percentage and result of a background job
+//	@Tags			jobs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id	path	int	true	"Job ID"
+//	@Success		200	{object} Response{data=JobResponse} "Successfully retrieved job"
+//	@Failure		400	{object} Response "Invalid job ID"
+//	@Failure		404	{object} Response "Job not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/jobs/{id} [get]
+func (handler *JobHandler) GetJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidJobID", nil))
+		return
+	}
+
+	job, err := handler.queue.Get(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeJobNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    toJobResponse(*job),
+		Message: "Job retrieved successfully",
+	})
+}
+
+// CancelJob godoc
+//
+//	@Summary	Cancel a background job
+//	@Schemes
+//	@Description	Cancels a queued job immediately or asks a running job to stop. Finished jobs are returned unchanged.
+//	@Tags			jobs
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id	path	int	true	"Job ID"
+//	@Success		200	{object} Response{data=JobResponse} "Cancellation requested"
+//	@Failure		400	{object} Response "Invalid job ID"
+//	@Failure		404	{object} Response "Job not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/jobs/{id} [delete]
+func (handler *JobHandler) CancelJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidJobID", nil))
+		return
+	}
+
+	job, err := handler.queue.Cancel(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeJobNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    toJobResponse(*job),
+		Message: "Job cancellation requested",
+	})
+}
@@ -0,0 +1,316 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"biblia-be/internal/repository"
+	"biblia-be/internal/service"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Error codes of the KOReader sync protocol
+const (
+	kosyncUnauthorized         = 2001
+	kosyncInvalidFields        = 2003
+	kosyncDocumentMissing      = 2004
+	kosyncRegistrationDisabled = 2005
+)
+
+// KosyncHandler implements the KOReader progress sync (kosync) API so e-readers can
+// push and pull reading positions. Progress for documents linked to a record also
+// moves that record's current page.
+type KosyncHandler struct {
+	db *gorm.DB
+}
+
+// Initialize sets up the handler with a database connection
+func (handler *KosyncHandler) Initialize(db *gorm.DB) {
+	handler.db = db
+}
+
+// kosyncError is the error body KOReader understands
+type kosyncError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// kosyncProgress is a reading position in the protocol's wire format
+type kosyncProgress struct {
+	Document   string  `json:"document"`
+	Progress   string  `json:"progress"`
+	Percentage float64 `json:"percentage"`
+	Device     string  `json:"device"`
+	DeviceID   string  `json:"device_id"`
+	Timestamp  int64   `json:"timestamp,omitempty"`
+}
+
+// RequireSyncAuth returns middleware that authenticates KOReader requests from the
+// x-auth-user (username) and x-auth-key (MD5 of the password) headers
+func (handler *KosyncHandler) RequireSyncAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetHeader("x-auth-user")
+		key := c.GetHeader("x-auth-key")
+
+		var user model.User
+		if username != "" && key != "" {
+			result := handler.db.Where("LOWER(username) = LOWER(?)", username).Limit(1).Find(&user)
+			if result.Error != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, kosyncError{Code: 1000, Message: "Unknown server error."})
+				return
+			}
+			if result.RowsAffected == 0 || user.SyncKey == "" ||
+				bcrypt.CompareHashAndPassword([]byte(user.SyncKey), []byte(key)) != nil {
+				user.ID = 0
+			}
+		}
+
+		if user.ID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, kosyncError{Code: kosyncUnauthorized, Message: "Unauthorized"})
+			return
+		}
+
+		c.Set(authUserKey, user.ID)
+		c.Next()
+	}
+}
+
+// RegisterUser godoc
+//
+//	@Summary	kosync registration
+//	@Schemes
+//	@Description	Accounts are created through POST /users; KOReader users log in with their Biblia username and password instead of registering
+//	@Tags			kosync
+//	@Produce		json
+//	@Failure		402	{object}	kosyncError	"Registration is disabled"
+//	@Router			/users/create [post]
+func (handler *KosyncHandler) RegisterUser(c *gin.Context) {
+	c.JSON(http.StatusPaymentRequired, kosyncError{
+		Code:    kosyncRegistrationDisabled,
+		Message: "User registration is disabled. Create an account in Biblia and log in with it.",
+	})
+}
+
+// AuthorizeUser godoc
+//
+//	@Summary	kosync login check
+//	@Schemes
+//	@Description	Confirms the x-auth-user and x-auth-key headers are valid
+//	@Tags			kosync
+//	@Produce		json
+//	@Param			x-auth-user	header	string	true	"Username"
+//	@Param			x-auth-key	header	string	true	"MD5 of the password"
+//	@Success		200	{object}	object	"Authorized"
+//	@Failure		401	{object}	kosyncError	"Unauthorized"
+//	@Router			/users/auth [get]
+func (handler *KosyncHandler) AuthorizeUser(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"authorized": "OK"})
+}
+
+// UpdateProgress godoc
+//
+//	@Summary	Push reading progress
+//	@Schemes
+//	@Description	Stores the reading position of a document. If the document is linked to a record, by an EPUB upload or PUT /records/{id}/sync-document, the record's current page follows the percentage.
+//	@Tags			kosync
+//	@Accept			json
+//	@Produce		json
+//	@Param			x-auth-user	header	string			true	"Username"
+//	@Param			x-auth-key	header	string			true	"MD5 of the password"
+//	@Param			progress	body	kosyncProgress	true	"Reading position"
+//	@Success		200	{object}	object	"Document and server timestamp"
+//	@Failure		401	{object}	kosyncError	"Unauthorized"
+//	@Failure		403	{object}	kosyncError	"Invalid fields"
+//	@Router			/syncs/progress [put]
+func (handler *KosyncHandler) UpdateProgress(c *gin.Context) {
+	userId := authUserID(c)
+
+	var body kosyncProgress
+	if err := c.ShouldBindJSON(&body); err != nil || body.Progress == "" || body.Device == "" {
+		c.JSON(http.StatusForbidden, kosyncError{Code: kosyncInvalidFields, Message: "Invalid request"})
+		return
+	}
+	if body.Document == "" {
+		c.JSON(http.StatusForbidden, kosyncError{Code: kosyncDocumentMissing, Message: "Field 'document' not provided."})
+		return
+	}
+
+	var progress model.SyncProgress
+	if err := handler.db.Where("user_id = ? AND document = ?", userId, body.Document).
+		Limit(1).Find(&progress).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, kosyncError{Code: 1000, Message: "Unknown server error."})
+		return
+	}
+
+	progress.UserID = userId
+	progress.Document = body.Document
+	progress.Progress = body.Progress
+	progress.Percentage = math.Max(0, math.Min(1, body.Percentage))
+	progress.Device = body.Device
+	progress.DeviceID = body.DeviceID
+	progress.Timestamp = time.Now().Unix()
+
+	// Documents uploaded as EPUBs map to their record by fingerprint
+	if progress.RecordID == nil {
+		var record model.Record
+		result := handler.db.Select("id").Where("user_id = ? AND document_id = ?", userId, body.Document).Limit(1).Find(&record)
+		if result.Error == nil && result.RowsAffected > 0 {
+			progress.RecordID = &record.ID
+		}
+	}
+
+	err := handler.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&progress).Error; err != nil {
+			return err
+		}
+		if progress.RecordID == nil {
+			return nil
+		}
+		return syncRecordPage(tx, *progress.RecordID, progress.Percentage)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, kosyncError{Code: 1000, Message: "Unknown server error."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"document":  progress.Document,
+		"timestamp": progress.Timestamp,
+	})
+}
+
+// syncRecordPage moves a record's current page to the given fraction of the book
+func syncRecordPage(tx *gorm.DB, recordId uint, percentage float64) error {
+	var record model.Record
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&record, recordId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if record.TotalPages <= 0 {
+		return nil
+	}
+
+	page := int32(math.Round(percentage * float64(record.TotalPages)))
+	if page == record.CurrentPage {
+		return nil
+	}
+	return tx.Model(&record).Updates(map[string]interface{}{"current_page": page, "version": repository.NextVersion()}).Error
+}
+
+// GetProgress godoc
+//
+//	@Summary	Pull reading progress
+//	@Schemes
+//	@Description	Returns the last reading position pushed for a document, or an empty object when there is none
+//	@Tags			kosync
+//	@Produce		json
+//	@Param			x-auth-user	header	string	true	"Username"
+//	@Param			x-auth-key	header	string	true	"MD5 of the password"
+//	@Param			document	path	string	true	"Document fingerprint"
+//	@Success		200	{object}	kosyncProgress	"Reading position"
+//	@Failure		401	{object}	kosyncError	"Unauthorized"
+//	@Router			/syncs/progress/{document} [get]
+func (handler *KosyncHandler) GetProgress(c *gin.Context) {
+	var progress model.SyncProgress
+	result := handler.db.Where("user_id = ? AND document = ?", authUserID(c), c.Param("document")).
+		Limit(1).Find(&progress)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, kosyncError{Code: 1000, Message: "Unknown server error."})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+
+	c.JSON(http.StatusOK, kosyncProgress{
+		Document:   progress.Document,
+		Progress:   progress.Progress,
+		Percentage: progress.Percentage,
+		Device:     progress.Device,
+		DeviceID:   progress.DeviceID,
+		Timestamp:  progress.Timestamp,
+	})
+}
+
+// LinkDocument godoc
+//
+//	@Summary	Link an e-reader document to a record
+//	@Schemes
+//	@Description	Maps a KOReader document fingerprint to a record so synced progress updates its current page. Needed for books that were not uploaded as EPUBs or that KOReader identifies by file name.
+//	@Tags			records
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int						true	"Record ID"
+//	@Param			document	body	model.LinkSyncDocument	true	"Document fingerprint"
+//	@Success		200	{object} Response{data=model.SyncProgress} "Document linked successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Record not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/records/{id}/sync-document [put]
+func (handler *KosyncHandler) LinkDocument(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidRecordID", nil))
+		return
+	}
+
+	var link model.LinkSyncDocument
+	if err := c.ShouldBindJSON(&link); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+
+	var record model.Record
+	if err := handler.db.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(recordNotFound())
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	var progress model.SyncProgress
+	if err := handler.db.Where("user_id = ? AND document = ?", record.UserID, link.Document).
+		Limit(1).Find(&progress).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	progress.UserID = record.UserID
+	progress.Document = link.Document
+	progress.RecordID = &record.ID
+
+	// Apply progress the device already pushed before the link existed
+	err = handler.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&progress).Error; err != nil {
+			return err
+		}
+		if progress.Timestamp == 0 {
+			return nil
+		}
+		return syncRecordPage(tx, record.ID, progress.Percentage)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    progress,
+		Message: "Document linked successfully",
+	})
+}
@@ -0,0 +1,370 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"biblia-be/internal/repository"
+	"biblia-be/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LoanHandler tracks physical books lent between users
+type LoanHandler struct {
+	db *gorm.DB
+}
+
+// Initialize sets up the handler with a database connection
+func (handler *LoanHandler) Initialize(db *gorm.DB) {
+	handler.db = db
+}
+
+// LoanResponse is a loan with whether it is overdue
+type LoanResponse struct {
+	model.Loan
+	Overdue bool `json:"overdue"`
+}
+
+// toLoanResponses marks overdue loans
+func toLoanResponses(loans []model.Loan) []LoanResponse {
+	now := time.Now()
+	responses := make([]LoanResponse, len(loans))
+	for i, loan := range loans {
+		responses[i] = LoanResponse{Loan: loan, Overdue: loan.Overdue(now)}
+	}
+	return responses
+}
+
+// CreateLoan godoc
+//
+//	@Summary	Lend a book
+//	@Schemes
+//	@Description	Records that the owner of a record lent the book to another user or to someone named in borrowerName. The lender's record becomes lent; a borrowing user gets the book in their library as borrowed, reusing their record of an earlier loan of it. Users who already have the book in their library cannot borrow it.
+//	@Tags			loans
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			loan	body	model.CreateLoan	true	"Lent record, borrower and dates"
+//	@Success		201	{object} Response{data=LoanResponse} "Book lent successfully"
+//	@Failure		400	{object} Response "Invalid request body"
+//	@Failure		404	{object} Response "Record or borrower not found"
+//	@Failure		409	{object} Response "Book is not on the lender's shelf or already in the borrower's library"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/loans [post]
+func (handler *LoanHandler) CreateLoan(c *gin.Context) {
+	var createLoan model.CreateLoan
+	if err := c.ShouldBindJSON(&createLoan); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+	if createLoan.BorrowerID == nil && createLoan.BorrowerName == "" {
+		c.Error(newError(service.ErrValidation, CodeBorrowerRequired))
+		return
+	}
+
+	lentAt := time.Now()
+	if createLoan.LentAt != nil {
+		lentAt = *createLoan.LentAt
+	}
+	if createLoan.DueAt != nil && createLoan.DueAt.Before(lentAt) {
+		c.Error(newError(service.ErrValidation, CodeInvalidDueDate))
+		return
+	}
+
+	var record model.Record
+	if err := handler.db.Preload("User").First(&record, createLoan.RecordID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(recordNotFound())
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+	if record.Ownership != model.OwnershipOwned {
+		c.Error(newError(service.ErrConflict, CodeNotOnShelf))
+		return
+	}
+
+	loan := model.Loan{
+		RecordID:     record.ID,
+		LenderID:     record.UserID,
+		LenderName:   record.User.Username,
+		BorrowerName: createLoan.BorrowerName,
+		ISBN:         record.ISBN,
+		Title:        record.Title,
+		LentAt:       lentAt,
+		DueAt:        createLoan.DueAt,
+		Notes:        createLoan.Notes,
+	}
+
+	var borrower model.User
+	if createLoan.BorrowerID != nil {
+		if *createLoan.BorrowerID == record.UserID {
+			c.Error(newError(service.ErrValidation, CodeSelfLoan))
+			return
+		}
+		if err := handler.db.First(&borrower, *createLoan.BorrowerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.Error(newError(service.ErrNotFound, CodeBorrowerNotFound))
+				return
+			}
+
+			c.Error(err)
+			return
+		}
+		loan.BorrowerID = &borrower.ID
+		loan.BorrowerName = borrower.Username
+	}
+
+	err := handler.db.Transaction(func(tx *gorm.DB) error {
+		// Guard against a concurrent loan of the same record
+		result := tx.Model(&record).Where("ownership = ?", model.OwnershipOwned).Updates(map[string]interface{}{"ownership": model.OwnershipLent, "version": repository.NextVersion()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyLent
+		}
+
+		if loan.BorrowerID != nil {
+			var borrowed model.Record
+			if err := tx.Where("user_id = ? AND isbn = ?", borrower.ID, record.ISBN).Limit(1).Find(&borrowed).Error; err != nil {
+				return err
+			}
+			if borrowed.ID != 0 && borrowed.Ownership != model.OwnershipReturned {
+				return errBorrowerHasBook
+			}
+			if borrowed.ID == 0 {
+				borrowed = model.Record{
+					UserID:     borrower.ID,
+					ISBN:       record.ISBN,
+					Title:      record.Title,
+					Author:     record.Author,
+					Cover:      record.Cover,
+					Genre:      record.Genre,
+					TotalPages: record.TotalPages,
+					Ownership:  model.OwnershipBorrowed,
+					DateAdded:  lentAt,
+				}
+				if err := tx.Create(&borrowed).Error; err != nil {
+					return err
+				}
+			} else if err := tx.Model(&borrowed).Updates(map[string]interface{}{"ownership": model.OwnershipBorrowed, "version": repository.NextVersion()}).Error; err != nil {
+				return err
+			}
+			loan.BorrowerRecordID = &borrowed.ID
+		}
+
+		return tx.Create(&loan).Error
+	})
+	if errors.Is(err, errAlreadyLent) {
+		c.Error(newError(service.ErrConflict, CodeNotOnShelf))
+		return
+	}
+	if errors.Is(err, errBorrowerHasBook) {
+		c.Error(newError(service.ErrConflict, CodeBorrowerHasBook))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, Response{
+		Success: true,
+		Data:    LoanResponse{Loan: loan, Overdue: loan.Overdue(time.Now())},
+		Message: "Book lent successfully",
+	})
+}
+
+var (
+	// errAlreadyLent aborts a loan of a record that is no longer on the shelf
+	errAlreadyLent = errors.New("record is already lent")
+	// errBorrowerHasBook aborts a loan to a user whose own record of the book
+	// would be overwritten
+	errBorrowerHasBook = errors.New("borrower already has the book")
+	// errLoanReturned aborts a return that a concurrent request already made
+	errLoanReturned = errors.New("loan is already returned")
+)
+
+// ReturnLoan godoc
+//
+//	@Summary	Return a lent book
+//	@Schemes
+//	@Description	Closes a loan. The lender's record is back on their shelf and the borrower's record is marked returned.
+//	@Tags			loans
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int					true	"Loan ID"
+//	@Param			return	body	model.ReturnLoan	false	"Return date, defaults to now"
+//	@Success		200	{object} Response{data=LoanResponse} "Book returned successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Loan not found"
+//	@Failure		409	{object} Response "Loan already returned"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/loans/{id}/return [post]
+func (handler *LoanHandler) ReturnLoan(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidLoanID", nil))
+		return
+	}
+
+	var returnLoan model.ReturnLoan
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&returnLoan); err != nil {
+			c.Error(service.InvalidInput(err))
+			return
+		}
+	}
+
+	var loan model.Loan
+	if err := handler.db.First(&loan, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeLoanNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+	if loan.ReturnedAt != nil {
+		c.Error(newError(service.ErrConflict, CodeLoanReturned))
+		return
+	}
+
+	returnedAt := time.Now()
+	if returnLoan.ReturnedAt != nil {
+		returnedAt = *returnLoan.ReturnedAt
+	}
+	if returnedAt.Before(loan.LentAt) {
+		c.Error(newError(service.ErrValidation, CodeInvalidReturnDate))
+		return
+	}
+	loan.ReturnedAt = &returnedAt
+
+	err = handler.db.Transaction(func(tx *gorm.DB) error {
+		// Guard against a concurrent return of the same loan
+		result := tx.Model(&loan).Where("returned_at IS NULL").Update("returned_at", returnedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errLoanReturned
+		}
+		if err := tx.Model(&model.Record{ID: loan.RecordID}).Updates(map[string]interface{}{"ownership": model.OwnershipOwned, "version": repository.NextVersion()}).Error; err != nil {
+			return err
+		}
+		if loan.BorrowerRecordID == nil {
+			return nil
+		}
+		return tx.Model(&model.Record{ID: *loan.BorrowerRecordID}).Updates(map[string]interface{}{"ownership": model.OwnershipReturned, "version": repository.NextVersion()}).Error
+	})
+	if errors.Is(err, errLoanReturned) {
+		c.Error(newError(service.ErrConflict, CodeLoanReturned))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    LoanResponse{Loan: loan},
+		Message: "Book returned successfully",
+	})
+}
+
+// GetLoans godoc
+//
+//	@Summary	List loans
+//	@Schemes
+//	@Description	Returns the loans a user is part of as lender or borrower, most recent first
+//	@Tags			loans
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			userId	query	int		true	"User ID"
+//	@Param			role	query	string	false	"Only loans where the user is the lender or the borrower"	Enums(lender, borrower)
+//	@Param			active	query	bool	false	"Only loans that have not been returned"
+//	@Success		200	{object} Response{data=[]LoanResponse} "Successfully retrieved loans"
+//	@Failure		400	{object} Response "Invalid request parameters"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/loans [get]
+func (handler *LoanHandler) GetLoans(c *gin.Context) {
+	query, ok := loanQuery(c, handler.db)
+	if !ok {
+		return
+	}
+	if c.Query("active") == "true" {
+		query = query.Where("returned_at IS NULL")
+	}
+	handler.listLoans(c, query.Order("lent_at DESC, id DESC"), "Loans retrieved successfully")
+}
+
+// GetOverdueLoans godoc
+//
+//	@Summary	List overdue loans
+//	@Schemes
+//	@Description	Returns the user's loans that are still out after their due date, the longest overdue first
+//	@Tags			loans
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			userId	query	int		true	"User ID"
+//	@Param			role	query	string	false	"Only loans where the user is the lender or the borrower"	Enums(lender, borrower)
+//	@Success		200	{object} Response{data=[]LoanResponse} "Successfully retrieved overdue loans"
+//	@Failure		400	{object} Response "Invalid request parameters"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/loans/overdue [get]
+func (handler *LoanHandler) GetOverdueLoans(c *gin.Context) {
+	query, ok := loanQuery(c, handler.db)
+	if !ok {
+		return
+	}
+	query = query.Where("returned_at IS NULL AND due_at < ?", time.Now())
+	handler.listLoans(c, query.Order("due_at, id"), "Overdue loans retrieved successfully")
+}
+
+// loanQuery limits loans to the userId and role parameters, writing a response if
+// they are invalid
+func loanQuery(c *gin.Context, db *gorm.DB) (*gorm.DB, bool) {
+	userId, err := strconv.ParseUint(c.Query("userId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return nil, false
+	}
+
+	switch c.Query("role") {
+	case "":
+		return db.Where("lender_id = ? OR borrower_id = ?", userId, userId), true
+	case "lender":
+		return db.Where("lender_id = ?", userId), true
+	case "borrower":
+		return db.Where("borrower_id = ?", userId), true
+	default:
+		c.Error(invalidParameter("param.invalidLoanRole", nil))
+		return nil, false
+	}
+}
+
+func (handler *LoanHandler) listLoans(c *gin.Context, query *gorm.DB, message string) {
+	var loans []model.Loan
+	if err := query.Find(&loans).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    toLoanResponses(loans),
+		Message: message,
+	})
+}
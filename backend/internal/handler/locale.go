@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"biblia-be/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// localeKey stores the negotiated locale in the gin context
+const localeKey = "locale"
+
+// Localize negotiates the language of the response from the Accept-Language
+// header and announces it in Content-Language
+func Localize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := i18n.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set(localeKey, locale)
+		c.Header("Content-Language", locale)
+		c.Header("Vary", "Accept-Language")
+		c.Next()
+	}
+}
+
+// locale returns the language of the current response
+func locale(c *gin.Context) string {
+	if locale := c.GetString(localeKey); locale != "" {
+		return locale
+	}
+	return i18n.Negotiate(c.GetHeader("Accept-Language"))
+}
+
+// translate returns a catalog message in the language of the current response
+func translate(c *gin.Context, key string) string {
+	return i18n.T(locale(c), key, nil)
+}
@@ -0,0 +1,345 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"biblia-be/internal/opds"
+	"biblia-be/internal/storage"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// opdsRoot is the path of the catalog's start feed
+const opdsRoot = "/opds"
+
+// OPDSHandler serves a user's library as an OPDS 1.2 catalog for e-reader apps.
+// Every route must be wrapped in APIKeyHandler.RequireAPIKey.
+type OPDSHandler struct {
+	db    *gorm.DB
+	store storage.BlobStore
+}
+
+// Initialize sets up the handler with a database connection and the blob store holding EPUBs
+func (handler *OPDSHandler) Initialize(db *gorm.DB, store storage.BlobStore) {
+	handler.db = db
+	handler.store = store
+}
+
+// writeFeed renders a feed with the given OPDS media type
+func writeFeed(c *gin.Context, feed *opds.Feed, mediaType string) {
+	body, err := feed.Marshal()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, mediaType+";charset=utf-8", body)
+}
+
+// navigationEntry links to a subsection of the catalog
+func navigationEntry(id, title, summary, href string, updated time.Time) opds.Entry {
+	return opds.Entry{
+		ID:      id,
+		Title:   title,
+		Updated: updated.UTC(),
+		Content: &opds.Content{Type: "text", Text: summary},
+		Links: []opds.Link{{
+			Rel:  opds.RelSubsection,
+			Href: href,
+			Type: opds.AcquisitionType,
+		}},
+	}
+}
+
+// statusLabel turns a status string into a shelf title
+func statusLabel(status string) string {
+	words := strings.Fields(strings.NewReplacer("-", " ", "_", " ").Replace(status))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// Catalog godoc
+//
+//	@Summary	OPDS catalog root
+//	@Schemes
+//	@Description	Navigation feed listing the user's library by status and by shelf. Authenticate with an API key.
+//	@Tags			opds
+//	@Produce		application/atom+xml
+//	@Security		ApiKeyAuth
+//	@Success		200	{string}	string	"OPDS navigation feed"
+//	@Failure		401	{object}	Response	"Missing or invalid API key"
+//	@Router			/opds [get]
+func (handler *OPDSHandler) Catalog(c *gin.Context) {
+	userId := authUserID(c)
+
+	var records []model.Record
+	if err := handler.db.Select("status", "shelves", "date_added").
+		Where("user_id = ?", userId).Find(&records).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	updated := time.Now()
+	statuses := map[string]int{}
+	shelves := map[string]int{}
+	for _, record := range records {
+		if record.Status != "" {
+			statuses[record.Status]++
+		}
+		for _, shelf := range record.Shelves {
+			shelves[shelf]++
+		}
+	}
+
+	feed := opds.NewFeed(fmt.Sprintf("urn:biblia:user:%d", userId), "Biblia Library", updated)
+	feed.Links = []opds.Link{
+		{Rel: opds.RelSelf, Href: opdsRoot, Type: opds.NavigationType},
+		{Rel: opds.RelStart, Href: opdsRoot, Type: opds.NavigationType},
+	}
+
+	feed.Entries = append(feed.Entries, navigationEntry(
+		fmt.Sprintf("urn:biblia:user:%d:all", userId),
+		"All Books",
+		fmt.Sprintf("%d books", len(records)),
+		opdsRoot+"/all",
+		updated,
+	))
+
+	for _, status := range sortedKeys(statuses) {
+		feed.Entries = append(feed.Entries, navigationEntry(
+			fmt.Sprintf("urn:biblia:user:%d:status:%s", userId, url.PathEscape(status)),
+			statusLabel(status),
+			fmt.Sprintf("%d books", statuses[status]),
+			opdsRoot+"/status/"+url.PathEscape(status),
+			updated,
+		))
+	}
+
+	for _, shelf := range sortedKeys(shelves) {
+		feed.Entries = append(feed.Entries, navigationEntry(
+			fmt.Sprintf("urn:biblia:user:%d:shelf:%s", userId, url.PathEscape(shelf)),
+			shelf,
+			fmt.Sprintf("%d books", shelves[shelf]),
+			opdsRoot+"/shelves/"+url.PathEscape(shelf),
+			updated,
+		))
+	}
+
+	writeFeed(c, feed, opds.NavigationType)
+}
+
+// AllBooks godoc
+//
+//	@Summary	OPDS feed of all books
+//	@Schemes
+//	@Description	Acquisition feed of every record in the user's library
+//	@Tags			opds
+//	@Produce		application/atom+xml
+//	@Security		ApiKeyAuth
+//	@Success		200	{string}	string	"OPDS acquisition feed"
+//	@Failure		401	{object}	Response	"Missing or invalid API key"
+//	@Router			/opds/all [get]
+func (handler *OPDSHandler) AllBooks(c *gin.Context) {
+	userId := authUserID(c)
+	handler.acquisitionFeed(c,
+		handler.db.Where("user_id = ?", userId),
+		fmt.Sprintf("urn:biblia:user:%d:all", userId),
+		"All Books",
+		opdsRoot+"/all",
+	)
+}
+
+// BooksByStatus godoc
+//
+//	@Summary	OPDS feed of books with a status
+//	@Schemes
+//	@Description	Acquisition feed of the user's records with the given reading status
+//	@Tags			opds
+//	@Produce		application/atom+xml
+//	@Security		ApiKeyAuth
+//	@Param			status	path	string	true	"Reading status"
+//	@Success		200	{string}	string	"OPDS acquisition feed"
+//	@Failure		401	{object}	Response	"Missing or invalid API key"
+//	@Router			/opds/status/{status} [get]
+func (handler *OPDSHandler) BooksByStatus(c *gin.Context) {
+	userId := authUserID(c)
+	status := c.Param("status")
+	handler.acquisitionFeed(c,
+		handler.db.Where("user_id = ? AND status = ?", userId, status),
+		fmt.Sprintf("urn:biblia:user:%d:status:%s", userId, url.PathEscape(status)),
+		statusLabel(status),
+		opdsRoot+"/status/"+url.PathEscape(status),
+	)
+}
+
+// BooksByShelf godoc
+//
+//	@Summary	OPDS feed of a shelf
+//	@Schemes
+//	@Description	Acquisition feed of the user's records on the given shelf
+//	@Tags			opds
+//	@Produce		application/atom+xml
+//	@Security		ApiKeyAuth
+//	@Param			shelf	path	string	true	"Shelf name"
+//	@Success		200	{string}	string	"OPDS acquisition feed"
+//	@Failure		401	{object}	Response	"Missing or invalid API key"
+//	@Router			/opds/shelves/{shelf} [get]
+func (handler *OPDSHandler) BooksByShelf(c *gin.Context) {
+	userId := authUserID(c)
+	shelf := c.Param("shelf")
+
+	// Shelves are stored as a JSON array, so filter them after loading
+	var records []model.Record
+	if err := handler.db.Where("user_id = ?", userId).Order("date_added DESC").Find(&records).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	onShelf := records[:0]
+	for _, record := range records {
+		for _, s := range record.Shelves {
+			if s == shelf {
+				onShelf = append(onShelf, record)
+				break
+			}
+		}
+	}
+
+	handler.writeAcquisitionFeed(c, onShelf,
+		fmt.Sprintf("urn:biblia:user:%d:shelf:%s", userId, url.PathEscape(shelf)),
+		shelf,
+		opdsRoot+"/shelves/"+url.PathEscape(shelf),
+	)
+}
+
+// DownloadBook godoc
+//
+//	@Summary	Download a book through OPDS
+//	@Schemes
+//	@Description	Streams the EPUB of one of the authenticated user's records
+//	@Tags			opds
+//	@Produce		application/epub+zip
+//	@Security		ApiKeyAuth
+//	@Param			id	path	int	true	"Record ID"
+//	@Success		200	{file}	binary	"EPUB file"
+//	@Failure		401	{object}	Response	"Missing or invalid API key"
+//	@Failure		404	{object}	Response	"Record or EPUB not found"
+//	@Router			/opds/books/{id}/epub [get]
+func (handler *OPDSHandler) DownloadBook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidRecordID", nil))
+		return
+	}
+
+	var record model.Record
+	if err := handler.db.Where("id = ? AND user_id = ?", id, authUserID(c)).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(recordNotFound())
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	serveEbook(c, handler.store, record)
+}
+
+// acquisitionFeed renders the records matched by query as an acquisition feed
+func (handler *OPDSHandler) acquisitionFeed(c *gin.Context, query *gorm.DB, id, title, self string) {
+	var records []model.Record
+	if err := query.Order("date_added DESC").Find(&records).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	handler.writeAcquisitionFeed(c, records, id, title, self)
+}
+
+func (handler *OPDSHandler) writeAcquisitionFeed(c *gin.Context, records []model.Record, id, title, self string) {
+	updated := time.Now()
+	if len(records) > 0 {
+		updated = records[0].DateAdded
+	}
+
+	feed := opds.NewFeed(id, title, updated)
+	feed.Links = []opds.Link{
+		{Rel: opds.RelSelf, Href: self, Type: opds.AcquisitionType},
+		{Rel: opds.RelStart, Href: opdsRoot, Type: opds.NavigationType},
+		{Rel: opds.RelUp, Href: opdsRoot, Type: opds.NavigationType},
+	}
+
+	for _, record := range records {
+		feed.Entries = append(feed.Entries, bookEntry(record))
+	}
+
+	writeFeed(c, feed, opds.AcquisitionType)
+}
+
+// bookEntry describes a record as an OPDS publication with cover and download links
+func bookEntry(record model.Record) opds.Entry {
+	entry := opds.Entry{
+		ID:         "urn:isbn:" + record.ISBN,
+		Title:      record.Title,
+		Updated:    record.DateAdded.UTC(),
+		Identifier: "urn:isbn:" + record.ISBN,
+	}
+
+	for _, author := range strings.Split(record.Author, ",") {
+		if author = strings.TrimSpace(author); author != "" {
+			entry.Authors = append(entry.Authors, opds.Author{Name: author})
+		}
+	}
+	if record.Genre != "" {
+		entry.Categories = append(entry.Categories, opds.Category{Term: record.Genre, Label: record.Genre})
+	}
+
+	summary := statusLabel(record.Status)
+	if record.TotalPages > 0 {
+		summary = fmt.Sprintf("%s · page %d of %d", summary, record.CurrentPage, record.TotalPages)
+	}
+	entry.Summary = strings.TrimPrefix(summary, " · ")
+
+	if record.Cover != "" {
+		thumbnail := coverThumbnailURL(record.Cover, thumbnailWidths[0])
+		entry.Links = append(entry.Links,
+			opds.Link{Rel: opds.RelImage, Href: record.Cover, Type: coverMediaType(record.Cover)},
+			opds.Link{Rel: opds.RelThumbnail, Href: thumbnail, Type: coverMediaType(thumbnail)},
+		)
+	}
+	if record.EbookKey != "" {
+		entry.Links = append(entry.Links, opds.Link{
+			Rel:  opds.RelAcquisition,
+			Href: fmt.Sprintf("%s/books/%d/epub", opdsRoot, record.ID),
+			Type: "application/epub+zip",
+		})
+	}
+
+	return entry
+}
+
+// coverMediaType guesses the image type of a cover URL from its extension
+func coverMediaType(cover string) string {
+	if strings.HasSuffix(strings.ToLower(cover), ".png") {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// sortedKeys returns the keys of a count map in alphabetical order
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
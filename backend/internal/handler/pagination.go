@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/repository"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Page size limits for list endpoints
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// errInvalidCursor is returned for cursors that were not issued for the current sort
+var errInvalidCursor = invalidParameter("param.invalidCursor", nil)
+
+// Meta carries pagination details of a list response
+type Meta struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total is only computed for the first page, where it is cheap enough to count
+	Total *int64 `json:"total,omitempty"`
+	// Facets holds value counts for list endpoints that support them
+	Facets map[string][]repository.FacetCount `json:"facets,omitempty"`
+}
+
+// cursor marks the position after the last item of a page: the sort value and ID
+// of that item, which together form a unique keyset position
+type cursor struct {
+	Sort  string          `json:"s"`
+	Desc  bool            `json:"d"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// sortSpec describes a sortable field of T
+type sortSpec[T any] struct {
+	// expr is the SQL column or expression to order by. Sorts of lists read
+	// through a repository leave it empty, the repository knows its columns.
+	expr string
+	// desc is the default direction
+	desc bool
+	// value extracts the sort value of an item for the next cursor
+	value func(T) interface{}
+	// parse converts a cursor value back into a query argument
+	parse func(json.RawMessage) (interface{}, error)
+	// id returns the unique tiebreaker of an item
+	id func(T) uint
+}
+
+// pageRequest is a parsed limit, sort and cursor
+type pageRequest[T any] struct {
+	limit    int
+	sortName string
+	sort     sortSpec[T]
+	desc     bool
+	after    *cursor
+	value    interface{}
+}
+
+// parsePageRequest reads limit, sort, order and cursor query parameters
+func parsePageRequest[T any](c *gin.Context, sorts map[string]sortSpec[T], defaultSort string) (pageRequest[T], error) {
+	req := pageRequest[T]{limit: defaultPageLimit, sortName: c.DefaultQuery("sort", defaultSort)}
+
+	if limitParam, ok := c.GetQuery("limit"); ok {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return req, invalidParameter("param.invalidLimit", i18n.Params{"max": strconv.Itoa(maxPageLimit)})
+		}
+		req.limit = limit
+	}
+
+	spec, ok := sorts[req.sortName]
+	if !ok {
+		return req, invalidParameter("param.unsupportedSort", i18n.Params{"sort": req.sortName})
+	}
+	req.sort = spec
+	req.desc = spec.desc
+
+	switch c.Query("order") {
+	case "":
+	case "asc":
+		req.desc = false
+	case "desc":
+		req.desc = true
+	default:
+		return req, invalidParameter("param.invalidOrder", nil)
+	}
+
+	if cursorParam := c.Query("cursor"); cursorParam != "" {
+		data, err := base64.RawURLEncoding.DecodeString(cursorParam)
+		if err != nil {
+			return req, errInvalidCursor
+		}
+		var after cursor
+		if err := json.Unmarshal(data, &after); err != nil {
+			return req, errInvalidCursor
+		}
+		// A cursor is only meaningful for the ordering it was issued for
+		if after.Sort != req.sortName || after.Desc != req.desc {
+			return req, errInvalidCursor
+		}
+		value, err := spec.parse(after.Value)
+		if err != nil {
+			return req, errInvalidCursor
+		}
+		req.after = &after
+		req.value = value
+	}
+
+	return req, nil
+}
+
+// page returns the request as a repository page. One extra row is fetched to
+// detect whether another page follows.
+func (req pageRequest[T]) page() repository.Page {
+	page := repository.Page{Sort: req.sortName, Desc: req.desc, Limit: req.limit + 1}
+	if req.after != nil {
+		page.After = &repository.Cursor{Value: req.value, ID: req.after.ID}
+	}
+	return page
+}
+
+// apply adds keyset conditions, ordering and the limit to a query
+func (req pageRequest[T]) apply(query *gorm.DB) *gorm.DB {
+	return repository.ApplyPage(query, req.sort.expr, req.page())
+}
+
+// trim drops the extra row fetched for the page and returns the cursor of the next page
+func (req pageRequest[T]) trim(items []T) ([]T, string) {
+	if len(items) <= req.limit {
+		return items, ""
+	}
+	items = items[:req.limit]
+	last := items[len(items)-1]
+
+	value, _ := json.Marshal(req.sort.value(last))
+	data, _ := json.Marshal(cursor{Sort: req.sortName, Desc: req.desc, Value: value, ID: req.sort.id(last)})
+	return items, base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseStringCursor decodes a string sort value
+func parseStringCursor(raw json.RawMessage) (interface{}, error) {
+	var s string
+	err := json.Unmarshal(raw, &s)
+	return s, err
+}
+
+// parseNumberCursor decodes a numeric sort value
+func parseNumberCursor(raw json.RawMessage) (interface{}, error) {
+	var f float64
+	err := json.Unmarshal(raw, &f)
+	return f, err
+}
+
+// parseTimeCursor decodes a timestamp sort value
+func parseTimeCursor(raw json.RawMessage) (interface{}, error) {
+	var t time.Time
+	err := json.Unmarshal(raw, &t)
+	return t, err
+}
+
+// parseDateParam parses a YYYY-MM-DD date or an RFC 3339 timestamp. With endOfDay,
+// a plain date is moved to the start of the following day so it can be used as an
+// exclusive upper bound covering the whole day.
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return t, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,433 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"biblia-be/internal/rank"
+	"biblia-be/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// QueueHandler manages users' to-be-read queues
+type QueueHandler struct {
+	db *gorm.DB
+}
+
+// Initialize sets up the handler with a database connection
+func (handler *QueueHandler) Initialize(db *gorm.DB) {
+	handler.db = db
+}
+
+// dequeueRecord takes a book off the user's queue once they start or finish it
+func dequeueRecord(tx *gorm.DB, _ *model.Record, record model.Record) error {
+	if record.Status != model.StatusReading && record.Status != model.StatusFinished {
+		return nil
+	}
+	return tx.Where("user_id = ? AND isbn = ?", record.UserID, record.ISBN).Delete(&model.QueueItem{}).Error
+}
+
+// placeQueueItem gives item a position right after the given item, or at the top
+// of the queue when after is nil. The queue is spread out again in the rare case
+// the new key gets too long.
+func placeQueueItem(tx *gorm.DB, item *model.QueueItem, after *model.QueueItem) error {
+	lower := ""
+	next := tx.Where("user_id = ? AND id <> ?", item.UserID, item.ID)
+	if after != nil {
+		lower = after.Position
+		next = next.Where("position > ?", after.Position)
+	}
+
+	var upper model.QueueItem
+	if err := next.Order("position").Limit(1).Find(&upper).Error; err != nil {
+		return err
+	}
+
+	position, err := rank.Between(lower, upper.Position)
+	if err != nil {
+		return err
+	}
+	if len(position) <= rank.MaxLength {
+		item.Position = position
+		return nil
+	}
+
+	var queue []model.QueueItem
+	if err := tx.Select("id").Where("user_id = ? AND id <> ?", item.UserID, item.ID).
+		Order("position").Find(&queue).Error; err != nil {
+		return err
+	}
+	index := 0
+	if after != nil {
+		for i, other := range queue {
+			if other.ID == after.ID {
+				index = i + 1
+			}
+		}
+	}
+	positions := rank.Sequence(len(queue) + 1)
+	item.Position = positions[index]
+	for i, other := range queue {
+		if i >= index {
+			i++
+		}
+		if err := tx.Model(&other).Update("position", positions[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseQueueItemPath reads the user and item IDs of a queue item path, writing a
+// response if they are invalid
+func parseQueueItemPath(c *gin.Context) (uint, uint, bool) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return 0, 0, false
+	}
+	itemId, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidQueueItemID", nil))
+		return 0, 0, false
+	}
+	return uint(userId), uint(itemId), true
+}
+
+// loadQueueItem fetches an item of the user's queue, writing a response if it cannot
+func (handler *QueueHandler) loadQueueItem(c *gin.Context, userId, itemId uint) (model.QueueItem, bool) {
+	var item model.QueueItem
+	if err := handler.db.Where("id = ? AND user_id = ?", itemId, userId).First(&item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeQueueItemNotFound))
+			return item, false
+		}
+
+		c.Error(err)
+		return item, false
+	}
+	return item, true
+}
+
+// GetQueue godoc
+//
+//	@Summary	Get to-be-read queue
+//	@Schemes
+//	@Description	Returns the books on the user's to-be-read queue in queue order
+//	@Tags			queue
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id	path	int	true	"User ID"
+//	@Success		200	{object} Response{data=[]model.QueueItem} "Successfully retrieved queue"
+//	@Failure		400	{object} Response "Invalid user ID"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/queue [get]
+func (handler *QueueHandler) GetQueue(c *gin.Context) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	queue := []model.QueueItem{}
+	if err := handler.db.Where("user_id = ?", userId).Order("position").Find(&queue).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    queue,
+		Message: "Queue retrieved successfully",
+	})
+}
+
+// GetNextUp godoc
+//
+//	@Summary	Get the next book to read
+//	@Schemes
+//	@Description	Returns the book to read next: the most urgent item on the queue, the first in queue order among equally urgent ones
+//	@Tags			queue
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id	path	int	true	"User ID"
+//	@Success		200	{object} Response{data=model.QueueItem} "Successfully retrieved next book"
+//	@Failure		400	{object} Response "Invalid user ID"
+//	@Failure		404	{object} Response "Queue is empty"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/queue/next [get]
+func (handler *QueueHandler) GetNextUp(c *gin.Context) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	var item model.QueueItem
+	if err := handler.db.Where("user_id = ?", userId).Order("priority DESC, position").First(&item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeQueueEmpty))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    item,
+		Message: "Next book retrieved successfully",
+	})
+}
+
+// AddToQueue godoc
+//
+//	@Summary	Add a book to the to-be-read queue
+//	@Schemes
+//	@Description	Adds a book at the end of the user's queue. Priority is 1 (low), 2 (normal, the default) or 3 (high). Books leave the queue when the user starts or finishes reading them.
+//	@Tags			queue
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int						true	"User ID"
+//	@Param			item	body	model.CreateQueueItem	true	"Book, priority and why it was added"
+//	@Success		201	{object} Response{data=model.QueueItem} "Book added to queue successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "User not found"
+//	@Failure		409	{object} Response "Book is already queued"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/queue [post]
+func (handler *QueueHandler) AddToQueue(c *gin.Context) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	var createItem model.CreateQueueItem
+	if err := c.ShouldBindJSON(&createItem); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+	if createItem.Priority == 0 {
+		createItem.Priority = model.PriorityNormal
+	}
+
+	var user model.User
+	if err := handler.db.First(&user, userId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, service.CodeUserNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	var existing int64
+	if err := handler.db.Model(&model.QueueItem{}).Where("user_id = ? AND isbn = ?", userId, createItem.ISBN).Count(&existing).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	if existing > 0 {
+		c.Error(newError(service.ErrConflict, CodeAlreadyQueued))
+		return
+	}
+
+	item := model.QueueItem{
+		UserID:   user.ID,
+		ISBN:     createItem.ISBN,
+		Title:    createItem.Title,
+		Author:   createItem.Author,
+		Cover:    createItem.Cover,
+		Genre:    createItem.Genre,
+		Priority: createItem.Priority,
+		Reason:   createItem.Reason,
+		AddedAt:  time.Now(),
+	}
+	err = handler.db.Transaction(func(tx *gorm.DB) error {
+		var last model.QueueItem
+		if err := tx.Where("user_id = ?", user.ID).Order("position DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		after := &last
+		if last.ID == 0 {
+			after = nil
+		}
+		if err := placeQueueItem(tx, &item, after); err != nil {
+			return err
+		}
+		return tx.Create(&item).Error
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, Response{
+		Success: true,
+		Data:    item,
+		Message: "Book added to queue successfully",
+	})
+}
+
+// UpdateQueueItem godoc
+//
+//	@Summary	Update a queued book
+//	@Schemes
+//	@Description	Changes the priority of a queued book or the note on why it was added
+//	@Tags			queue
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int						true	"User ID"
+//	@Param			itemId	path	int						true	"Queue item ID"
+//	@Param			item	body	model.UpdateQueueItem	true	"Fields to change"
+//	@Success		200	{object} Response{data=model.QueueItem} "Queue item updated successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Queue item not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/queue/{itemId} [put]
+func (handler *QueueHandler) UpdateQueueItem(c *gin.Context) {
+	userId, itemId, ok := parseQueueItemPath(c)
+	if !ok {
+		return
+	}
+
+	var updateItem model.UpdateQueueItem
+	if err := c.ShouldBindJSON(&updateItem); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+
+	item, ok := handler.loadQueueItem(c, userId, itemId)
+	if !ok {
+		return
+	}
+	if updateItem.Priority != nil {
+		item.Priority = *updateItem.Priority
+	}
+	if updateItem.Reason != nil {
+		item.Reason = *updateItem.Reason
+	}
+
+	if err := handler.db.Save(&item).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    item,
+		Message: "Queue item updated successfully",
+	})
+}
+
+// MoveQueueItem godoc
+//
+//	@Summary	Reorder the to-be-read queue
+//	@Schemes
+//	@Description	Moves a queued book right after the item given as afterID, or to the top of the queue when afterID is left out. Only the moved item changes position.
+//	@Tags			queue
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int					true	"User ID"
+//	@Param			itemId	path	int					true	"Queue item ID"
+//	@Param			move	body	model.MoveQueueItem	true	"Item to place the book after"
+//	@Success		200	{object} Response{data=model.QueueItem} "Queue item moved successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Queue item not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/queue/{itemId}/position [put]
+func (handler *QueueHandler) MoveQueueItem(c *gin.Context) {
+	userId, itemId, ok := parseQueueItemPath(c)
+	if !ok {
+		return
+	}
+
+	var moveItem model.MoveQueueItem
+	if err := c.ShouldBindJSON(&moveItem); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+	if moveItem.AfterID != nil && *moveItem.AfterID == itemId {
+		c.Error(newError(service.ErrValidation, CodeInvalidQueueMove))
+		return
+	}
+
+	item, ok := handler.loadQueueItem(c, userId, itemId)
+	if !ok {
+		return
+	}
+	var after *model.QueueItem
+	if moveItem.AfterID != nil {
+		afterItem, ok := handler.loadQueueItem(c, userId, *moveItem.AfterID)
+		if !ok {
+			return
+		}
+		after = &afterItem
+	}
+
+	err := handler.db.Transaction(func(tx *gorm.DB) error {
+		if err := placeQueueItem(tx, &item, after); err != nil {
+			return err
+		}
+		return tx.Model(&item).Update("position", item.Position).Error
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    item,
+		Message: "Queue item moved successfully",
+	})
+}
+
+// RemoveFromQueue godoc
+//
+//	@Summary	Remove a book from the to-be-read queue
+//	@Schemes
+//	@Description	Removes a book from the user's queue
+//	@Tags			queue
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int	true	"User ID"
+//	@Param			itemId	path	int	true	"Queue item ID"
+//	@Success		200	{object} Response "Book removed from queue successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Queue item not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/queue/{itemId} [delete]
+func (handler *QueueHandler) RemoveFromQueue(c *gin.Context) {
+	userId, itemId, ok := parseQueueItemPath(c)
+	if !ok {
+		return
+	}
+
+	result := handler.db.Where("id = ? AND user_id = ?", itemId, userId).Delete(&model.QueueItem{})
+	if result.Error != nil {
+		c.Error(result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(newError(service.ErrNotFound, CodeQueueItemNotFound))
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: "Book removed from queue successfully",
+	})
+}
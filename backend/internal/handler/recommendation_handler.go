@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/model"
+	"biblia-be/internal/recommend"
+	"biblia-be/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Limits for GET /users/:id/recommendations
+const (
+	defaultRecommendationLimit = 20
+	maxRecommendationLimit     = 100
+	// genreCandidateLimit caps the books loaded per request for genre matches
+	genreCandidateLimit = 1000
+)
+
+// RecommendationHandler suggests books from the shared catalog
+type RecommendationHandler struct {
+	db *gorm.DB
+}
+
+// Initialize sets up the handler with a database connection
+func (handler *RecommendationHandler) Initialize(db *gorm.DB) {
+	handler.db = db
+}
+
+// RecommendationResponse is a suggested book with the reasons it was picked
+type RecommendationResponse struct {
+	model.CatalogBook
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// GetRecommendations godoc
+//
+//	@Summary	Get book recommendations
+//	@Schemes
+//	@Description	Ranks books from other users' libraries that the user does not have yet, by similarity to the books the user has read (users who read one also read the other) and by affinity with the user's favorite and most read genres
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int	true	"User ID"
+//	@Param			limit	query	int	false	"Maximum number of books (1-100, default 20)"
+//	@Success		200	{object} Response{data=[]RecommendationResponse} "Successfully retrieved recommendations"
+//	@Failure		400	{object} Response "Invalid request parameters"
+//	@Failure		404	{object} Response "User not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/recommendations [get]
+func (handler *RecommendationHandler) GetRecommendations(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	limit := defaultRecommendationLimit
+	if limitParam, ok := c.GetQuery("limit"); ok {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxRecommendationLimit {
+			c.Error(invalidParameter("param.invalidLimit", i18n.Params{"max": strconv.Itoa(maxRecommendationLimit)}))
+			return
+		}
+	}
+
+	var user model.User
+	if err := handler.db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, service.CodeUserNotFound))
+			return
+		}
+
+		c.Error(err)
+		return
+	}
+
+	input, err := handler.loadInput(user)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	recommendations := []RecommendationResponse{}
+	for _, recommendation := range recommend.Recommend(input, limit) {
+		recommendations = append(recommendations, RecommendationResponse{
+			CatalogBook: catalogBook(recommendation.Book),
+			Score:       recommendation.Score,
+			Reasons:     recommendation.Reasons,
+		})
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    recommendations,
+		Message: "Recommendations retrieved successfully",
+	})
+}
+
+// loadInput gathers the user's library, the libraries of users sharing a book with
+// them, books in the genres they like and reader counts for all of these
+func (handler *RecommendationHandler) loadInput(user model.User) (recommend.Input, error) {
+	input := recommend.Input{FavoriteGenres: user.FavoriteGenres, Readers: map[string]int{}}
+
+	if err := handler.db.Where("user_id = ?", user.ID).Find(&input.Library).Error; err != nil {
+		return input, err
+	}
+
+	var isbns []string
+	genres := append([]string{}, user.FavoriteGenres...)
+	for _, record := range input.Library {
+		isbns = append(isbns, record.ISBN)
+		if record.Genre != "" {
+			genres = append(genres, record.Genre)
+		}
+	}
+
+	seen := map[uint]bool{}
+	addCandidates := func(records []model.Record) {
+		for _, record := range records {
+			if !seen[record.ID] {
+				seen[record.ID] = true
+				input.Candidates = append(input.Candidates, record)
+			}
+		}
+	}
+
+	if len(isbns) > 0 {
+		var coReaders []model.Record
+		err := handler.db.Where("user_id IN (?)",
+			handler.db.Model(&model.Record{}).Distinct("user_id").Where("isbn IN ? AND user_id <> ?", isbns, user.ID),
+		).Find(&coReaders).Error
+		if err != nil {
+			return input, err
+		}
+		addCandidates(coReaders)
+	}
+
+	if len(genres) > 0 {
+		var inGenres []model.Record
+		err := handler.db.Where("user_id <> ? AND genre IN ?", user.ID, genres).
+			Order("date_added DESC").Limit(genreCandidateLimit).Find(&inGenres).Error
+		if err != nil {
+			return input, err
+		}
+		addCandidates(inGenres)
+	}
+
+	for _, record := range input.Candidates {
+		isbns = append(isbns, record.ISBN)
+	}
+	if len(isbns) == 0 {
+		return input, nil
+	}
+
+	var readers []struct {
+		ISBN    string
+		Readers int
+	}
+	err := handler.db.Model(&model.Record{}).
+		Select("isbn, COUNT(DISTINCT user_id) AS readers").
+		Where("isbn IN ?", isbns).
+		Group("isbn").
+		Scan(&readers).Error
+	for _, row := range readers {
+		input.Readers[row.ISBN] = row.Readers
+	}
+	return input, err
+}
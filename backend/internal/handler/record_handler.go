@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"biblia-be/internal/jobs"
 	"biblia-be/internal/model"
-	"errors"
+	"biblia-be/internal/repository"
+	"biblia-be/internal/service"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -13,85 +15,109 @@ import (
 
 // RecordHandler manages book record operations
 type RecordHandler struct {
-	db *gorm.DB
+	records *service.RecordService
+	jobs    *jobs.Queue
 }
 
-// Initialize sets up the handler with a database connection and performs migrations
+// Initialize sets up the handler with a database connection. Saving a record
+// also takes it off the user's queue and writes its feed events.
 func (handler *RecordHandler) Initialize(db *gorm.DB) {
-	handler.db = db
-	db.AutoMigrate(&model.Record{})
+	handler.records = service.NewRecordService(repository.NewGormRecordRepository(db, dequeueRecord, writeRecordEvents))
 }
 
-// Response represents a standardized API response
+// Response represents a standardized API response. Failures reported through
+// ErrorHandler also carry a stable code, the invalid fields and the request ID.
 type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success   bool                 `json:"success"`
+	Message   string               `json:"message,omitempty"`
+	Data      interface{}          `json:"data,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	Code      string               `json:"code,omitempty"`
+	Fields    []service.FieldError `json:"fields,omitempty"`
+	RequestID string               `json:"requestId,omitempty"`
+	Meta      *Meta                `json:"meta,omitempty"`
 }
 
 // GetRecords godoc
 //
 //	@Summary	Get user reading records
 //	@Schemes
-//	@Description	Returns reading records filtered by userId and/or ISBN
+//	@Description	Returns a page of reading records. Filters combine with AND; status, genre and author accept comma separated values. Pass meta.next_cursor back as cursor to fetch the next page; meta.total is included on the first page. Requested facets are returned in meta.facets as value counts for the current filters, where each facet ignores the filter on its own field. Lent and borrowed records carry their active loan.
 //	@Tags			records
 //	@Accept			json
 //	@Produce		json
 //
 // @Param userId query int false "User ID to filter records (optional)"
-// @Param isbn query int false "ISBN to filter records (optional)"
+// @Param isbn query string false "ISBN to filter records (optional)"
+// @Param status query string false "Statuses to include"
+// @Param genre query string false "Genres to include"
+// @Param author query string false "Authors to include"
+// @Param ownership query string false "Ownership states to include: owned, lent, borrowed, returned"
+// @Param addedFrom query string false "Earliest date added (YYYY-MM-DD or RFC 3339)"
+// @Param addedTo query string false "Latest date added, inclusive for plain dates (YYYY-MM-DD or RFC 3339)"
+// @Param finishedFrom query string false "Earliest date finished (YYYY-MM-DD or RFC 3339)"
+// @Param finishedTo query string false "Latest date finished, inclusive for plain dates (YYYY-MM-DD or RFC 3339)"
+// @Param facets query string false "Comma separated facets to count: status, genre, author, ownership, yearAdded, yearFinished"
+// @Param sort query string false "Sort field" Enums(dateAdded, title, author, progress)
+// @Param order query string false "Sort direction, defaults to desc for dateAdded and progress" Enums(asc, desc)
+// @Param limit query int false "Page size (1-200, default 50)"
+// @Param cursor query string false "Cursor from the previous page"
 //
-//	@Success		200	{object} Response{data=[]model.Record} "Successfully retrieved records"
+//	@Success		200	{object} Response{data=[]model.Record,meta=Meta} "Successfully retrieved records"
 //	@Failure		400	{object} Response "Invalid request parameters"
 //	@Failure		500	{object} Response "Internal server error"
 //	@Router			/records [get]
 func (handler *RecordHandler) GetRecords(c *gin.Context) {
-	var records []model.Record
-	userIdParam, hasUserId := c.GetQuery("userId")
-	isbnParam, hasIsbn := c.GetQuery("isbn")
+	filter, err := parseRecordFilters(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	query := handler.db
+	facets, err := parseFacets(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	// Apply filters if they exist
-	if hasUserId {
-		userId, err := strconv.ParseUint(userIdParam, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, Response{
-				Success: false,
-				Error:   "Invalid userId format",
-			})
-			return
-		}
-		query = query.Where("user_id = ?", userId)
+	page, err := parsePageRequest(c, recordSorts, "dateAdded")
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	if hasIsbn {
-		isbn, err := strconv.ParseUint(isbnParam, 10, 32)
+	// Execute query
+	records, err := handler.records.List(c.Request.Context(), repository.RecordQuery{RecordFilter: filter, Page: page.page()})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	meta := &Meta{Limit: page.limit}
+	records, meta.NextCursor = page.trim(records)
+
+	// Counting is only worth it once, when the client starts paging
+	if page.after == nil {
+		total, err := handler.records.Count(c.Request.Context(), filter)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, Response{
-				Success: false,
-				Error:   "Invalid ISBN format",
-			})
+			c.Error(err)
 			return
 		}
-		query = query.Where("isbn = ?", isbn)
+		meta.Total = &total
 	}
 
-	// Execute query
-	result := query.Find(&records)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to retrieve records",
-		})
-		return
+	if len(facets) > 0 {
+		if meta.Facets, err = handler.records.Facets(c.Request.Context(), filter, facets); err != nil {
+			c.Error(err)
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, Response{
 		Success: true,
 		Data:    records,
-		Message: "Records retrieved successfully",
+		Meta:    meta,
+		Message: translate(c, "record.listed"),
 	})
 }
 
@@ -105,9 +131,10 @@ func (handler *RecordHandler) GetRecords(c *gin.Context) {
 //	@Produce		json
 //
 // @Param userId query int true "User ID of the record owner"
-// @Param isbn query int true "ISBN of the book"
+// @Param isbn query string true "ISBN of the book"
 //
 //	@Success		200	{object} Response{data=model.Record} "Successfully retrieved record"
+//	@Header			200	{string} ETag "Version of the record, for If-Match"
 //	@Failure		400	{object} Response "Invalid request parameters"
 //	@Failure		404	{object} Response "Record not found"
 //	@Failure		500	{object} Response "Internal server error"
@@ -118,57 +145,36 @@ func (handler *RecordHandler) GetRecordByUserAndISBN(c *gin.Context) {
 
 	// Validate required parameters
 	if !hasUserId || !hasIsbn {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Both userId and isbn parameters are required",
-		})
+		c.Error(invalidParameter("param.userIDAndISBNRequired", nil))
 		return
 	}
 
 	// Parse and validate userId
 	userId, err := strconv.ParseUint(userIdParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid userId format",
-		})
+		c.Error(invalidParameter("param.invalidUserID", nil))
 		return
 	}
 
-	// Parse and validate isbn
-	isbn, err := strconv.ParseUint(isbnParam, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid ISBN format",
-		})
+	// Imported records may keep ISBNs that are not valid, so any is looked up
+	if strings.TrimSpace(isbnParam) == "" {
+		c.Error(invalidParameter("param.invalidISBN", nil))
 		return
 	}
 
 	// Query the record
-	var record model.Record
-	result := handler.db.Where("user_id = ? AND isbn = ?", userId, isbn).First(&record)
-
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, Response{
-				Success: false,
-				Error:   "Record not found for the specified user and ISBN",
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to retrieve record",
-		})
+	record, err := handler.records.Get(c.Request.Context(), uint(userId), isbnParam)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
+	setETag(c, record)
+
 	c.JSON(http.StatusOK, Response{
 		Success: true,
 		Data:    record,
-		Message: "Record retrieved successfully",
+		Message: translate(c, "record.retrieved"),
 	})
 }
 
@@ -182,10 +188,13 @@ func (handler *RecordHandler) GetRecordByUserAndISBN(c *gin.Context) {
 //	@Produce		json
 //
 // @Param record body model.CreateRecord true "Reading record data"
+// @Param Idempotency-Key header string false "Key that makes retries replay the first response"
 //
 //	@Success		201	{object} Response{data=model.Record} "Record created successfully"
+//	@Header			201	{string} ETag "Version of the record, for If-Match"
 //	@Failure		400	{object} Response "Invalid request body"
-//	@Failure		409	{object} Response "Record already exists"
+//	@Failure		409	{object} Response "Record already exists, or a request with the same Idempotency-Key is in progress"
+//	@Failure		422	{object} Response "Idempotency-Key already used for a different request"
 //	@Failure		500	{object} Response "Internal server error"
 //	@Router			/records [post]
 func (handler *RecordHandler) CreateRecord(c *gin.Context) {
@@ -193,60 +202,23 @@ func (handler *RecordHandler) CreateRecord(c *gin.Context) {
 
 	// Parse request body
 	if err := c.ShouldBindJSON(&createRecord); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid request body: " + err.Error(),
-		})
+		c.Error(service.InvalidInput(err))
 		return
 	}
 
-	// Validate required fields
-	if createRecord.UserID == 0 || createRecord.ISBN == "" || createRecord.Title == "" {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "UserID, ISBN, and Title are required fields",
-		})
-		return
-	}
-
-	// Check if record already exists
-	var existingRecord model.Record
-	result := handler.db.Where("user_id = ? AND isbn = ?", createRecord.UserID, createRecord.ISBN).First(&existingRecord)
-
-	if result.RowsAffected > 0 {
-		c.JSON(http.StatusConflict, Response{
-			Success: false,
-			Error:   "A record for this user and ISBN already exists",
-		})
+	// Validate and save the record
+	record, err := handler.records.Create(c.Request.Context(), createRecord)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Create new record
-	record := model.Record{
-		ISBN:        createRecord.ISBN,
-		UserID:      createRecord.UserID,
-		Title:       createRecord.Title,
-		Author:      createRecord.Author,
-		Cover:       createRecord.Cover,
-		Genre:       createRecord.Genre,
-		Status:      createRecord.Status,
-		CurrentPage: createRecord.CurrentPage,
-		TotalPages:  createRecord.TotalPages,
-		DateAdded:   time.Now(),
-	}
-
-	if err := handler.db.Create(&record).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to create record: " + err.Error(),
-		})
-		return
-	}
+	setETag(c, record)
 
 	c.JSON(http.StatusCreated, Response{
 		Success: true,
 		Data:    record,
-		Message: "Record created successfully",
+		Message: translate(c, "record.created"),
 	})
 }
 
@@ -260,12 +232,15 @@ func (handler *RecordHandler) CreateRecord(c *gin.Context) {
 //	@Produce		json
 //
 // @Param userId query int true "User ID of the record owner"
-// @Param isbn query int true "ISBN of the book"
+// @Param isbn query string true "ISBN of the book"
 // @Param record body model.UpdateRecord true "Updated reading status"
+// @Param If-Match header string false "ETag of the version being updated"
 //
 //	@Success		200	{object} Response{data=model.Record} "Record updated successfully"
+//	@Header			200	{string} ETag "New version of the record"
 //	@Failure		400	{object} Response "Invalid request parameters"
 //	@Failure		404	{object} Response "Record not found"
+//	@Failure		412	{object} Response "Record changed since the If-Match version"
 //	@Failure		500	{object} Response "Internal server error"
 //	@Router			/records [put]
 func (handler *RecordHandler) UpdateRecord(c *gin.Context) {
@@ -276,88 +251,42 @@ func (handler *RecordHandler) UpdateRecord(c *gin.Context) {
 
 	// Validate required parameters
 	if !hasUserId || !hasIsbn {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Both userId and isbn parameters are required",
-		})
+		c.Error(invalidParameter("param.userIDAndISBNRequired", nil))
 		return
 	}
 
 	// Parse and validate userId
 	userId, err := strconv.ParseUint(userIdParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid userId format",
-		})
+		c.Error(invalidParameter("param.invalidUserID", nil))
 		return
 	}
 
-	// Parse and validate isbn
-	isbn, err := strconv.ParseUint(isbnParam, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid ISBN format",
-		})
+	// Imported records may keep ISBNs that are not valid, so any is looked up
+	if strings.TrimSpace(isbnParam) == "" {
+		c.Error(invalidParameter("param.invalidISBN", nil))
 		return
 	}
 
 	// Parse request body
 	if err := c.ShouldBindJSON(&updateRecord); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid request body: " + err.Error(),
-		})
+		c.Error(service.InvalidInput(err))
 		return
 	}
 
-	// Find the record to update
-	var record model.Record
-	result := handler.db.Where("user_id = ? AND isbn = ?", userId, isbn).First(&record)
-
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, Response{
-				Success: false,
-				Error:   "Record not found for the specified user and ISBN",
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to retrieve record for update",
-		})
-		return
-	}
-
-	// Validate update data
-	if updateRecord.CurrentPage > record.TotalPages {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Current page cannot exceed total pages",
-		})
+	// Validate and save the progress
+	record, err := handler.records.Update(c.Request.Context(), uint(userId), isbnParam, updateRecord, ifMatch(c))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Update record fields
-	record.Status = updateRecord.Status
-	record.CurrentPage = updateRecord.CurrentPage
-
-	// Save updated record
-	if err := handler.db.Save(&record).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to update record: " + err.Error(),
-		})
-		return
-	}
+	setETag(c, record)
 
 	c.JSON(http.StatusOK, Response{
 		Success: true,
 		Data:    record,
-		Message: "Record updated successfully",
+		Message: translate(c, "record.updated"),
 	})
 }
 
@@ -371,11 +300,13 @@ func (handler *RecordHandler) UpdateRecord(c *gin.Context) {
 //	@Produce		json
 //
 // @Param userId query int true "User ID of the record owner"
-// @Param isbn query int true "ISBN of the book"
+// @Param isbn query string true "ISBN of the book"
+// @Param If-Match header string false "ETag of the version being deleted"
 //
 //	@Success		200	{object} Response "Record deleted successfully"
 //	@Failure		400	{object} Response "Invalid request parameters"
 //	@Failure		404	{object} Response "Record not found"
+//	@Failure		412	{object} Response "Record changed since the If-Match version"
 //	@Failure		500	{object} Response "Internal server error"
 //	@Router			/records [delete]
 func (handler *RecordHandler) DeleteRecord(c *gin.Context) {
@@ -384,64 +315,31 @@ func (handler *RecordHandler) DeleteRecord(c *gin.Context) {
 
 	// Validate required parameters
 	if !hasUserId || !hasIsbn {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Both userId and isbn parameters are required",
-		})
+		c.Error(invalidParameter("param.userIDAndISBNRequired", nil))
 		return
 	}
 
 	// Parse and validate userId
 	userId, err := strconv.ParseUint(userIdParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid userId format",
-		})
-		return
-	}
-
-	// Parse and validate isbn
-	isbn, err := strconv.ParseUint(isbnParam, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid ISBN format",
-		})
+		c.Error(invalidParameter("param.invalidUserID", nil))
 		return
 	}
 
-	// Find the record to delete
-	var record model.Record
-	result := handler.db.Where("user_id = ? AND isbn = ?", userId, isbn).First(&record)
-
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, Response{
-				Success: false,
-				Error:   "Record not found for the specified user and ISBN",
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to retrieve record for deletion",
-		})
+	// Imported records may keep ISBNs that are not valid, so any is looked up
+	if strings.TrimSpace(isbnParam) == "" {
+		c.Error(invalidParameter("param.invalidISBN", nil))
 		return
 	}
 
 	// Delete the record
-	if err := handler.db.Delete(&record).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to delete record: " + err.Error(),
-		})
+	if err := handler.records.Delete(c.Request.Context(), uint(userId), isbnParam, ifMatch(c)); err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, Response{
 		Success: true,
-		Message: "Record deleted successfully",
+		Message: translate(c, "record.deleted"),
 	})
 }
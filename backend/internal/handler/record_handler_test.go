@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"biblia-be/internal/model"
+	"biblia-be/internal/repository"
+	"biblia-be/internal/service"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRecordRouter() (*gin.Engine, *repository.MemoryRecordRepository) {
+	gin.SetMode(gin.TestMode)
+	records := repository.NewMemoryRecordRepository()
+	handler := RecordHandler{records: service.NewRecordService(records)}
+
+	router := gin.New()
+	router.Use(RequestID(), Localize(), ErrorHandler())
+	router.GET("records", handler.GetRecords)
+	router.GET("records/detail", handler.GetRecordByUserAndISBN)
+	router.POST("records", handler.CreateRecord)
+	router.PUT("records", handler.UpdateRecord)
+	router.DELETE("records", handler.DeleteRecord)
+	return router, records
+}
+
+// addRecord creates a record through the API
+func addRecord(t *testing.T, router *gin.Engine, record model.CreateRecord) model.Record {
+	t.Helper()
+	code, resp := serve(t, router, http.MethodPost, "/records", record)
+	if code != http.StatusCreated {
+		t.Fatalf("create %s: got %d %s", record.ISBN, code, resp.Error)
+	}
+	return decode[model.Record](t, resp)
+}
+
+func TestCreateRecord(t *testing.T) {
+	router, _ := newRecordRouter()
+
+	record := addRecord(t, router, model.CreateRecord{UserID: 1, ISBN: "9786161851125", Title: "Khu Kam", TotalPages: 300, Status: "finished"})
+	if record.ID == 0 || record.Ownership != model.OwnershipOwned || record.DateAdded.IsZero() || record.DateFinished == nil {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	code, _ := serve(t, router, http.MethodPost, "/records", model.CreateRecord{UserID: 1, ISBN: "9786161851125", Title: "Khu Kam", TotalPages: 300})
+	if code != http.StatusConflict {
+		t.Errorf("duplicate record: got %d, want 409", code)
+	}
+
+	// Another user may keep a record of the same book
+	addRecord(t, router, model.CreateRecord{UserID: 2, ISBN: "9786161851125", Title: "Khu Kam", TotalPages: 300})
+
+	code, _ = serve(t, router, http.MethodPost, "/records", model.CreateRecord{UserID: 1, ISBN: "9786160000000"})
+	if code != http.StatusBadRequest {
+		t.Errorf("missing title: got %d, want 400", code)
+	}
+}
+
+func TestGetRecordsFiltersAndFacets(t *testing.T) {
+	router, repo := newRecordRouter()
+	added := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	// The API stamps the date added, so the records are stored directly
+	for _, record := range []model.Record{
+		{UserID: 1, ISBN: "1001", Title: "A", Author: "Sidaoruang", Genre: "fiction", Status: "reading", TotalPages: 100, DateAdded: added},
+		{UserID: 1, ISBN: "1002", Title: "B", Author: "Sidaoruang", Genre: "fiction", Status: "finished", TotalPages: 100, DateAdded: added.AddDate(0, 1, 0)},
+		{UserID: 1, ISBN: "1003", Title: "C", Author: "Chart Korbjitti", Genre: "essay", Status: "reading", TotalPages: 100, DateAdded: added.AddDate(1, 0, 0)},
+		{UserID: 2, ISBN: "1001", Title: "A", Author: "Sidaoruang", Genre: "fiction", Status: "reading", TotalPages: 100, DateAdded: added},
+	} {
+		if err := repo.Create(context.Background(), &record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, resp := serve(t, router, http.MethodGet, "/records?userId=1&status=reading&facets=status,genre,yearAdded", nil)
+	if code != http.StatusOK {
+		t.Fatalf("list: got %d %s", code, resp.Error)
+	}
+	records := decode[[]model.Record](t, resp)
+	if len(records) != 2 || records[0].ISBN != "1003" || records[1].ISBN != "1001" {
+		t.Errorf("want 1003 and 1001 newest first, got %+v", records)
+	}
+	if resp.Meta.Total == nil || *resp.Meta.Total != 2 {
+		t.Errorf("total: got %v, want 2", resp.Meta.Total)
+	}
+
+	// The status facet ignores the status filter, the others do not
+	wantFacets := map[string][]repository.FacetCount{
+		"status":    {{Value: "reading", Count: 2}, {Value: "finished", Count: 1}},
+		"genre":     {{Value: "essay", Count: 1}, {Value: "fiction", Count: 1}},
+		"yearAdded": {{Value: "2024", Count: 1}, {Value: "2025", Count: 1}},
+	}
+	for name, want := range wantFacets {
+		got := resp.Meta.Facets[name]
+		if len(got) != len(want) {
+			t.Errorf("facet %s: got %v, want %v", name, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("facet %s: got %v, want %v", name, got, want)
+				break
+			}
+		}
+	}
+
+	code, resp = serve(t, router, http.MethodGet, "/records?author=Sidaoruang&addedTo=2024-03-01", nil)
+	if code != http.StatusOK {
+		t.Fatalf("list: got %d %s", code, resp.Error)
+	}
+	if records := decode[[]model.Record](t, resp); len(records) != 2 {
+		t.Errorf("addedTo includes the whole day: got %d records, want 2", len(records))
+	}
+
+	for _, query := range []string{"userId=x", "facets=publisher", "addedFrom=yesterday", "sort=rating", "limit=0"} {
+		if code, _ := serve(t, router, http.MethodGet, "/records?"+query, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", query, code)
+		}
+	}
+}
+
+func TestGetRecordsProgressPagination(t *testing.T) {
+	router, _ := newRecordRouter()
+	for i, page := range []int32{50, 100, 50, 0, 75} {
+		addRecord(t, router, model.CreateRecord{
+			UserID:      1,
+			ISBN:        string(rune('a' + i)),
+			Title:       "Title",
+			CurrentPage: page,
+			TotalPages:  100,
+		})
+	}
+
+	var isbns, cursor string
+	target := "/records?sort=progress&limit=2"
+	for target != "" {
+		code, resp := serve(t, router, http.MethodGet, target, nil)
+		if code != http.StatusOK {
+			t.Fatalf("list: got %d %s", code, resp.Error)
+		}
+		for _, record := range decode[[]model.Record](t, resp) {
+			isbns += record.ISBN
+		}
+		target = ""
+		if resp.Meta.NextCursor != "" {
+			cursor = resp.Meta.NextCursor
+			target = "/records?sort=progress&limit=2&cursor=" + resp.Meta.NextCursor
+		}
+	}
+
+	// Equal progress falls back to descending IDs
+	if isbns != "becad" {
+		t.Errorf("got order %q, want %q", isbns, "becad")
+	}
+
+	if code, _ := serve(t, router, http.MethodGet, "/records?sort=title&cursor="+cursor, nil); code != http.StatusBadRequest {
+		t.Errorf("cursor of another sort: got %d, want 400", code)
+	}
+}
+
+func TestUpdateRecord(t *testing.T) {
+	router, _ := newRecordRouter()
+	addRecord(t, router, model.CreateRecord{UserID: 1, ISBN: "9786161851132", Title: "Lap Lae", Status: "reading", TotalPages: 200})
+
+	code, resp := serve(t, router, http.MethodPut, "/records?userId=1&isbn=9786161851132", model.UpdateRecord{Status: "reading", CurrentPage: 201})
+	if code != http.StatusBadRequest || len(resp.Fields) != 1 || resp.Fields[0].Field != "currentPage" {
+		t.Errorf("current page past the end: got %d %+v, want 400 on currentPage", code, resp.Fields)
+	}
+
+	rating := int8(4)
+	code, resp = serve(t, router, http.MethodPut, "/records?userId=1&isbn=9786161851132", model.UpdateRecord{Status: "finished", CurrentPage: 200, Rating: &rating})
+	if code != http.StatusOK {
+		t.Fatalf("update: got %d %s", code, resp.Error)
+	}
+	record := decode[model.Record](t, resp)
+	if record.DateFinished == nil || record.Rating != 4 || record.CurrentPage != 200 {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	code, resp = serve(t, router, http.MethodGet, "/records/detail?userId=1&isbn=9786161851132", nil)
+	if code != http.StatusOK {
+		t.Fatalf("detail: got %d %s", code, resp.Error)
+	}
+	if stored := decode[model.Record](t, resp); stored.Status != "finished" || stored.Rating != 4 {
+		t.Errorf("update was not saved: %+v", stored)
+	}
+
+	if code, resp := serve(t, router, http.MethodPut, "/records?userId=2&isbn=9786161851132", model.UpdateRecord{Status: "reading"}); code != http.StatusNotFound || resp.Code != service.CodeRecordNotFound {
+		t.Errorf("missing record: got %d %s, want 404 %s", code, resp.Code, service.CodeRecordNotFound)
+	}
+}
+
+func TestDeleteRecord(t *testing.T) {
+	router, _ := newRecordRouter()
+	addRecord(t, router, model.CreateRecord{UserID: 1, ISBN: "9786161851132", Title: "Lap Lae", TotalPages: 200})
+
+	if code, _ := serve(t, router, http.MethodDelete, "/records?userId=1&isbn=9786161851132", nil); code != http.StatusOK {
+		t.Fatalf("delete: got %d, want 200", code)
+	}
+	if code, _ := serve(t, router, http.MethodDelete, "/records?userId=1&isbn=9786161851132", nil); code != http.StatusNotFound {
+		t.Errorf("delete twice: got %d, want 404", code)
+	}
+	if code, _ := serve(t, router, http.MethodDelete, "/records?userId=1", nil); code != http.StatusBadRequest {
+		t.Errorf("missing isbn: got %d, want 400", code)
+	}
+	if code, _ := serve(t, router, http.MethodDelete, "/records?userId=1&isbn=", nil); code != http.StatusBadRequest {
+		t.Errorf("empty isbn: got %d, want 400", code)
+	}
+
+	// The placeholders of imported books without an ISBN can be managed too
+	addRecord(t, router, model.CreateRecord{UserID: 1, ISBN: "legacy-12", Title: "Khang Lang Phap", TotalPages: 120})
+	if code, resp := serve(t, router, http.MethodGet, "/records/detail?userId=1&isbn=legacy-12", nil); code != http.StatusOK {
+		t.Errorf("placeholder detail: got %d %s, want 200", code, resp.Error)
+	}
+	if code, resp := serve(t, router, http.MethodDelete, "/records?userId=1&isbn=legacy-12", nil); code != http.StatusOK {
+		t.Errorf("placeholder delete: got %d %s, want 200", code, resp.Error)
+	}
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"biblia-be/internal/jobs"
+	"biblia-be/internal/model"
+	"biblia-be/internal/service"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportRecordsJob is the job type of a library import
+const ImportRecordsJob = "records.import"
+
+// importProgressEvery is how many records are processed between progress updates
+const importProgressEvery = 50
+
+// ImportFailure describes one record that could not be imported
+type ImportFailure struct {
+	Index int    `json:"index"`
+	ISBN  string `json:"isbn"`
+	Error string `json:"error"`
+}
+
+// ImportResult is stored as the result of a finished import job
+type ImportResult struct {
+	Created int             `json:"created"`
+	Skipped int             `json:"skipped"`
+	Failed  []ImportFailure `json:"failed"`
+}
+
+// UseJobs registers the record job types on the queue
+func (handler *RecordHandler) UseJobs(queue *jobs.Queue) {
+	handler.jobs = queue
+	queue.Register(ImportRecordsJob, handler.runImport)
+}
+
+// ImportRecords godoc
+//
+//	@Summary	Import a library
+//	@Schemes
+//	@Description	Queues a background job that creates reading records in bulk. Records that already exist for the user are skipped. Poll GET /jobs/{id} for progress.
+//	@Tags			records
+//	@Accept			json
+//	@Produce		json
+//
+// @Param import body model.ImportRecords true "Records to import"
+//
+//	@Success		202	{object} Response{data=JobResponse} "Import queued"
+//	@Failure		400	{object} Response "Invalid request body"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/records/import [post]
+func (handler *RecordHandler) ImportRecords(c *gin.Context) {
+	var importRecords model.ImportRecords
+
+	// Parse request body
+	if err := c.ShouldBindJSON(&importRecords); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+
+	job, err := handler.jobs.Enqueue(ImportRecordsJob, importRecords.UserID, importRecords)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, Response{
+		Success: true,
+		Data:    toJobResponse(*job),
+		Message: translate(c, "record.importQueued"),
+	})
+}
+
+// runImport creates the records of an import job. Existing records are skipped,
+// which keeps retried attempts from creating duplicates.
+func (handler *RecordHandler) runImport(ctx context.Context, task *jobs.Task) (interface{}, error) {
+	var importRecords model.ImportRecords
+	if err := task.Decode(&importRecords); err != nil {
+		return nil, jobs.Fail("The import could not be read", err)
+	}
+
+	result := ImportResult{Failed: []ImportFailure{}}
+	total := len(importRecords.Records)
+
+	for i, createRecord := range importRecords.Records {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		createRecord.UserID = importRecords.UserID
+		created, err := handler.records.Import(ctx, createRecord)
+		var domainErr *service.Error
+		switch {
+		case errors.As(err, &domainErr):
+			result.Failed = append(result.Failed, ImportFailure{Index: i, ISBN: createRecord.ISBN, Error: domainErr.Message})
+			continue
+		case err != nil:
+			// The job is retried, skipping the records this attempt created
+			return nil, err
+		case created:
+			result.Created++
+		default:
+			result.Skipped++
+		}
+
+		if (i+1)%importProgressEvery == 0 {
+			if err := task.SetProgress(float64(i+1) * 100 / float64(total)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
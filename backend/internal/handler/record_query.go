@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/model"
+	"biblia-be/internal/repository"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordSorts are the orderings supported by GET /records
+var recordSorts = map[string]sortSpec[model.Record]{
+	"dateAdded": {
+		desc:  true,
+		value: func(r model.Record) interface{} { return r.DateAdded },
+		parse: parseTimeCursor,
+		id:    func(r model.Record) uint { return r.ID },
+	},
+	"title": {
+		value: func(r model.Record) interface{} { return r.Title },
+		parse: parseStringCursor,
+		id:    func(r model.Record) uint { return r.ID },
+	},
+	"author": {
+		value: func(r model.Record) interface{} { return r.Author },
+		parse: parseStringCursor,
+		id:    func(r model.Record) uint { return r.ID },
+	},
+	"progress": {
+		desc:  true,
+		value: func(r model.Record) interface{} { return r.Progress() },
+		parse: parseNumberCursor,
+		id:    func(r model.Record) uint { return r.ID },
+	},
+}
+
+// splitList parses a comma separated filter value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseRecordFilters reads the filter parameters of GET /records: userId, isbn,
+// status, genre, author and ownership (comma separated for several values) and the
+// addedFrom/addedTo and finishedFrom/finishedTo date ranges
+func parseRecordFilters(c *gin.Context) (repository.RecordFilter, error) {
+	var filter repository.RecordFilter
+
+	if userIdParam, ok := c.GetQuery("userId"); ok {
+		userId, err := strconv.ParseUint(userIdParam, 10, 32)
+		if err != nil {
+			return filter, invalidParameter("param.invalidUserID", nil)
+		}
+		id := uint(userId)
+		filter.UserID = &id
+	}
+
+	filter.ISBN = c.Query("isbn")
+	filter.Status = splitList(c.Query("status"))
+	filter.Genre = splitList(c.Query("genre"))
+	filter.Author = splitList(c.Query("author"))
+	filter.Ownership = splitList(c.Query("ownership"))
+
+	ranges := []struct {
+		param    string
+		endOfDay bool
+		target   **time.Time
+	}{
+		{"addedFrom", false, &filter.AddedFrom},
+		{"addedTo", true, &filter.AddedTo},
+		{"finishedFrom", false, &filter.FinishedFrom},
+		{"finishedTo", true, &filter.FinishedTo},
+	}
+	for _, r := range ranges {
+		if value := c.Query(r.param); value != "" {
+			t, err := parseDateParam(value, r.endOfDay)
+			if err != nil {
+				return filter, invalidParameter("param.invalidDate", i18n.Params{"param": r.param})
+			}
+			*r.target = &t
+		}
+	}
+
+	return filter, nil
+}
+
+// parseFacets reads the comma separated facets parameter
+func parseFacets(c *gin.Context) ([]string, error) {
+	names := splitList(c.Query("facets"))
+	for _, name := range names {
+		if !repository.IsRecordFacet(name) {
+			return nil, invalidParameter("param.unsupportedFacet", i18n.Params{"facet": name})
+		}
+	}
+	return names, nil
+}
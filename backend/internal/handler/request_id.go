@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey stores the request ID in the gin context
+const requestIDKey = "requestID"
+
+// validRequestID accepts IDs set by proxies and clients, rejecting values that
+// would be unsafe to echo back or write to logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID tags every request with an ID, reusing the one a proxy or client
+// sent, and returns it in the X-Request-ID header so failures can be traced in
+// the logs
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// newRequestID returns a random 128-bit ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the ID of the current request, or "" without the middleware
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
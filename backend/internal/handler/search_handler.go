@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/model"
+	"biblia-be/internal/search"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Result limits for GET /search
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// SearchHandler serves full-text search over reading records and the shared catalog
+type SearchHandler struct {
+	db    *gorm.DB
+	index search.SearchIndex
+}
+
+// Initialize sets up the handler with a database connection and the search index
+func (handler *SearchHandler) Initialize(db *gorm.DB, index search.SearchIndex) {
+	handler.db = db
+	handler.index = index
+}
+
+// RecordHit is one of the user's records matching a search
+type RecordHit struct {
+	model.Record
+	Score float64 `json:"score"`
+}
+
+// CatalogHit is a book from other users' libraries matching a search
+type CatalogHit struct {
+	model.CatalogBook
+	Score float64 `json:"score"`
+}
+
+// SearchResponse holds search results ranked by relevance
+type SearchResponse struct {
+	Records []RecordHit  `json:"records"`
+	Catalog []CatalogHit `json:"catalog"`
+}
+
+// searchRecords runs a query and loads the matching records with their scores in
+// rank order. Hits for records deleted since they were indexed are dropped.
+func (handler *SearchHandler) searchRecords(ctx context.Context, query search.Query) ([]model.Record, []float64, error) {
+	hits, err := handler.index.Search(ctx, query)
+	if err != nil || len(hits) == 0 {
+		return nil, nil, err
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.RecordID
+	}
+	var found []model.Record
+	if err := handler.db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[uint]model.Record, len(found))
+	for _, record := range found {
+		byID[record.ID] = record
+	}
+
+	var records []model.Record
+	var scores []float64
+	for _, hit := range hits {
+		if record, ok := byID[hit.RecordID]; ok {
+			records = append(records, record)
+			scores = append(scores, hit.Score)
+		}
+	}
+	return records, scores, nil
+}
+
+// Search godoc
+//
+//	@Summary	Search books
+//	@Schemes
+//	@Description	Full-text search over the titles, authors, genres, ISBNs and notes of a user's records, and over the titles, authors, genres and ISBNs of books in other users' libraries. Matching ignores case, Latin accents and Thai tone marks; every term must match, as a whole word, a prefix or inside a word, so partial input can be searched while typing.
+//	@Tags			search
+//	@Accept			json
+//	@Produce		json
+//
+// @Param q query string true "Search text"
+// @Param userId query int true "User whose records are searched"
+// @Param catalog query bool false "Also search the shared catalog (default true)"
+// @Param limit query int false "Maximum results per section (1-50, default 20)"
+//
+//	@Success		200	{object} Response{data=SearchResponse} "Search completed successfully"
+//	@Failure		400	{object} Response "Invalid request parameters"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/search [get]
+func (handler *SearchHandler) Search(c *gin.Context) {
+	text := c.Query("q")
+	if len(search.Terms(text)) == 0 {
+		c.Error(invalidParameter("param.searchTextRequired", nil))
+		return
+	}
+
+	userId, err := strconv.ParseUint(c.Query("userId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	limit := defaultSearchLimit
+	if limitParam, ok := c.GetQuery("limit"); ok {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			c.Error(invalidParameter("param.invalidLimit", i18n.Params{"max": strconv.Itoa(maxSearchLimit)}))
+			return
+		}
+	}
+
+	query := search.Query{Text: text, UserID: uint(userId), Limit: limit}
+	response := SearchResponse{Records: []RecordHit{}, Catalog: []CatalogHit{}}
+
+	records, scores, err := handler.searchRecords(c.Request.Context(), query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i, record := range records {
+		response.Records = append(response.Records, RecordHit{Record: record, Score: scores[i]})
+	}
+
+	if c.DefaultQuery("catalog", "true") != "false" {
+		query.Catalog = true
+		records, scores, err := handler.searchRecords(c.Request.Context(), query)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		for i, record := range records {
+			response.Catalog = append(response.Catalog, CatalogHit{CatalogBook: catalogBook(record), Score: scores[i]})
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    response,
+		Message: "Search completed successfully",
+	})
+}
@@ -0,0 +1,486 @@
+package handler
+
+import (
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/model"
+	"biblia-be/internal/repository"
+	"biblia-be/internal/service"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SeriesHandler manages book series and readers' progress through them
+type SeriesHandler struct {
+	db *gorm.DB
+}
+
+// Initialize sets up the handler with a database connection
+func (handler *SeriesHandler) Initialize(db *gorm.DB) {
+	handler.db = db
+}
+
+// SeriesVolume is a volume of a series with the reader's record of it
+type SeriesVolume struct {
+	model.SeriesEntry
+	RecordID *uint  `json:"recordID"`
+	Status   string `json:"status"`
+}
+
+// SeriesProgress is how far a user has read through a series. Next is the first
+// volume in reading order the user has not finished, nil once the series is done.
+type SeriesProgress struct {
+	ID       uint           `json:"id"`
+	Name     string         `json:"name"`
+	Author   string         `json:"author"`
+	Total    int            `json:"total"`
+	Finished int            `json:"finished"`
+	Reading  int            `json:"reading"`
+	Next     *SeriesVolume  `json:"next"`
+	Volumes  []SeriesVolume `json:"volumes,omitempty"`
+}
+
+// sortSeriesEntries puts entries in reading order
+func sortSeriesEntries(entries []model.SeriesEntry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Position < entries[j].Position })
+}
+
+// orderedEntries preloads the entries of a series in reading order
+func orderedEntries(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+// seriesProgress matches the volumes of a series with the user's records, keyed by ISBN
+func seriesProgress(series model.Series, records map[string]model.Record) SeriesProgress {
+	progress := SeriesProgress{
+		ID:      series.ID,
+		Name:    series.Name,
+		Author:  series.Author,
+		Total:   len(series.Entries),
+		Volumes: make([]SeriesVolume, len(series.Entries)),
+	}
+	for i, entry := range series.Entries {
+		volume := SeriesVolume{SeriesEntry: entry}
+		if record, ok := records[entry.ISBN]; ok {
+			volume.RecordID = &record.ID
+			volume.Status = record.Status
+		}
+		progress.Volumes[i] = volume
+
+		switch volume.Status {
+		case model.StatusFinished:
+			progress.Finished++
+			continue
+		case model.StatusReading:
+			progress.Reading++
+		}
+		if progress.Next == nil {
+			progress.Next = &progress.Volumes[i]
+		}
+	}
+	return progress
+}
+
+// userRecordsByISBN returns the user's records of the given books
+func (handler *SeriesHandler) userRecordsByISBN(userId uint64, isbns []string) (map[string]model.Record, error) {
+	var records []model.Record
+	if err := handler.db.Select("id", "isbn", "status").
+		Where("user_id = ? AND isbn IN ?", userId, isbns).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	byISBN := make(map[string]model.Record, len(records))
+	for _, record := range records {
+		byISBN[record.ISBN] = record
+	}
+	return byISBN, nil
+}
+
+// newSeriesEntries validates entries and fills in missing titles from the catalog,
+// writing a response if an entry is invalid
+func (handler *SeriesHandler) newSeriesEntries(c *gin.Context, createEntries []model.CreateSeriesEntry) ([]model.SeriesEntry, bool) {
+	entries := make([]model.SeriesEntry, 0, len(createEntries))
+	seen := make(map[string]bool, len(createEntries))
+	for _, createEntry := range createEntries {
+		if createEntry.ISBN == "" || createEntry.Position <= 0 {
+			c.Error(newError(service.ErrValidation, CodeInvalidSeriesEntry))
+			return nil, false
+		}
+		if seen[createEntry.ISBN] {
+			c.Error(service.NewError(service.ErrValidation, CodeDuplicateSeriesEntry, "", i18n.Params{"isbn": createEntry.ISBN}))
+			return nil, false
+		}
+		seen[createEntry.ISBN] = true
+
+		entry := model.SeriesEntry{
+			ISBN:     createEntry.ISBN,
+			Title:    createEntry.Title,
+			Position: createEntry.Position,
+		}
+		if entry.Title == "" {
+			book, err := findCatalogBook(handler.db, []string{entry.ISBN})
+			if err != nil {
+				c.Error(err)
+				return nil, false
+			}
+			if book != nil {
+				entry.Title = book.Title
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, true
+}
+
+// loadSeries fetches the series named in the path with its entries in reading
+// order, writing a response if it cannot
+func (handler *SeriesHandler) loadSeries(c *gin.Context, param string) (model.Series, bool) {
+	var series model.Series
+	id, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidSeriesID", nil))
+		return series, false
+	}
+
+	if err := handler.db.Preload("Entries", orderedEntries).First(&series, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(newError(service.ErrNotFound, CodeSeriesNotFound))
+			return series, false
+		}
+
+		c.Error(err)
+		return series, false
+	}
+	return series, true
+}
+
+// GetSeries godoc
+//
+//	@Summary	List series
+//	@Schemes
+//	@Description	Returns series by name with their volumes in reading order. Pass isbn to find the series a book belongs to.
+//	@Tags			series
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			isbn	query	string	false	"Only series containing this book"
+//	@Param			name	query	string	false	"Only series whose name starts with this"
+//	@Success		200	{object} Response{data=[]model.Series} "Successfully retrieved series"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/series [get]
+func (handler *SeriesHandler) GetSeries(c *gin.Context) {
+	query := handler.db.Preload("Entries", orderedEntries).Order("name, id")
+	if isbn := c.Query("isbn"); isbn != "" {
+		query = query.Where("id IN (?)", handler.db.Model(&model.SeriesEntry{}).Select("series_id").Where("isbn = ?", isbn))
+	}
+	if name := c.Query("name"); name != "" {
+		query = query.Scopes(repository.HasPrefix("name", name))
+	}
+
+	series := []model.Series{}
+	if err := query.Find(&series).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    series,
+		Message: "Series retrieved successfully",
+	})
+}
+
+// GetSeriesByID godoc
+//
+//	@Summary	Get a series
+//	@Schemes
+//	@Description	Returns a series with its volumes in reading order
+//	@Tags			series
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id	path	int	true	"Series ID"
+//	@Success		200	{object} Response{data=model.Series} "Successfully retrieved series"
+//	@Failure		400	{object} Response "Invalid series ID"
+//	@Failure		404	{object} Response "Series not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/series/{id} [get]
+func (handler *SeriesHandler) GetSeriesByID(c *gin.Context) {
+	series, ok := handler.loadSeries(c, "id")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    series,
+		Message: "Series retrieved successfully",
+	})
+}
+
+// CreateSeries godoc
+//
+//	@Summary	Create a series
+//	@Schemes
+//	@Description	Creates a series with its volumes. Positions give the reading order and may be fractional (1.5) for novellas between two books. Titles left out are taken from the catalog.
+//	@Tags			series
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			series	body	model.CreateSeries	true	"Series and its volumes"
+//	@Success		201	{object} Response{data=model.Series} "Series created successfully"
+//	@Failure		400	{object} Response "Invalid request body"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/series [post]
+func (handler *SeriesHandler) CreateSeries(c *gin.Context) {
+	var createSeries model.CreateSeries
+	if err := c.ShouldBindJSON(&createSeries); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+
+	entries, ok := handler.newSeriesEntries(c, createSeries.Entries)
+	if !ok {
+		return
+	}
+
+	series := model.Series{
+		Name:        createSeries.Name,
+		Author:      createSeries.Author,
+		Description: createSeries.Description,
+		Entries:     entries,
+	}
+	if err := handler.db.Create(&series).Error; err != nil {
+		c.Error(err)
+		return
+	}
+	sortSeriesEntries(series.Entries)
+
+	c.JSON(http.StatusCreated, Response{
+		Success: true,
+		Data:    series,
+		Message: "Series created successfully",
+	})
+}
+
+// AddSeriesEntries godoc
+//
+//	@Summary	Add volumes to a series
+//	@Schemes
+//	@Description	Adds volumes to a series and returns the series in reading order. Titles left out are taken from the catalog.
+//	@Tags			series
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int							true	"Series ID"
+//	@Param			entries	body	[]model.CreateSeriesEntry	true	"Volumes to add"
+//	@Success		200	{object} Response{data=model.Series} "Volumes added successfully"
+//	@Failure		400	{object} Response "Invalid request body"
+//	@Failure		404	{object} Response "Series not found"
+//	@Failure		409	{object} Response "Book is already in the series"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/series/{id}/entries [post]
+func (handler *SeriesHandler) AddSeriesEntries(c *gin.Context) {
+	var createEntries []model.CreateSeriesEntry
+	if err := c.ShouldBindJSON(&createEntries); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+
+	series, ok := handler.loadSeries(c, "id")
+	if !ok {
+		return
+	}
+	entries, ok := handler.newSeriesEntries(c, createEntries)
+	if !ok {
+		return
+	}
+
+	existing := make(map[string]bool, len(series.Entries))
+	for _, entry := range series.Entries {
+		existing[entry.ISBN] = true
+	}
+	for i := range entries {
+		if existing[entries[i].ISBN] {
+			c.Error(service.NewError(service.ErrConflict, CodeAlreadyInSeries, "", i18n.Params{"isbn": entries[i].ISBN}))
+			return
+		}
+		entries[i].SeriesID = series.ID
+	}
+
+	if len(entries) > 0 {
+		if err := handler.db.Create(&entries).Error; err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	series.Entries = append(series.Entries, entries...)
+	sortSeriesEntries(series.Entries)
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    series,
+		Message: "Volumes added successfully",
+	})
+}
+
+// RemoveSeriesEntry godoc
+//
+//	@Summary	Remove a volume from a series
+//	@Schemes
+//	@Description	Removes a volume from a series
+//	@Tags			series
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id		path	int	true	"Series ID"
+//	@Param			entryId	path	int	true	"Entry ID"
+//	@Success		200	{object} Response "Volume removed successfully"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Volume not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/series/{id}/entries/{entryId} [delete]
+func (handler *SeriesHandler) RemoveSeriesEntry(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidSeriesID", nil))
+		return
+	}
+	entryId, err := strconv.ParseUint(c.Param("entryId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidEntryID", nil))
+		return
+	}
+
+	result := handler.db.Where("id = ? AND series_id = ?", entryId, id).Delete(&model.SeriesEntry{})
+	if result.Error != nil {
+		c.Error(result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(newError(service.ErrNotFound, CodeVolumeNotFound))
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: "Volume removed successfully",
+	})
+}
+
+// GetUserSeries godoc
+//
+//	@Summary	Get a user's series progress
+//	@Schemes
+//	@Description	Returns every series the user has a record of at least one volume from, with how many volumes they finished and the next one to read. Finished series are left out unless complete=true.
+//	@Tags			series
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int		true	"User ID"
+//	@Param			complete	query	bool	false	"Include series the user has finished"
+//	@Success		200	{object} Response{data=[]SeriesProgress} "Successfully retrieved series progress"
+//	@Failure		400	{object} Response "Invalid user ID"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/series [get]
+func (handler *SeriesHandler) GetUserSeries(c *gin.Context) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	userBooks := handler.db.Model(&model.Record{}).Select("isbn").Where("user_id = ?", userId)
+	started := handler.db.Model(&model.SeriesEntry{}).Select("series_id").Where("isbn IN (?)", userBooks)
+
+	var series []model.Series
+	if err := handler.db.Preload("Entries", orderedEntries).Where("id IN (?)", started).Order("name, id").Find(&series).Error; err != nil {
+		c.Error(err)
+		return
+	}
+
+	var isbns []string
+	for _, s := range series {
+		for _, entry := range s.Entries {
+			isbns = append(isbns, entry.ISBN)
+		}
+	}
+	records := map[string]model.Record{}
+	if len(isbns) > 0 {
+		if records, err = handler.userRecordsByISBN(userId, isbns); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	includeComplete := c.Query("complete") == "true"
+	progress := []SeriesProgress{}
+	for _, s := range series {
+		p := seriesProgress(s, records)
+		if p.Next == nil && !includeComplete {
+			continue
+		}
+		if p.Next != nil {
+			next := *p.Next
+			p.Next = &next
+		}
+		p.Volumes = nil
+		progress = append(progress, p)
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    progress,
+		Message: "Series progress retrieved successfully",
+	})
+}
+
+// GetUserSeriesProgress godoc
+//
+//	@Summary	Get a user's progress through a series
+//	@Schemes
+//	@Description	Returns the volumes of a series in reading order with the user's status for each, and the next volume to read
+//	@Tags			series
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			id			path	int	true	"User ID"
+//	@Param			seriesId	path	int	true	"Series ID"
+//	@Success		200	{object} Response{data=SeriesProgress} "Successfully retrieved series progress"
+//	@Failure		400	{object} Response "Invalid request"
+//	@Failure		404	{object} Response "Series not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/users/{id}/series/{seriesId} [get]
+func (handler *SeriesHandler) GetUserSeriesProgress(c *gin.Context) {
+	userId, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	series, ok := handler.loadSeries(c, "seriesId")
+	if !ok {
+		return
+	}
+
+	isbns := make([]string, len(series.Entries))
+	for i, entry := range series.Entries {
+		isbns[i] = entry.ISBN
+	}
+	records := map[string]model.Record{}
+	if len(isbns) > 0 {
+		if records, err = handler.userRecordsByISBN(userId, isbns); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    seriesProgress(series, records),
+		Message: "Series progress retrieved successfully",
+	})
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"biblia-be/internal/changes"
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/model"
+	"biblia-be/internal/repository"
+	"biblia-be/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// Batch limits for GET /sync
+const (
+	defaultSyncLimit = 200
+	maxSyncLimit     = 1000
+)
+
+// Outcomes of a pushed change
+const (
+	// ChangeApplied means the server now holds the change
+	ChangeApplied = "applied"
+	// ChangeConflict means the record changed on the server since the client
+	// last saw it, or was created or deleted there; the result carries the
+	// server's record, if any, to merge with
+	ChangeConflict = "conflict"
+	// ChangeRejected means the change is invalid and will never apply
+	ChangeRejected = "rejected"
+)
+
+// SyncHandler lets clients that keep a copy of a library offline exchange
+// changes with the server
+type SyncHandler struct {
+	db      *gorm.DB
+	records *service.RecordService
+}
+
+// Initialize sets up the handler with a database connection. Pushed changes
+// follow the same rules and hooks as the record endpoints.
+func (handler *SyncHandler) Initialize(db *gorm.DB) {
+	handler.db = db
+	handler.records = service.NewRecordService(repository.NewGormRecordRepository(db, dequeueRecord, writeRecordEvents))
+}
+
+// SyncRecord is a record with the ETag to send back as ifMatch when changing it
+type SyncRecord struct {
+	model.Record
+	ETag string `json:"etag"`
+}
+
+func newSyncRecord(record model.Record) *SyncRecord {
+	return &SyncRecord{Record: record, ETag: record.ETag()}
+}
+
+// SyncResponse is a batch of changes to a user's records. Notes and shelves are
+// fields of records and change with them. Clients apply deleted before records
+// and fetch the next batch with token right away while hasMore is set. reset
+// means the token was not recognized and the batch starts over, so local
+// copies that do not come back are gone.
+type SyncResponse struct {
+	Records []SyncRecord        `json:"records"`
+	Deleted []changes.Tombstone `json:"deleted"`
+	Token   string              `json:"token"`
+	HasMore bool                `json:"hasMore"`
+	Reset   bool                `json:"reset,omitempty"`
+}
+
+// ChangeResult is the outcome of one pushed change, at the index of the change
+// in the push
+type ChangeResult struct {
+	Index  int                  `json:"index"`
+	Op     string               `json:"op"`
+	ISBN   string               `json:"isbn"`
+	Status string               `json:"status"`
+	Code   string               `json:"code,omitempty"`
+	Error  string               `json:"error,omitempty"`
+	Fields []service.FieldError `json:"fields,omitempty"`
+	// Record is the server's record after the change, or on conflict the one
+	// to merge with
+	Record *SyncRecord `json:"record,omitempty"`
+}
+
+// PushResponse holds the outcome of every pushed change, in order
+type PushResponse struct {
+	Results []ChangeResult `json:"results"`
+}
+
+// GetChanges godoc
+//
+//	@Summary	Pull changes to a user's records
+//	@Schemes
+//	@Description	Returns the records created or updated and the records deleted since a change token, oldest first. Omit since for a full sync. Pass the returned token as since next time, immediately while hasMore is set.
+//	@Tags			sync
+//	@Accept			json
+//	@Produce		json
+//
+// @Param userId query int true "User ID"
+// @Param since query string false "Token from the previous sync"
+// @Param limit query int false "Batch size (1-1000, default 200)"
+//
+//	@Success		200	{object} Response{data=SyncResponse} "Changes retrieved successfully"
+//	@Failure		400	{object} Response "Invalid request parameters"
+//	@Failure		404	{object} Response "User not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/sync [get]
+func (handler *SyncHandler) GetChanges(c *gin.Context) {
+	userId, err := strconv.ParseUint(c.Query("userId"), 10, 32)
+	if err != nil {
+		c.Error(invalidParameter("param.invalidUserID", nil))
+		return
+	}
+
+	since, err := changes.ParseToken(c.Query("since"))
+	if err != nil {
+		c.Error(invalidParameter("param.invalidSyncToken", nil))
+		return
+	}
+
+	limit := defaultSyncLimit
+	if limitParam, ok := c.GetQuery("limit"); ok {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxSyncLimit {
+			c.Error(invalidParameter("param.invalidLimit", i18n.Params{"max": strconv.Itoa(maxSyncLimit)}))
+			return
+		}
+	}
+
+	feed, err := changes.Since(c.Request.Context(), handler.db, uint(userId), since, limit)
+	if err != nil {
+		c.Error(userError(err))
+		return
+	}
+
+	response := SyncResponse{
+		Records: make([]SyncRecord, len(feed.Records)),
+		Deleted: feed.Deleted,
+		Token:   changes.Token(feed.Seq),
+		HasMore: feed.More,
+		Reset:   feed.Reset,
+	}
+	for i, record := range feed.Records {
+		response.Records[i] = *newSyncRecord(record)
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    response,
+		Message: translate(c, "sync.pulled"),
+	})
+}
+
+// PushChanges godoc
+//
+//	@Summary	Push changes made offline
+//	@Schemes
+//	@Description	Applies a batch of record changes in order. Each change gets its own result: applied, conflict when the record changed on the server since the ifMatch version or was created or deleted there, or rejected when it is invalid. Conflicts carry the server's record to merge with. Deleting a record that is already gone counts as applied.
+//	@Tags			sync
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param changes body model.PushChanges true "Changes to apply"
+//	@Param Idempotency-Key header string false "Key that makes retries replay the first response"
+//	@Success		200	{object} Response{data=PushResponse} "Changes processed"
+//	@Failure		400	{object} Response "Invalid request body"
+//	@Failure		404	{object} Response "User not found"
+//	@Failure		500	{object} Response "Internal server error"
+//	@Router			/sync [post]
+func (handler *SyncHandler) PushChanges(c *gin.Context) {
+	var push model.PushChanges
+
+	// Parse request body; the changes are checked one by one
+	if err := c.ShouldBindJSON(&push); err != nil {
+		c.Error(service.InvalidInput(err))
+		return
+	}
+	if _, err := changes.Head(c.Request.Context(), handler.db, push.UserID); err != nil {
+		c.Error(userError(err))
+		return
+	}
+
+	results := make([]ChangeResult, len(push.Changes))
+	for i, change := range push.Changes {
+		result, err := handler.apply(c, push.UserID, change)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		result.Index = i
+		results[i] = result
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    PushResponse{Results: results},
+		Message: translate(c, "sync.pushed"),
+	})
+}
+
+// apply makes one pushed change. Domain errors become the result of the
+// change; other errors abort the push.
+func (handler *SyncHandler) apply(c *gin.Context, userID uint, change model.RecordChange) (ChangeResult, error) {
+	ctx := c.Request.Context()
+	result := ChangeResult{Op: change.Op, ISBN: change.ISBN, Status: ChangeApplied}
+
+	if change.Record != nil {
+		change.Record.UserID = userID
+		change.Record.ISBN = change.ISBN
+	}
+	var precondition service.Precondition
+	if change.IfMatch != "" {
+		precondition = service.Precondition{change.IfMatch}
+	}
+
+	var record model.Record
+	err := binding.Validator.ValidateStruct(change)
+	if err != nil {
+		err = service.InvalidInput(err)
+	} else {
+		switch change.Op {
+		case model.ChangeCreate:
+			record, err = handler.records.Create(ctx, *change.Record)
+		case model.ChangeUpdate:
+			record, err = handler.records.Update(ctx, userID, change.ISBN, *change.Update, precondition)
+		case model.ChangeDelete:
+			err = handler.records.Delete(ctx, userID, change.ISBN, precondition)
+			if errors.Is(err, service.ErrNotFound) {
+				err = nil
+			}
+		}
+	}
+
+	if err == nil {
+		if change.Op != model.ChangeDelete {
+			result.Record = newSyncRecord(record)
+		}
+		return result, nil
+	}
+
+	var domainErr *service.Error
+	if !errors.As(err, &domainErr) {
+		return result, err
+	}
+	result.Code = domainErr.Code
+	result.Error = domainErr.Localize(locale(c))
+	result.Fields = localizeFields(locale(c), domainErr.Fields)
+	if errors.Is(err, service.ErrValidation) {
+		result.Status = ChangeRejected
+		return result, nil
+	}
+
+	// Hand back the server's record so the client can merge
+	result.Status = ChangeConflict
+	current, err := handler.records.Get(ctx, userID, change.ISBN)
+	if err == nil {
+		result.Record = newSyncRecord(current)
+	} else if !errors.Is(err, service.ErrNotFound) {
+		return result, err
+	}
+	return result, nil
+}
+
+// userError reports an unknown user as not found
+func userError(err error) error {
+	if errors.Is(err, changes.ErrUnknownUser) {
+		return service.NewError(service.ErrNotFound, service.CodeUserNotFound, "", nil)
+	}
+	return err
+}
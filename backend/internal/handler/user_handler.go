@@ -2,24 +2,23 @@ package handler
 
 import (
 	"biblia-be/internal/model"
-	"errors"
+	"biblia-be/internal/repository"
+	"biblia-be/internal/service"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 // UserHandler manages user account operations
 type UserHandler struct {
-	db *gorm.DB
+	users *service.UserService
 }
 
-// Initialize sets up the handler with a database connection and performs migrations
+// Initialize sets up the handler with a database connection
 func (handler *UserHandler) Initialize(db *gorm.DB) {
-	handler.db = db
-	db.AutoMigrate(&model.User{})
+	handler.users = service.NewUserService(repository.NewGormUserRepository(db))
 }
 
 // UserResponse is a user response with password field removed
@@ -27,6 +26,7 @@ type UserResponse struct {
 	ID             uint           `json:"id"`
 	Username       string         `json:"username"`
 	FavoriteGenres []string       `json:"favorite_genres"`
+	Privacy        string         `json:"privacy"`
 	Records        []model.Record `json:"records,omitempty"`
 }
 
@@ -36,6 +36,7 @@ func toResponse(user model.User) UserResponse {
 		ID:             user.ID,
 		Username:       user.Username,
 		FavoriteGenres: user.FavoriteGenres,
+		Privacy:        user.Privacy,
 		Records:        user.Records,
 	}
 }
@@ -49,35 +50,78 @@ func toResponseArray(users []model.User) []UserResponse {
 	return result
 }
 
+// userSorts are the orderings supported by GET /users
+var userSorts = map[string]sortSpec[model.User]{
+	"id": {
+		value: func(u model.User) interface{} { return u.ID },
+		parse: parseNumberCursor,
+		id:    func(u model.User) uint { return u.ID },
+	},
+	"username": {
+		value: func(u model.User) interface{} { return u.Username },
+		parse: parseStringCursor,
+		id:    func(u model.User) uint { return u.ID },
+	},
+}
+
 // GetUsers godoc
 //
 //	@Summary	Get all users
 //	@Schemes
-//	@Description	Returns all user accounts with their reading records
+//	@Description	Returns a page of user accounts. Records are only included when requested, for the users of the page. Pass meta.next_cursor back as cursor to fetch the next page; meta.total is included on the first page.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //
-//	@Success		200	{object} Response{data=[]UserResponse} "Successfully retrieved users"
+// @Param username query string false "Username prefix to filter users"
+// @Param includeRecords query bool false "Include each user's reading records"
+// @Param sort query string false "Sort field" Enums(id, username)
+// @Param order query string false "Sort direction" Enums(asc, desc)
+// @Param limit query int false "Page size (1-200, default 50)"
+// @Param cursor query string false "Cursor from the previous page"
+//
+//	@Success		200	{object} Response{data=[]UserResponse,meta=Meta} "Successfully retrieved users"
+//	@Failure		400	{object} Response "Invalid request parameters"
 //	@Failure		500	{object} Response "Internal server error"
 //	@Router			/users [get]
 func (handler *UserHandler) GetUsers(c *gin.Context) {
-	var users []model.User
-
-	result := handler.db.Preload("Records").Find(&users)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to retrieve users: " + result.Error.Error(),
-		})
+	page, err := parsePageRequest(c, userSorts, "id")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	query := repository.UserQuery{
+		UserFilter:  repository.UserFilter{UsernamePrefix: c.Query("username")},
+		Page:        page.page(),
+		WithRecords: c.Query("includeRecords") == "true",
+	}
+
+	users, err := handler.users.List(c.Request.Context(), query)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
+	meta := &Meta{Limit: page.limit}
+	users, meta.NextCursor = page.trim(users)
+
+	// Counting is only worth it once, when the client starts paging
+	if page.after == nil {
+		total, err := handler.users.Count(c.Request.Context(), query.UserFilter)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		meta.Total = &total
+	}
+
 	// Return users without password fields
 	c.JSON(http.StatusOK, Response{
 		Success: true,
 		Data:    toResponseArray(users),
-		Message: "Users retrieved successfully",
+		Meta:    meta,
+		Message: translate(c, "user.listed"),
 	})
 }
 
@@ -102,29 +146,13 @@ func (handler *UserHandler) GetUser(c *gin.Context) {
 	// Validate ID
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid user ID format",
-		})
+		c.Error(invalidParameter("param.invalidUserID", nil))
 		return
 	}
 
-	var user model.User
-	result := handler.db.Preload("Records").First(&user, id)
-
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, Response{
-				Success: false,
-				Error:   "User not found",
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to retrieve user: " + result.Error.Error(),
-		})
+	user, err := handler.users.Get(c.Request.Context(), uint(id), true)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -132,7 +160,7 @@ func (handler *UserHandler) GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Success: true,
 		Data:    toResponse(user),
-		Message: "User retrieved successfully",
+		Message: translate(c, "user.retrieved"),
 	})
 }
 
@@ -146,9 +174,11 @@ func (handler *UserHandler) GetUser(c *gin.Context) {
 //	@Produce		json
 //
 //	@Param user body model.CreateUser true "User data"
+//	@Param Idempotency-Key header string false "Key that makes retries replay the first response"
 //	@Success		201	{object} Response{data=UserResponse} "User created successfully"
 //	@Failure		400	{object} Response "Invalid request body or validation error"
-//	@Failure		409	{object} Response "Username already exists"
+//	@Failure		409	{object} Response "Username already exists, or a request with the same Idempotency-Key is in progress"
+//	@Failure		422	{object} Response "Idempotency-Key already used for a different request"
 //	@Failure		500	{object} Response "Internal server error"
 //	@Router			/users [post]
 func (handler *UserHandler) CreateUser(c *gin.Context) {
@@ -156,64 +186,14 @@ func (handler *UserHandler) CreateUser(c *gin.Context) {
 
 	// Parse request body
 	if err := c.ShouldBindJSON(&createUser); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid request body: " + err.Error(),
-		})
-		return
-	}
-
-	// Validate username length
-	if len(createUser.Username) < 3 || len(createUser.Username) > 50 {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Username must be between 3 and 50 characters",
-		})
-		return
-	}
-
-	// Validate password length
-	if len(createUser.Password) < 6 {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Password must be at least 6 characters long",
-		})
+		c.Error(service.InvalidInput(err))
 		return
 	}
 
-	// Check if username already exists
-	var existingUser model.User
-	if handler.db.Where("username = ?", createUser.Username).First(&existingUser).RowsAffected > 0 {
-		c.JSON(http.StatusConflict, Response{
-			Success: false,
-			Error:   "Username already exists",
-		})
-		return
-	}
-
-	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(createUser.Password), bcrypt.DefaultCost)
+	// Validate and save the user, hashing the password
+	user, err := handler.users.Create(c.Request.Context(), createUser)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to process password",
-		})
-		return
-	}
-
-	// Create new user
-	user := model.User{
-		Username:       createUser.Username,
-		Password:       string(hashedPassword),
-		FavoriteGenres: createUser.FavoriteGenres,
-	}
-
-	// Save user to database
-	if err := handler.db.Create(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to create user: " + err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
@@ -221,7 +201,7 @@ func (handler *UserHandler) CreateUser(c *gin.Context) {
 	c.JSON(http.StatusCreated, Response{
 		Success: true,
 		Data:    toResponse(user),
-		Message: "User created successfully",
+		Message: translate(c, "user.created"),
 	})
 }
 
@@ -229,7 +209,7 @@ func (handler *UserHandler) CreateUser(c *gin.Context) {
 //
 //	@Summary	Update an existing user
 //	@Schemes
-//	@Description	Updates a user's username, password, and/or favorite genres
+//	@Description	Updates a user's username, password, favorite genres and/or privacy setting
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -248,94 +228,21 @@ func (handler *UserHandler) UpdateUser(c *gin.Context) {
 	// Validate ID
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid user ID format",
-		})
+		c.Error(invalidParameter("param.invalidUserID", nil))
 		return
 	}
 
 	var updateUser model.UpdateUser
 	// Parse request body
 	if err := c.ShouldBindJSON(&updateUser); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid request body: " + err.Error(),
-		})
+		c.Error(service.InvalidInput(err))
 		return
 	}
 
-	// Validate username length
-	if len(updateUser.Username) < 3 || len(updateUser.Username) > 50 {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Username must be between 3 and 50 characters",
-		})
-		return
-	}
-
-	// Validate password length
-	if len(updateUser.Password) < 6 {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Password must be at least 6 characters long",
-		})
-		return
-	}
-
-	// Find user to update
-	var user model.User
-	result := handler.db.First(&user, id)
-
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, Response{
-				Success: false,
-				Error:   "User not found",
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to retrieve user for update: " + result.Error.Error(),
-		})
-		return
-	}
-
-	// Check if the new username already exists for another user
-	if updateUser.Username != user.Username {
-		var existingUser model.User
-		if handler.db.Where("username = ? AND id != ?", updateUser.Username, id).First(&existingUser).RowsAffected > 0 {
-			c.JSON(http.StatusConflict, Response{
-				Success: false,
-				Error:   "Username already exists",
-			})
-			return
-		}
-	}
-
-	// Hash the new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updateUser.Password), bcrypt.DefaultCost)
+	// Save updated user, accepting pending follow requests once the profile is public
+	user, err := handler.users.Update(c.Request.Context(), uint(id), updateUser)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to process password",
-		})
-		return
-	}
-
-	// Update user fields
-	user.Username = updateUser.Username
-	user.Password = string(hashedPassword)
-	user.FavoriteGenres = updateUser.FavoriteGenres
-
-	// Save updated user
-	if err := handler.db.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to update user: " + err.Error(),
-		})
+		c.Error(err)
 		return
 	}
 
@@ -343,7 +250,7 @@ func (handler *UserHandler) UpdateUser(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Success: true,
 		Data:    toResponse(user),
-		Message: "User updated successfully",
+		Message: translate(c, "user.updated"),
 	})
 }
 
@@ -368,46 +275,20 @@ func (handler *UserHandler) DeleteUser(c *gin.Context) {
 	// Validate ID
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid user ID format",
-		})
-		return
-	}
-
-	// Find user to delete
-	var user model.User
-	result := handler.db.First(&user, id)
-
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, Response{
-				Success: false,
-				Error:   "User not found",
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to retrieve user for deletion: " + result.Error.Error(),
-		})
+		c.Error(invalidParameter("param.invalidUserID", nil))
 		return
 	}
 
 	// Delete user
-	if err := handler.db.Delete(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to delete user: " + err.Error(),
-		})
+	if err := handler.users.Delete(c.Request.Context(), uint(id)); err != nil {
+		c.Error(err)
 		return
 	}
 
 	// Return success response
 	c.JSON(http.StatusOK, Response{
 		Success: true,
-		Message: "User deleted successfully",
+		Message: translate(c, "user.deleted"),
 	})
 }
 
@@ -434,50 +315,21 @@ func (handler *UserHandler) AuthenticateUser(c *gin.Context) {
 
 	// Parse request body
 	if err := c.ShouldBindJSON(&credentials); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   "Invalid request body: " + err.Error(),
-		})
+		c.Error(service.InvalidInput(err))
 		return
 	}
 
-	// Find user by username
-	var user model.User
-	result := handler.db.Where("username = ?", credentials.Username).First(&user)
-
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusUnauthorized, Response{
-				Success: false,
-				Error:   "Invalid username or password",
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Authentication error: " + result.Error.Error(),
-		})
-		return
-	}
-
-	// Verify password
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password))
+	// Verify the password and load the user's records
+	user, err := handler.users.Authenticate(c.Request.Context(), credentials.Username, credentials.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, Response{
-			Success: false,
-			Error:   "Invalid username or password",
-		})
+		c.Error(err)
 		return
 	}
 
-	// Get user records
-	handler.db.Model(&user).Association("Records").Find(&user.Records)
-
 	// Return authenticated user without password
 	c.JSON(http.StatusOK, Response{
 		Success: true,
 		Data:    toResponse(user),
-		Message: "Authentication successful",
+		Message: translate(c, "user.authenticated"),
 	})
 }
@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"biblia-be/internal/i18n"
+	"biblia-be/internal/repository"
+	"biblia-be/internal/service"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testResponse is Response with the data left undecoded
+type testResponse struct {
+	Success   bool                 `json:"success"`
+	Error     string               `json:"error"`
+	Code      string               `json:"code"`
+	Fields    []service.FieldError `json:"fields"`
+	RequestID string               `json:"requestId"`
+	Data      json.RawMessage      `json:"data"`
+	Meta      *Meta                `json:"meta"`
+}
+
+// serve sends a request to router and decodes the response envelope
+func serve(t *testing.T, router *gin.Engine, method, target string, body interface{}) (int, testResponse) {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, target, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var resp testResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: invalid response %q", method, target, rec.Body.String())
+	}
+	return rec.Code, resp
+}
+
+// decode unmarshals the data of a response
+func decode[T any](t *testing.T, resp testResponse) T {
+	t.Helper()
+	var data T
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		t.Fatalf("invalid data %s: %v", resp.Data, err)
+	}
+	return data
+}
+
+func newUserRouter() (*gin.Engine, *repository.MemoryUserRepository) {
+	gin.SetMode(gin.TestMode)
+	users := repository.NewMemoryUserRepository(repository.NewMemoryRecordRepository())
+	handler := UserHandler{users: service.NewUserService(users)}
+
+	router := gin.New()
+	router.Use(RequestID(), Localize(), ErrorHandler())
+	router.GET("users", handler.GetUsers)
+	router.GET("users/:id", handler.GetUser)
+	router.POST("users", handler.CreateUser)
+	router.PUT("users/:id", handler.UpdateUser)
+	router.DELETE("users/:id", handler.DeleteUser)
+	router.POST("auth/login", handler.AuthenticateUser)
+	return router, users
+}
+
+func TestCreateUser(t *testing.T) {
+	router, _ := newUserRouter()
+
+	code, resp := serve(t, router, http.MethodPost, "/users", gin.H{"username": "somchai", "password": "secret1"})
+	if code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", code, resp.Error)
+	}
+	user := decode[UserResponse](t, resp)
+	if user.ID == 0 || user.Username != "somchai" || user.Privacy != "public" {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if bytes.Contains(resp.Data, []byte("secret1")) || bytes.Contains(resp.Data, []byte("password")) {
+		t.Errorf("response leaks the password: %s", resp.Data)
+	}
+
+	code, resp = serve(t, router, http.MethodPost, "/users", gin.H{"username": "SomChai", "password": "secret2"})
+	if code != http.StatusConflict || resp.Code != service.CodeUsernameTaken || resp.RequestID == "" {
+		t.Errorf("duplicate username: got %d %+v, want 409 %s with a request ID", code, resp, service.CodeUsernameTaken)
+	}
+}
+
+func TestCreateUserValidation(t *testing.T) {
+	router, _ := newUserRouter()
+
+	tests := []struct {
+		name  string
+		body  gin.H
+		field string
+		rule  string
+	}{
+		{"missing password", gin.H{"username": "somchai"}, "password", "required"},
+		{"short username", gin.H{"username": "ab", "password": "secret1"}, "username", "min"},
+		{"short password", gin.H{"username": "somchai", "password": "12345"}, "password", "min"},
+		{"unknown privacy", gin.H{"username": "somchai", "password": "secret1", "privacy": "friends"}, "privacy", "oneof"},
+		{"wrong type", gin.H{"username": 42, "password": "secret1"}, "username", "type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := serve(t, router, http.MethodPost, "/users", tt.body)
+			if code != http.StatusBadRequest || resp.Code != service.CodeValidation {
+				t.Fatalf("got %d %s, want 400 %s", code, resp.Code, service.CodeValidation)
+			}
+			if len(resp.Fields) != 1 || resp.Fields[0].Field != tt.field || resp.Fields[0].Code != tt.rule {
+				t.Errorf("fields: got %+v, want %s %s", resp.Fields, tt.field, tt.rule)
+			}
+		})
+	}
+
+	code, resp := serve(t, router, http.MethodPost, "/users", "{")
+	if code != http.StatusBadRequest || resp.Code != service.CodeInvalidBody || strings.Contains(resp.Error, "json:") {
+		t.Errorf("malformed body: got %d %+v", code, resp)
+	}
+}
+
+func TestLocalizedMessages(t *testing.T) {
+	router, _ := newUserRouter()
+	serve(t, router, http.MethodPost, "/users", gin.H{"username": "somchai", "password": "secret1"})
+
+	tests := []struct {
+		language string
+		body     gin.H
+		want     string
+		field    string
+	}{
+		{"th-TH,th;q=0.9", gin.H{"username": "somchai", "password": "secret1"}, i18n.T("th", "error.USERNAME_TAKEN", nil), ""},
+		{"en-US", gin.H{"username": "somchai", "password": "secret1"}, "Username already exists", ""},
+		{"th", gin.H{"username": "ab", "password": "secret1"}, "username ต้องมีความยาวอย่างน้อย 3 ตัวอักษร", "username ต้องมีความยาวอย่างน้อย 3 ตัวอักษร"},
+		{"fr", gin.H{"username": "ab", "password": "secret1"}, "username must be at least 3 characters long", "username must be at least 3 characters long"},
+	}
+	for _, tt := range tests {
+		var payload bytes.Buffer
+		json.NewEncoder(&payload).Encode(tt.body)
+		req := httptest.NewRequest(http.MethodPost, "/users", &payload)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", tt.language)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		var resp testResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.language, resp.Error, tt.want)
+		}
+		if tt.field != "" && (len(resp.Fields) != 1 || resp.Fields[0].Message != tt.field) {
+			t.Errorf("%s: got fields %+v, want %q", tt.language, resp.Fields, tt.field)
+		}
+	}
+}
+
+func TestGetUsersPagination(t *testing.T) {
+	router, _ := newUserRouter()
+	for _, name := range []string{"dao", "dara", "anong", "darunee", "kittipong"} {
+		if code, resp := serve(t, router, http.MethodPost, "/users", gin.H{"username": name, "password": "secret1"}); code != http.StatusCreated {
+			t.Fatalf("create %s: got %d %s", name, code, resp.Error)
+		}
+	}
+
+	var names []string
+	target := "/users?username=DA&sort=username&limit=2"
+	for pages := 0; target != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination does not end")
+		}
+		code, resp := serve(t, router, http.MethodGet, target, nil)
+		if code != http.StatusOK {
+			t.Fatalf("list: got %d %s", code, resp.Error)
+		}
+		if pages == 0 && (resp.Meta.Total == nil || *resp.Meta.Total != 3) {
+			t.Errorf("first page total: got %v, want 3", resp.Meta.Total)
+		}
+		if pages > 0 && resp.Meta.Total != nil {
+			t.Error("total is only computed for the first page")
+		}
+		for _, user := range decode[[]UserResponse](t, resp) {
+			names = append(names, user.Username)
+		}
+		target = ""
+		if resp.Meta.NextCursor != "" {
+			target = "/users?username=DA&sort=username&limit=2&cursor=" + resp.Meta.NextCursor
+		}
+	}
+
+	want := []string{"dao", "dara", "darunee"}
+	if len(names) != len(want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got %v, want %v", names, want)
+		}
+	}
+
+	if code, _ := serve(t, router, http.MethodGet, "/users?sort=username&cursor=bogus", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid cursor: got %d, want 400", code)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	router, _ := newUserRouter()
+	serve(t, router, http.MethodPost, "/users", gin.H{"username": "malee", "password": "secret1"})
+	_, resp := serve(t, router, http.MethodPost, "/users", gin.H{"username": "somsri", "password": "secret1"})
+	somsri := decode[UserResponse](t, resp)
+	target := "/users/" + strconv.FormatUint(uint64(somsri.ID), 10)
+
+	code, _ := serve(t, router, http.MethodPut, target, gin.H{"username": "Malee", "password": "secret2"})
+	if code != http.StatusConflict {
+		t.Errorf("taken username: got %d, want 409", code)
+	}
+
+	code, resp = serve(t, router, http.MethodPut, target, gin.H{"username": "somsri_k", "password": "secret2", "privacy": "private"})
+	if code != http.StatusOK {
+		t.Fatalf("update: got %d %s", code, resp.Error)
+	}
+	if user := decode[UserResponse](t, resp); user.Username != "somsri_k" || user.Privacy != "private" {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	code, _ = serve(t, router, http.MethodPost, "/auth/login", gin.H{"username": "somsri_k", "password": "secret2"})
+	if code != http.StatusOK {
+		t.Errorf("login with the new password: got %d, want 200", code)
+	}
+
+	if code, _ := serve(t, router, http.MethodPut, "/users/999", gin.H{"username": "nobody", "password": "secret2"}); code != http.StatusNotFound {
+		t.Errorf("missing user: got %d, want 404", code)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	router, _ := newUserRouter()
+	_, resp := serve(t, router, http.MethodPost, "/users", gin.H{"username": "prasert", "password": "secret1"})
+	target := "/users/" + strconv.FormatUint(uint64(decode[UserResponse](t, resp).ID), 10)
+
+	if code, _ := serve(t, router, http.MethodDelete, target, nil); code != http.StatusOK {
+		t.Fatalf("delete: got %d, want 200", code)
+	}
+	if code, _ := serve(t, router, http.MethodGet, target, nil); code != http.StatusNotFound {
+		t.Errorf("get deleted: got %d, want 404", code)
+	}
+	if code, _ := serve(t, router, http.MethodDelete, target, nil); code != http.StatusNotFound {
+		t.Errorf("delete twice: got %d, want 404", code)
+	}
+	if code, _ := serve(t, router, http.MethodGet, "/users/abc", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid id: got %d, want 400", code)
+	}
+}
+
+func TestAuthenticateUser(t *testing.T) {
+	router, users := newUserRouter()
+	serve(t, router, http.MethodPost, "/users", gin.H{"username": "wanida", "password": "secret1"})
+
+	code, resp := serve(t, router, http.MethodPost, "/auth/login", gin.H{"username": "wanida", "password": "secret1"})
+	if code != http.StatusOK {
+		t.Fatalf("login: got %d %s", code, resp.Error)
+	}
+	if user := decode[UserResponse](t, resp); user.Username != "wanida" {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	stored, err := users.GetByUsername(context.Background(), "wanida", false)
+	if err != nil || stored.SyncKey == "" || stored.Password == "secret1" {
+		t.Errorf("password and sync key must be stored hashed: %+v, %v", stored, err)
+	}
+
+	for _, credentials := range []gin.H{
+		{"username": "wanida", "password": "wrong1"},
+		{"username": "nobody", "password": "secret1"},
+	} {
+		if code, _ := serve(t, router, http.MethodPost, "/auth/login", credentials); code != http.StatusUnauthorized {
+			t.Errorf("login %v: got %d, want 401", credentials, code)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package i18n holds the message catalogs of the API and picks the language of
+// a response from the Accept-Language header.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is the last step of every fallback chain
+const DefaultLocale = "en"
+
+// Params fill the {name} placeholders of a message
+type Params map[string]string
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogs maps a locale to its messages by key
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	catalogs := make(map[string]map[string]string, len(files))
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("locale %s: %v", file.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(file.Name(), ".json")] = messages
+	}
+	return catalogs
+}
+
+// Locales returns the supported locales in alphabetical order
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Keys returns the message keys of a locale in alphabetical order
+func Keys(locale string) []string {
+	keys := make([]string, 0, len(catalogs[locale]))
+	for key := range catalogs[locale] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fallbacks returns the locales to try for a message: the locale itself, its
+// base language (th for th-TH) and the default locale
+func fallbacks(locale string) []string {
+	chain := []string{locale}
+	if base, _, ok := strings.Cut(locale, "-"); ok {
+		chain = append(chain, base)
+	}
+	return append(chain, DefaultLocale)
+}
+
+// T returns the message for key in locale, filling its placeholders. Missing
+// messages fall back along the chain of the locale and finally to the key.
+func T(locale, key string, params Params) string {
+	message := key
+	for _, candidate := range fallbacks(strings.ToLower(locale)) {
+		if m, ok := catalogs[candidate][key]; ok {
+			message = m
+			break
+		}
+	}
+
+	for name, value := range params {
+		message = strings.ReplaceAll(message, "{"+name+"}", value)
+	}
+	return message
+}
+
+// match returns the supported locale serving a language tag: the tag itself or
+// its base language
+func match(tag string) (string, bool) {
+	tag = strings.ToLower(tag)
+	if _, ok := catalogs[tag]; ok {
+		return tag, true
+	}
+	if base, _, ok := strings.Cut(tag, "-"); ok {
+		if _, ok := catalogs[base]; ok {
+			return base, true
+		}
+	}
+	return "", false
+}
+
+// Negotiate picks the supported locale a client prefers most from an
+// Accept-Language header such as "th-TH,th;q=0.9,en;q=0.8", defaulting to
+// DefaultLocale when none of its languages are supported
+func Negotiate(acceptLanguage string) string {
+	best, bestQuality := DefaultLocale, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, quality := parseLanguage(part)
+		if tag == "" || tag == "*" || quality <= bestQuality {
+			continue
+		}
+		if locale, ok := match(tag); ok {
+			best, bestQuality = locale, quality
+		}
+	}
+	return best
+}
+
+// parseLanguage splits one entry of an Accept-Language header into its tag and
+// quality, returning a zero quality for malformed weights
+func parseLanguage(part string) (string, float64) {
+	tag, weight, hasWeight := strings.Cut(part, ";")
+	tag = strings.TrimSpace(tag)
+	if !hasWeight {
+		return tag, 1
+	}
+
+	value, ok := strings.CutPrefix(strings.TrimSpace(weight), "q=")
+	if !ok {
+		return tag, 0
+	}
+	quality, err := strconv.ParseFloat(value, 64)
+	if err != nil || quality < 0 || quality > 1 {
+		return tag, 0
+	}
+	return tag, quality
+}
@@ -0,0 +1,100 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+)
+
+var placeholder = regexp.MustCompile(`\{\w+\}`)
+
+// placeholders returns the sorted placeholders of a message
+func placeholders(message string) []string {
+	names := placeholder.FindAllString(message, -1)
+	sort.Strings(names)
+	return names
+}
+
+func TestEveryKeyInEveryLocale(t *testing.T) {
+	if len(Locales()) < 2 {
+		t.Fatalf("expected several locales, got %v", Locales())
+	}
+
+	keys := make(map[string]bool)
+	for _, locale := range Locales() {
+		for _, key := range Keys(locale) {
+			keys[key] = true
+		}
+	}
+
+	for _, locale := range Locales() {
+		for key := range keys {
+			message, ok := catalogs[locale][key]
+			if !ok {
+				t.Errorf("%s: missing %q", locale, key)
+				continue
+			}
+			if message == "" {
+				t.Errorf("%s: empty %q", locale, key)
+			}
+
+			// Translations must fill the same placeholders as the default
+			want := placeholders(catalogs[DefaultLocale][key])
+			got := placeholders(message)
+			if len(got) != len(want) {
+				t.Errorf("%s: %q has placeholders %v, want %v", locale, key, got, want)
+				continue
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("%s: %q has placeholders %v, want %v", locale, key, got, want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"th", "th"},
+		{"th-TH,th;q=0.9,en;q=0.8", "th"},
+		{"TH-th", "th"},
+		{"en-US,th;q=0.5", "en"},
+		{"fr-FR,th;q=0.3", "th"},
+		{"fr, de;q=0.8", "en"},
+		{"en;q=0.2, th;q=0.7", "th"},
+		{"th;q=0, en", "en"},
+		{"th;q=bogus", "en"},
+		{"*", "en"},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestFallbacks(t *testing.T) {
+	catalogs[DefaultLocale]["test.onlyEnglish"] = "Only {what} in English"
+	defer delete(catalogs[DefaultLocale], "test.onlyEnglish")
+
+	tests := []struct {
+		locale, key, want string
+	}{
+		{"th", "user.created", catalogs["th"]["user.created"]},
+		{"th-TH", "user.created", catalogs["th"]["user.created"]},
+		{"th", "test.onlyEnglish", "Only this in English"},
+		{"ja", "user.created", catalogs[DefaultLocale]["user.created"]},
+		{"th", "missing.key", "missing.key"},
+	}
+	for _, tt := range tests {
+		if got := T(tt.locale, tt.key, Params{"what": "this"}); got != tt.want {
+			t.Errorf("T(%q, %q) = %q, want %q", tt.locale, tt.key, got, tt.want)
+		}
+	}
+}
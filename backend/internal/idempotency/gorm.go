@@ -0,0 +1,112 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// keyRow is a row of the idempotency_keys table created by the schema migrations
+type keyRow struct {
+	ID          string `gorm:"primaryKey"`
+	RequestHash string
+	Status      int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+func (keyRow) TableName() string {
+	return "idempotency_keys"
+}
+
+// GormStore keeps entries in the database, so every instance of the API sees
+// the same keys
+type GormStore struct {
+	db        *gorm.DB
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+// NewGormStore uses the idempotency_keys table of db
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Reserve claims a key for a request. The insert and the primary key decide
+// between concurrent requests with the same key.
+func (s *GormStore) Reserve(ctx context.Context, key string, entry Entry) (Entry, bool, error) {
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+	s.purge(db, now)
+
+	if err := db.Where("id = ? AND expires_at <= ?", key, now).Delete(&keyRow{}).Error; err != nil {
+		return Entry{}, false, err
+	}
+
+	row := keyRow{
+		ID:          key,
+		RequestHash: entry.RequestHash,
+		Status:      entry.Status,
+		ContentType: entry.ContentType,
+		Body:        entry.Body,
+		ExpiresAt:   entry.ExpiresAt,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if result.Error != nil {
+		return Entry{}, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return entry, true, nil
+	}
+
+	var existing keyRow
+	if err := db.Where("id = ?", key).Take(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Entry{}, false, errors.New("idempotency key was released while being reserved")
+		}
+		return Entry{}, false, err
+	}
+	return Entry{
+		RequestHash: existing.RequestHash,
+		Status:      existing.Status,
+		ContentType: existing.ContentType,
+		Body:        existing.Body,
+		ExpiresAt:   existing.ExpiresAt,
+	}, false, nil
+}
+
+// Complete stores the response of a reserved key
+func (s *GormStore) Complete(ctx context.Context, key string, entry Entry) error {
+	return s.db.WithContext(ctx).Model(&keyRow{}).Where("id = ?", key).
+		Updates(map[string]interface{}{
+			"status":       entry.Status,
+			"content_type": entry.ContentType,
+			"body":         entry.Body,
+			"expires_at":   entry.ExpiresAt,
+		}).Error
+}
+
+// Release frees a reserved key
+func (s *GormStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("id = ?", key).Delete(&keyRow{}).Error
+}
+
+// purge deletes expired rows at most once per purgeInterval. Failures are left
+// for the next sweep since expired rows are ignored anyway.
+func (s *GormStore) purge(db *gorm.DB, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastPurge) < purgeInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPurge = now
+	s.mu.Unlock()
+
+	db.Where("expires_at <= ?", now).Delete(&keyRow{})
+}
@@ -0,0 +1,46 @@
+// Package idempotency remembers the responses of requests sent with an
+// Idempotency-Key header, so clients can retry them without repeating their
+// effects.
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultTTL is how long a response is kept for replay
+const DefaultTTL = 24 * time.Hour
+
+// LockTimeout bounds how long a key stays reserved by a request that never
+// completes, such as one interrupted by a crash, before it can be reused
+const LockTimeout = time.Minute
+
+// purgeInterval is how often the stores sweep out expired entries
+const purgeInterval = time.Hour
+
+// Entry is the request made with a key and, once completed, its response.
+// An entry without a status is still in progress.
+type Entry struct {
+	RequestHash string
+	Status      int
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// Completed reports whether the response of the entry has been stored
+func (e Entry) Completed() bool {
+	return e.Status != 0
+}
+
+// Store keeps entries by key until they expire. Keys are opaque to the store;
+// callers scope them to an endpoint.
+type Store interface {
+	// Reserve claims a key for a request. When the key is already held by an
+	// entry that has not expired, it returns that entry and false.
+	Reserve(ctx context.Context, key string, entry Entry) (Entry, bool, error)
+	// Complete stores the response of a reserved key
+	Complete(ctx context.Context, key string, entry Entry) error
+	// Release frees a reserved key so the request can be sent again
+	Release(ctx context.Context, key string) error
+}
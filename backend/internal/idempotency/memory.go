@@ -0,0 +1,62 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps entries in process. It suits tests and single instance
+// deployments; entries are lost on restart.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]Entry
+	lastPurge time.Time
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry), lastPurge: time.Now()}
+}
+
+// Reserve claims a key for a request
+func (s *MemoryStore) Reserve(ctx context.Context, key string, entry Entry) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPurge) >= purgeInterval {
+		for k, e := range s.entries {
+			if !e.ExpiresAt.After(now) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastPurge = now
+	}
+
+	if existing, ok := s.entries[key]; ok && existing.ExpiresAt.After(now) {
+		return existing, false, nil
+	}
+	s.entries[key] = entry
+	return entry, true, nil
+}
+
+// Complete stores the response of a reserved key
+func (s *MemoryStore) Complete(ctx context.Context, key string, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; ok {
+		s.entries[key] = entry
+	}
+	return nil
+}
+
+// Release frees a reserved key
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+// MaxPixels bounds the decoded size of an image to guard against decompression bombs
+const MaxPixels = 40_000_000
+
+// ErrTooLarge is returned for images whose dimensions exceed MaxPixels
+var ErrTooLarge = errors.New("image dimensions too large")
+
+// Decode reads a JPEG or PNG image after checking its dimensions
+func Decode(data []byte) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	return img, format, nil
+}
+
+// Thumbnail scales img down to the given width, keeping the aspect ratio, and
+// flattens transparency onto white. Images already narrower than width keep
+// their original size.
+func Thumbnail(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= width {
+		return resize(img, srcW, srcH)
+	}
+
+	height := srcH * width / srcW
+	if height < 1 {
+		height = 1
+	}
+	return resize(img, width, height)
+}
+
+// EncodeJPEG writes img as a JPEG
+func EncodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+}
+
+// resize downsamples with an area-averaging (box) filter, which is cheap and
+// avoids the aliasing of nearest neighbour when shrinking by large factors
+func resize(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcH/height
+		y1 := bounds.Min.Y + (y+1)*srcH/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcW/width
+			x1 := bounds.Min.X + (x+1)*srcW/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+
+			// Colors are alpha-premultiplied, so adding the uncovered
+			// fraction of white composites the pixel onto a white background
+			r, g, b, a = r/n, g/n, b/n, a/n
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8((r + 0xffff - a) >> 8),
+				G: uint8((g + 0xffff - a) >> 8),
+				B: uint8((b + 0xffff - a) >> 8),
+				A: 0xff,
+			})
+		}
+	}
+
+	return dst
+}
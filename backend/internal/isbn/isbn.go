@@ -0,0 +1,127 @@
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is returned for strings that are not a valid ISBN-10 or ISBN-13
+var ErrInvalid = errors.New("invalid ISBN")
+
+// ErrNotBook is returned for valid EAN-13 codes outside the Bookland prefixes
+var ErrNotBook = errors.New("EAN-13 is not an ISBN")
+
+// Normalize strips separators and returns the ISBN-13 form of an ISBN-10 or ISBN-13,
+// validating its check digit
+func Normalize(s string) (string, error) {
+	s = strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, s))
+
+	switch len(s) {
+	case 10:
+		if !ValidISBN10(s) {
+			return "", ErrInvalid
+		}
+		return FromISBN10(s), nil
+	case 13:
+		if !ValidEAN13(s) {
+			return "", ErrInvalid
+		}
+		if !strings.HasPrefix(s, "978") && !strings.HasPrefix(s, "979") {
+			return "", ErrNotBook
+		}
+		return s, nil
+	}
+	return "", ErrInvalid
+}
+
+// ValidEAN13 checks the digits and check digit of an EAN-13 code
+func ValidEAN13(s string) bool {
+	if len(s) != 13 || !allDigits(s) {
+		return false
+	}
+	return EAN13CheckDigit(s[:12]) == s[12]
+}
+
+// EAN13CheckDigit computes the check digit of the first 12 digits of an EAN-13
+func EAN13CheckDigit(s string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(s[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// ValidISBN10 checks the digits and check character of an ISBN-10
+func ValidISBN10(s string) bool {
+	if len(s) != 10 || !allDigits(s[:9]) {
+		return false
+	}
+	last := s[9]
+	if last != 'X' && (last < '0' || last > '9') {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(s[i]-'0') * (10 - i)
+	}
+	if last == 'X' {
+		sum += 10
+	} else {
+		sum += int(last - '0')
+	}
+	return sum%11 == 0
+}
+
+// FromISBN10 converts a valid ISBN-10 to ISBN-13
+func FromISBN10(s string) string {
+	body := "978" + s[:9]
+	return body + string(EAN13CheckDigit(body))
+}
+
+// ToISBN10 converts a 978-prefixed ISBN-13 to ISBN-10. ISBNs with the 979 prefix
+// have no ISBN-10 form and return "".
+func ToISBN10(s string) string {
+	if len(s) != 13 || !strings.HasPrefix(s, "978") {
+		return ""
+	}
+
+	body := s[3:12]
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X"
+	}
+	return body + string(byte('0'+check))
+}
+
+// Variants returns the forms an ISBN may have been stored in: ISBN-13 and, when
+// one exists, ISBN-10
+func Variants(isbn13 string) []string {
+	variants := []string{isbn13}
+	if isbn10 := ToISBN10(isbn13); isbn10 != "" {
+		variants = append(variants, isbn10)
+	}
+	return variants
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
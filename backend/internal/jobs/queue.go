@@ -0,0 +1,404 @@
+package jobs
+
+import (
+	"biblia-be/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnknownType is returned when enqueuing a job type without a registered handler
+var ErrUnknownType = errors.New("unknown job type")
+
+// internalErrorMessage is shown for failures that do not carry a message meant
+// for users
+const internalErrorMessage = "The job failed because of an internal error"
+
+// Error is a job failure with a message that may be shown to users. Other
+// errors are logged and reported as an internal error, as they can reveal
+// details of the database or the filesystem.
+type Error struct {
+	Message string
+	Err     error
+}
+
+// Fail wraps err with a message users may see as the job's last error
+func Fail(message string, err error) error {
+	return &Error{Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// publicMessage returns the message of a failure that is safe to show to users
+func publicMessage(err error) string {
+	var jobErr *Error
+	if errors.As(err, &jobErr) {
+		return jobErr.Message
+	}
+	return internalErrorMessage
+}
+
+// HandlerFunc executes one attempt of a job. The returned result is stored as JSON
+// on the job when the attempt succeeds.
+type HandlerFunc func(ctx context.Context, task *Task) (interface{}, error)
+
+// Options tunes the worker pool
+type Options struct {
+	Workers      int
+	MaxAttempts  int
+	PollInterval time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// StaleAfter is how long a running job may go without a heartbeat before it is
+	// considered abandoned by a crashed worker and requeued
+	StaleAfter time.Duration
+}
+
+// Queue is a database backed job queue with an in-process worker pool
+type Queue struct {
+	db       *gorm.DB
+	opts     Options
+	handlers map[string]HandlerFunc
+
+	mu      sync.Mutex
+	running map[uint]context.CancelFunc
+
+	wake chan struct{}
+	wg   sync.WaitGroup
+	stop context.CancelFunc
+}
+
+// NewQueue creates a queue, filling unset options with defaults
+func NewQueue(db *gorm.DB, opts Options) *Queue {
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 5 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 10 * time.Minute
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = 10 * time.Minute
+	}
+
+	return &Queue{
+		db:       db,
+		opts:     opts,
+		handlers: make(map[string]HandlerFunc),
+		running:  make(map[uint]context.CancelFunc),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register binds a handler to a job type. It must be called before Start.
+func (q *Queue) Register(jobType string, fn HandlerFunc) {
+	q.handlers[jobType] = fn
+}
+
+// Enqueue stores a new job and wakes an idle worker
+func (q *Queue) Enqueue(jobType string, userID uint, payload interface{}) (*model.Job, error) {
+	if _, ok := q.handlers[jobType]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, jobType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := model.Job{
+		Type:        jobType,
+		UserID:      userID,
+		Status:      model.JobQueued,
+		Payload:     string(data),
+		MaxAttempts: q.opts.MaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := q.db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return &job, nil
+}
+
+// Get loads a job by id
+func (q *Queue) Get(id uint) (*model.Job, error) {
+	var job model.Job
+	if err := q.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Cancel requests cancellation of a job. Queued jobs are cancelled immediately;
+// running jobs are interrupted through their context and marked cancelled by the
+// worker once the handler returns.
+func (q *Queue) Cancel(id uint) (*model.Job, error) {
+	job, err := q.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return job, nil
+	}
+
+	now := time.Now()
+	result := q.db.Model(&model.Job{}).
+		Where("id = ? AND status = ?", id, model.JobQueued).
+		Updates(map[string]interface{}{
+			"status":           model.JobCancelled,
+			"cancel_requested": true,
+			"finished_at":      now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		if err := q.db.Model(&model.Job{}).Where("id = ?", id).
+			Update("cancel_requested", true).Error; err != nil {
+			return nil, err
+		}
+
+		q.mu.Lock()
+		if cancel, ok := q.running[id]; ok {
+			cancel()
+		}
+		q.mu.Unlock()
+	}
+
+	return q.Get(id)
+}
+
+// Start launches the worker pool. Workers stop when ctx is cancelled or Stop is called.
+func (q *Queue) Start(ctx context.Context) {
+	ctx, q.stop = context.WithCancel(ctx)
+
+	q.requeueStale()
+
+	for i := 0; i < q.opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+	log.Printf("job queue started with %d workers", q.opts.Workers)
+}
+
+// Stop cancels running jobs and waits for the workers to exit
+func (q *Queue) Stop() {
+	if q.stop != nil {
+		q.stop()
+	}
+	q.wg.Wait()
+}
+
+// requeueStale resets running jobs whose heartbeat is older than StaleAfter
+func (q *Queue) requeueStale() {
+	cutoff := time.Now().Add(-q.opts.StaleAfter)
+	result := q.db.Model(&model.Job{}).
+		Where("status = ? AND updated_at < ?", model.JobRunning, cutoff).
+		Updates(map[string]interface{}{"status": model.JobQueued, "run_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("failed to requeue stale jobs: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("requeued %d stale jobs", result.RowsAffected)
+	}
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before waiting again
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			job, err := q.claim()
+			if err != nil {
+				log.Printf("failed to claim job: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			q.execute(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// claim atomically moves the next due job from queued to running
+func (q *Queue) claim() (*model.Job, error) {
+	for {
+		var job model.Job
+		result := q.db.Where("status = ? AND run_at <= ?", model.JobQueued, time.Now()).
+			Order("run_at, id").
+			Limit(1).
+			Find(&job)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, nil
+		}
+
+		now := time.Now()
+		claimed := q.db.Model(&model.Job{}).
+			Where("id = ? AND status = ?", job.ID, model.JobQueued).
+			Updates(map[string]interface{}{
+				"status":     model.JobRunning,
+				"attempts":   gorm.Expr("attempts + 1"),
+				"started_at": now,
+			})
+		if claimed.Error != nil {
+			return nil, claimed.Error
+		}
+
+		// Another worker won the race; look for the next one
+		if claimed.RowsAffected == 0 {
+			continue
+		}
+
+		job.Status = model.JobRunning
+		job.Attempts++
+		job.StartedAt = &now
+		return &job, nil
+	}
+}
+
+func (q *Queue) execute(parent context.Context, job *model.Job) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+	}()
+
+	task := &Task{Job: job, queue: q, cancel: cancel}
+	result, err := q.run(ctx, task)
+
+	now := time.Now()
+	updates := map[string]interface{}{}
+	if err != nil && parent.Err() == nil {
+		log.Printf("job %d (%s) attempt %d failed: %v", job.ID, job.Type, job.Attempts, err)
+	}
+
+	switch {
+	case err == nil:
+		data, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			data = []byte("null")
+		}
+		updates["status"] = model.JobSucceeded
+		updates["progress"] = 100
+		updates["result"] = string(data)
+		updates["last_error"] = ""
+		updates["finished_at"] = now
+
+	case parent.Err() == nil && q.cancelRequested(job.ID):
+		updates["status"] = model.JobCancelled
+		updates["last_error"] = "cancelled"
+		updates["finished_at"] = now
+
+	case parent.Err() != nil:
+		// Shutting down; let another process pick the job up again
+		updates["status"] = model.JobQueued
+		updates["attempts"] = gorm.Expr("attempts - 1")
+		updates["run_at"] = now
+
+	case job.Attempts >= job.MaxAttempts:
+		updates["status"] = model.JobFailed
+		updates["last_error"] = publicMessage(err)
+		updates["finished_at"] = now
+
+	default:
+		updates["status"] = model.JobQueued
+		updates["last_error"] = publicMessage(err)
+		updates["run_at"] = now.Add(q.backoff(job.Attempts))
+	}
+
+	if err := q.db.Model(&model.Job{}).Where("id = ?", job.ID).Updates(updates).Error; err != nil {
+		log.Printf("failed to record outcome of job %d: %v", job.ID, err)
+	}
+}
+
+// cancelRequested reports whether cancellation was requested for the job
+func (q *Queue) cancelRequested(id uint) bool {
+	var job model.Job
+	if err := q.db.Select("cancel_requested").First(&job, id).Error; err != nil {
+		return false
+	}
+	return job.CancelRequested
+}
+
+// run invokes the registered handler, converting panics into errors
+func (q *Queue) run(ctx context.Context, task *Task) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	fn, ok := q.handlers[task.Job.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, task.Job.Type)
+	}
+
+	return fn(ctx, task)
+}
+
+// backoff returns the delay before the next attempt, doubling per attempt
+func (q *Queue) backoff(attempt int) time.Duration {
+	delay := q.opts.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= q.opts.MaxBackoff {
+			return q.opts.MaxBackoff
+		}
+	}
+	return delay
+}
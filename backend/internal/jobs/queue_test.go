@@ -0,0 +1,339 @@
+package jobs
+
+import (
+	"biblia-be/internal/db"
+	"biblia-be/internal/model"
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestQueue returns a queue on a migrated SQLite database. The workers are
+// not started, so tests can claim and execute jobs one at a time.
+func newTestQueue(t *testing.T, opts Options) *Queue {
+	t.Helper()
+	conn, err := db.NewDB(db.DriverSQLite, "", "", "", filepath.Join(t.TempDir(), "jobs.db"), "", 4, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	migrator, err := db.NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return NewQueue(conn, opts)
+}
+
+// reload reads a job back from the database
+func reload(t *testing.T, q *Queue, id uint) *model.Job {
+	t.Helper()
+	job, err := q.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+// waitFor polls a job until it reaches status
+func waitFor(t *testing.T, q *Queue, id uint, status string) *model.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job := reload(t, q, id)
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d: got status %s, want %s", id, job.Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClaimIsExclusive(t *testing.T) {
+	q := newTestQueue(t, Options{})
+	q.Register("noop", func(ctx context.Context, task *Task) (interface{}, error) { return nil, nil })
+
+	const jobs = 20
+	for i := 0; i < jobs; i++ {
+		if _, err := q.Enqueue("noop", 1, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Workers race for the same rows; each job must go to exactly one of them
+	var mu sync.Mutex
+	claimed := map[uint]int{}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := q.claim()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				mu.Lock()
+				claimed[job.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != jobs {
+		t.Fatalf("claimed %d jobs, want %d", len(claimed), jobs)
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Errorf("job %d claimed %d times", id, n)
+		}
+		if job := reload(t, q, id); job.Status != model.JobRunning || job.Attempts != 1 || job.StartedAt == nil {
+			t.Errorf("job %d: got status %s after %d attempts", id, job.Status, job.Attempts)
+		}
+	}
+}
+
+func TestClaimSkipsJobsNotDue(t *testing.T) {
+	q := newTestQueue(t, Options{})
+	q.Register("noop", func(ctx context.Context, task *Task) (interface{}, error) { return nil, nil })
+
+	later, err := q.Enqueue("noop", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.db.Model(&model.Job{}).Where("id = ?", later.ID).Update("run_at", time.Now().Add(time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	now, err := q.Enqueue("noop", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := q.claim()
+	if err != nil || job == nil || job.ID != now.ID {
+		t.Fatalf("got %+v, %v; want job %d", job, err, now.ID)
+	}
+	if job, err := q.claim(); err != nil || job != nil {
+		t.Fatalf("got %+v, %v; want nothing due", job, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	q := NewQueue(nil, Options{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+
+	for _, tc := range tests {
+		if got := q.backoff(tc.attempt); got != tc.want {
+			t.Errorf("backoff(%d) = %v, want %v", tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	q := newTestQueue(t, Options{MaxAttempts: 3, BaseBackoff: time.Minute, MaxBackoff: time.Hour})
+	failures := []error{
+		Fail("Row 3 has no ISBN", errors.New("validate row 3")),
+		errors.New("UNIQUE constraint failed: records.user_id, records.isbn"),
+	}
+	q.Register("flaky", func(ctx context.Context, task *Task) (interface{}, error) {
+		if task.Job.Attempts <= len(failures) {
+			return nil, failures[task.Job.Attempts-1]
+		}
+		return map[string]int{"imported": 2}, nil
+	})
+
+	queued, err := q.Enqueue("flaky", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// runAttempt claims the job as soon as it is queued, however far off its
+	// retry is, and returns it after the attempt
+	runAttempt := func() (*model.Job, time.Time) {
+		t.Helper()
+		if err := q.db.Model(&model.Job{}).Where("id = ?", queued.ID).Update("run_at", time.Now()).Error; err != nil {
+			t.Fatal(err)
+		}
+		job, err := q.claim()
+		if err != nil || job == nil {
+			t.Fatalf("claim: got %+v, %v", job, err)
+		}
+		started := time.Now()
+		q.execute(context.Background(), job)
+		return reload(t, q, queued.ID), started
+	}
+
+	// A failure with a message for users keeps it
+	job, started := runAttempt()
+	if job.Status != model.JobQueued || job.LastError != "Row 3 has no ISBN" {
+		t.Fatalf("first attempt: got %s %q", job.Status, job.LastError)
+	}
+	if delay := job.RunAt.Sub(started); delay < time.Minute || delay > time.Minute+5*time.Second {
+		t.Errorf("first retry in %v, want a minute", delay)
+	}
+
+	// Other failures never reach users, and the delay doubles
+	job, started = runAttempt()
+	if job.Status != model.JobQueued || job.LastError != internalErrorMessage {
+		t.Fatalf("second attempt: got %s %q", job.Status, job.LastError)
+	}
+	if delay := job.RunAt.Sub(started); delay < 2*time.Minute || delay > 2*time.Minute+5*time.Second {
+		t.Errorf("second retry in %v, want two minutes", delay)
+	}
+
+	job, _ = runAttempt()
+	if job.Status != model.JobSucceeded || job.Attempts != 3 || job.Progress != 100 || job.LastError != "" ||
+		job.Result != `{"imported":2}` || job.FinishedAt == nil {
+		t.Errorf("third attempt: got %+v", job)
+	}
+}
+
+func TestFailAfterMaxAttempts(t *testing.T) {
+	q := newTestQueue(t, Options{MaxAttempts: 1})
+	q.Register("panics", func(ctx context.Context, task *Task) (interface{}, error) {
+		panic("open /var/lib/biblia/secret: permission denied")
+	})
+
+	queued, err := q.Enqueue("panics", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := q.claim()
+	if err != nil || job == nil {
+		t.Fatalf("claim: got %+v, %v", job, err)
+	}
+	q.execute(context.Background(), job)
+
+	job = reload(t, q, queued.ID)
+	if job.Status != model.JobFailed || job.LastError != internalErrorMessage || job.FinishedAt == nil {
+		t.Errorf("got %s %q, want failed with the internal error message", job.Status, job.LastError)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	q := newTestQueue(t, Options{Workers: 1, PollInterval: 10 * time.Millisecond})
+	started := make(chan uint, 1)
+	q.Register("blocks", func(ctx context.Context, task *Task) (interface{}, error) {
+		started <- task.Job.ID
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	q.Register("polls", func(ctx context.Context, task *Task) (interface{}, error) {
+		started <- task.Job.ID
+		for ctx.Err() == nil {
+			if err := task.SetProgress(50); err != nil {
+				return nil, err
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil, ctx.Err()
+	})
+
+	// A queued job is cancelled without running
+	queued, err := q.Enqueue("blocks", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := q.Cancel(queued.ID)
+	if err != nil || job.Status != model.JobCancelled || !job.CancelRequested || job.FinishedAt == nil {
+		t.Fatalf("queued: got %+v, %v", job, err)
+	}
+
+	q.Start(context.Background())
+	defer q.Stop()
+
+	// A running job is interrupted through its context
+	running, err := q.Enqueue("blocks", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := <-started; id != running.ID {
+		t.Fatalf("started job %d, want %d", id, running.ID)
+	}
+	if _, err := q.Cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	if job := waitFor(t, q, running.ID, model.JobCancelled); job.LastError != "cancelled" || job.Attempts != 1 {
+		t.Errorf("running: got %q after %d attempts", job.LastError, job.Attempts)
+	}
+
+	// Another process can only set the flag, which the next heartbeat picks up
+	polling, err := q.Enqueue("polls", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := q.db.Model(&model.Job{}).Where("id = ?", polling.ID).Update("cancel_requested", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, q, polling.ID, model.JobCancelled)
+}
+
+func TestRequeueStale(t *testing.T) {
+	q := newTestQueue(t, Options{StaleAfter: time.Minute})
+	q.Register("noop", func(ctx context.Context, task *Task) (interface{}, error) { return nil, nil })
+
+	var ids []uint
+	for i := 0; i < 2; i++ {
+		if _, err := q.Enqueue("noop", 1, nil); err != nil {
+			t.Fatal(err)
+		}
+		job, err := q.claim()
+		if err != nil || job == nil {
+			t.Fatalf("claim: got %+v, %v", job, err)
+		}
+		ids = append(ids, job.ID)
+	}
+	stale, alive := ids[0], ids[1]
+
+	// The worker of the first job stopped sending heartbeats
+	err := q.db.Model(&model.Job{}).Where("id = ?", stale).
+		UpdateColumn("updated_at", time.Now().Add(-time.Hour)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.requeueStale()
+	if job := reload(t, q, stale); job.Status != model.JobQueued || job.RunAt.After(time.Now()) {
+		t.Errorf("stale job: got %s due %v", job.Status, job.RunAt)
+	}
+	if job := reload(t, q, alive); job.Status != model.JobRunning {
+		t.Errorf("live job: got %s, want it left running", job.Status)
+	}
+
+	// The requeued job runs again and keeps count of its attempts
+	job, err := q.claim()
+	if err != nil || job == nil || job.ID != stale {
+		t.Fatalf("claim after requeue: got %+v, %v", job, err)
+	}
+	q.execute(context.Background(), job)
+	if job := reload(t, q, stale); job.Status != model.JobSucceeded || job.Attempts != 2 {
+		t.Errorf("requeued job: got %s after %d attempts", job.Status, job.Attempts)
+	}
+}
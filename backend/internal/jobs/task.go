@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"biblia-be/internal/model"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Task is the view of a running job handed to a HandlerFunc
+type Task struct {
+	Job    *model.Job
+	queue  *Queue
+	cancel context.CancelFunc
+}
+
+// Decode unmarshals the job payload into v
+func (t *Task) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(t.Job.Payload), v)
+}
+
+// SetProgress stores the completion percentage (0-100) and doubles as the job's
+// heartbeat. If cancellation was requested, possibly from another process, the
+// task's context is cancelled so the handler can stop at its next check.
+func (t *Task) SetProgress(percent float64) error {
+	if percent < 0 {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+
+	err := t.queue.db.Model(&model.Job{}).Where("id = ?", t.Job.ID).
+		Updates(map[string]interface{}{"progress": percent, "updated_at": time.Now()}).Error
+	if err != nil {
+		return err
+	}
+	t.Job.Progress = percent
+
+	if t.queue.cancelRequested(t.Job.ID) {
+		t.cancel()
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Package kosync implements the pieces of KOReader's progress sync protocol that
+// are independent of HTTP.
+package kosync
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+)
+
+// PartialMD5 computes KOReader's "binary" document fingerprint: the MD5 of
+// 1 KiB samples taken at offsets 0, 1 KiB, 4 KiB, 16 KiB, ... up to 1 GiB,
+// stopping at the end of the file. Identifying documents this way is cheap
+// even for large files and survives renames.
+func PartialMD5(r io.ReaderAt, size int64) string {
+	const step, sample = 1024, 1024
+
+	hash := md5.New()
+	buf := make([]byte, sample)
+	for i := -1; i <= 10; i++ {
+		// KOReader computes lshift(1024, -2), which LuaJIT wraps to 0
+		var offset int64
+		if i >= 0 {
+			offset = int64(step) << (2 * i)
+		}
+		if offset >= size {
+			break
+		}
+
+		n, err := r.ReadAt(buf, offset)
+		if n > 0 {
+			hash.Write(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// AuthKey is the key KOReader sends for a password: its hex MD5 digest
+func AuthKey(password string) string {
+	sum := md5.Sum([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,394 @@
+// Package legacy imports data from the schema of the old root module, where
+// records were keyed by book and user, books had no ISBN and passwords were
+// stored in plain text.
+package legacy
+
+import (
+	"biblia-be/internal/isbn"
+	"biblia-be/internal/kosync"
+	"biblia-be/internal/model"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type legacyAuthor struct {
+	ID   uint
+	Name string
+}
+
+func (legacyAuthor) TableName() string { return "authors" }
+
+type legacyBook struct {
+	ID       uint
+	Name     string
+	NumPages int32
+	AuthorID uint
+}
+
+func (legacyBook) TableName() string { return "books" }
+
+type legacyUser struct {
+	ID       uint
+	Username string
+	Password string
+}
+
+func (legacyUser) TableName() string { return "users" }
+
+type legacyRecord struct {
+	BookID       uint
+	UserID       uint
+	Status       string
+	CurrProgress int32
+	CurrChapter  int32
+	CreatedAt    time.Time
+	UpdateDate   *time.Time
+	FinishDate   *time.Time
+}
+
+func (legacyRecord) TableName() string { return "records" }
+
+// Imported is a row of legacy_imports, linking a legacy row to the row it became
+type Imported struct {
+	Entity     string `gorm:"primaryKey"`
+	LegacyID   string `gorm:"primaryKey"`
+	NewID      uint
+	ImportedAt time.Time
+}
+
+func (Imported) TableName() string { return "legacy_imports" }
+
+// Imported entities
+const (
+	entityUser   = "user"
+	entityRecord = "record"
+)
+
+// Report describes what an import did, or would do in a dry run
+type Report struct {
+	DryRun bool `json:"dryRun"`
+
+	Users             int      `json:"users"`
+	UsersCreated      int      `json:"usersCreated"`
+	UsersDone         int      `json:"usersAlreadyImported"`
+	UsernameConflicts []string `json:"usernameConflicts"`
+
+	Records        int `json:"records"`
+	RecordsCreated int `json:"recordsCreated"`
+	RecordsDone    int `json:"recordsAlreadyImported"`
+	// RecordsSkipped counts records of users that were not imported and records
+	// of a book the user already has in the backend
+	RecordsSkipped int `json:"recordsSkipped"`
+
+	// BooksWithoutISBN are legacy book IDs missing from the ISBN map. Their
+	// records get a placeholder ISBN of the form legacy-<book id>.
+	BooksWithoutISBN []uint `json:"booksWithoutISBN"`
+	// Statuses counts the legacy status values and what they were mapped to
+	Statuses map[string]int `json:"statuses"`
+}
+
+// Importer copies users and records from a legacy database into the backend
+type Importer struct {
+	source *gorm.DB
+	target *gorm.DB
+	isbns  map[uint]string
+	dryRun bool
+}
+
+// NewImporter creates an importer. isbns maps legacy book IDs to ISBNs and may
+// be nil. A dry run reads both databases and reports without writing anything.
+func NewImporter(source, target *gorm.DB, isbns map[uint]string, dryRun bool) *Importer {
+	return &Importer{source: source, target: target, isbns: isbns, dryRun: dryRun}
+}
+
+// ReadISBNMap reads a CSV of legacy book IDs and ISBNs, with an optional header
+func ReadISBNMap(r io.Reader) (map[uint]string, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	isbns := make(map[uint]string, len(rows))
+	for i, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("line %d: expected book ID and ISBN", i+1)
+		}
+		bookId, err := strconv.ParseUint(strings.TrimSpace(row[0]), 10, 32)
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid book ID %q", i+1, row[0])
+		}
+		normalized, err := isbn.Normalize(strings.TrimSpace(row[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		isbns[uint(bookId)] = normalized
+	}
+	return isbns, nil
+}
+
+// mapStatus translates the free-form legacy statuses to the backend's
+func mapStatus(status string) string {
+	status = strings.ToLower(strings.TrimSpace(status))
+	switch status {
+	case "finished", "finish", "completed", "complete", "done", "read":
+		return model.StatusFinished
+	case "reading", "in progress", "in_progress", "started", "current":
+		return model.StatusReading
+	}
+	return status
+}
+
+// Run imports every legacy user and record that has not been imported yet.
+// Each row is written with its legacy_imports entry in one transaction, so an
+// interrupted import can simply be run again.
+func (importer *Importer) Run() (Report, error) {
+	report := Report{DryRun: importer.dryRun, Statuses: map[string]int{}}
+
+	userIds, err := importer.importUsers(&report)
+	if err != nil {
+		return report, err
+	}
+	if err := importer.importRecords(&report, userIds); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// done returns the legacy IDs of an entity that were already imported
+func (importer *Importer) done(entity string) (map[string]uint, error) {
+	var rows []Imported
+	if err := importer.target.Where("entity = ?", entity).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[string]uint, len(rows))
+	for _, row := range rows {
+		done[row.LegacyID] = row.NewID
+	}
+	return done, nil
+}
+
+// importUsers imports the users and returns the backend ID of every imported
+// legacy user. New users have ID 0 in a dry run.
+func (importer *Importer) importUsers(report *Report) (map[uint]uint, error) {
+	var users []legacyUser
+	if err := importer.source.Order("id").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("reading legacy users: %w", err)
+	}
+	done, err := importer.done(entityUser)
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := make(map[uint]uint, len(users))
+	for _, legacy := range users {
+		report.Users++
+		legacyId := strconv.FormatUint(uint64(legacy.ID), 10)
+		if id, ok := done[legacyId]; ok {
+			userIds[legacy.ID] = id
+			report.UsersDone++
+			continue
+		}
+
+		var taken int64
+		if err := importer.target.Model(&model.User{}).Where("LOWER(username) = LOWER(?)", legacy.Username).Count(&taken).Error; err != nil {
+			return nil, err
+		}
+		if taken > 0 || legacy.Username == "" {
+			report.UsernameConflicts = append(report.UsernameConflicts, legacy.Username)
+			continue
+		}
+
+		if importer.dryRun {
+			userIds[legacy.ID] = 0
+			report.UsersCreated++
+			continue
+		}
+
+		// Legacy passwords are plain text, so they can be hashed like new ones
+		hashed, err := bcrypt.GenerateFromPassword([]byte(legacy.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		syncKey, err := bcrypt.GenerateFromPassword([]byte(kosync.AuthKey(legacy.Password)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		user := model.User{
+			Username: legacy.Username,
+			Password: string(hashed),
+			SyncKey:  string(syncKey),
+			Privacy:  model.PrivacyPublic,
+		}
+		err = importer.target.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			return tx.Create(&Imported{Entity: entityUser, LegacyID: legacyId, NewID: user.ID, ImportedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("importing user %d: %w", legacy.ID, err)
+		}
+		userIds[legacy.ID] = user.ID
+		report.UsersCreated++
+	}
+	return userIds, nil
+}
+
+// importRecords imports the records of imported users, keyed by ISBN
+func (importer *Importer) importRecords(report *Report, userIds map[uint]uint) error {
+	var authors []legacyAuthor
+	if err := importer.source.Find(&authors).Error; err != nil {
+		return fmt.Errorf("reading legacy authors: %w", err)
+	}
+	authorNames := make(map[uint]string, len(authors))
+	for _, author := range authors {
+		authorNames[author.ID] = author.Name
+	}
+
+	var books []legacyBook
+	if err := importer.source.Find(&books).Error; err != nil {
+		return fmt.Errorf("reading legacy books: %w", err)
+	}
+	bookById := make(map[uint]legacyBook, len(books))
+	for _, book := range books {
+		bookById[book.ID] = book
+	}
+
+	var records []legacyRecord
+	if err := importer.source.Order("user_id, book_id").Find(&records).Error; err != nil {
+		return fmt.Errorf("reading legacy records: %w", err)
+	}
+	done, err := importer.done(entityRecord)
+	if err != nil {
+		return err
+	}
+
+	missingISBN := map[uint]bool{}
+	planned := map[string]bool{}
+	for _, legacy := range records {
+		report.Records++
+		legacyId := fmt.Sprintf("%d:%d", legacy.BookID, legacy.UserID)
+		if _, ok := done[legacyId]; ok {
+			report.RecordsDone++
+			continue
+		}
+		userId, ok := userIds[legacy.UserID]
+		if !ok {
+			report.RecordsSkipped++
+			continue
+		}
+
+		book := bookById[legacy.BookID]
+		bookISBN, ok := importer.isbns[legacy.BookID]
+		if !ok {
+			bookISBN = fmt.Sprintf("legacy-%d", legacy.BookID)
+			missingISBN[legacy.BookID] = true
+		}
+		status := mapStatus(legacy.Status)
+		report.Statuses[legacy.Status+" -> "+status]++
+
+		// Two legacy books can map to the same ISBN, and the user may already
+		// have the book in the backend
+		key := fmt.Sprintf("%d:%d:%s", legacy.UserID, userId, bookISBN)
+		if planned[key] {
+			report.RecordsSkipped++
+			continue
+		}
+		planned[key] = true
+		if userId != 0 {
+			var existing int64
+			if err := importer.target.Model(&model.Record{}).Where("user_id = ? AND isbn = ?", userId, bookISBN).Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing > 0 {
+				report.RecordsSkipped++
+				continue
+			}
+		}
+
+		if importer.dryRun {
+			report.RecordsCreated++
+			continue
+		}
+
+		record := newRecord(legacy, book, authorNames[book.AuthorID], userId, bookISBN, status)
+		err := importer.target.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			return tx.Create(&Imported{Entity: entityRecord, LegacyID: legacyId, NewID: record.ID, ImportedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("importing record of book %d for user %d: %w", legacy.BookID, legacy.UserID, err)
+		}
+		report.RecordsCreated++
+	}
+
+	for bookId := range missingISBN {
+		report.BooksWithoutISBN = append(report.BooksWithoutISBN, bookId)
+	}
+	sort.Slice(report.BooksWithoutISBN, func(i, j int) bool { return report.BooksWithoutISBN[i] < report.BooksWithoutISBN[j] })
+	return nil
+}
+
+// newRecord converts a legacy record. Progress was a page number; the chapter
+// has no backend field and is kept in the notes.
+func newRecord(legacy legacyRecord, book legacyBook, author string, userId uint, bookISBN, status string) model.Record {
+	record := model.Record{
+		UserID:      userId,
+		ISBN:        bookISBN,
+		Title:       book.Name,
+		Author:      author,
+		CurrentPage: legacy.CurrProgress,
+		TotalPages:  book.NumPages,
+		DateAdded:   legacy.CreatedAt,
+		Ownership:   model.OwnershipOwned,
+	}
+	if record.DateAdded.IsZero() {
+		record.DateAdded = time.Now()
+	}
+	if record.TotalPages > 0 && record.CurrentPage > record.TotalPages {
+		record.CurrentPage = record.TotalPages
+	}
+	if legacy.CurrChapter > 0 {
+		record.Notes = fmt.Sprintf("Imported at chapter %d", legacy.CurrChapter)
+	}
+
+	finished := record.DateAdded
+	if legacy.FinishDate != nil {
+		finished = *legacy.FinishDate
+	} else if legacy.UpdateDate != nil {
+		finished = *legacy.UpdateDate
+	}
+	record.SetStatus(status, finished)
+	return record
+}
+
+// ErrNotLegacy is returned by Check when the source has no root-module tables
+var ErrNotLegacy = errors.New("source database does not have the legacy schema")
+
+// Check makes sure the source database has the legacy tables and columns
+func Check(source *gorm.DB) error {
+	migrator := source.Migrator()
+	for _, table := range []interface{}{&legacyAuthor{}, &legacyBook{}, &legacyUser{}, &legacyRecord{}} {
+		if !migrator.HasTable(table) {
+			return ErrNotLegacy
+		}
+	}
+	if !migrator.HasColumn(&legacyRecord{}, "curr_progress") || !migrator.HasColumn(&legacyRecord{}, "book_id") {
+		return ErrNotLegacy
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package legacy
+
+import (
+	"biblia-be/internal/db"
+	"biblia-be/internal/model"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// openSQLite opens a SQLite database named name in dir
+func openSQLite(t *testing.T, dir, name string) *gorm.DB {
+	t.Helper()
+	conn, err := db.NewDB(db.DriverSQLite, "", "", "", filepath.Join(dir, name), "", 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return conn
+}
+
+var finishedAt = time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC)
+
+// newLegacyDB returns a root-module database with three users, one of whom
+// clashes with a backend user, and records that exercise the mappings
+func newLegacyDB(t *testing.T, dir string) *gorm.DB {
+	t.Helper()
+	source := openSQLite(t, dir, "legacy.db")
+	if err := source.AutoMigrate(&legacyAuthor{}, &legacyBook{}, &legacyUser{}, &legacyRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	rows := []interface{}{
+		&legacyAuthor{ID: 1, Name: "Tommayanti"},
+		&[]legacyBook{
+			{ID: 1, Name: "Khu Kam", NumPages: 300, AuthorID: 1},
+			{ID: 2, Name: "Lap Lae", NumPages: 200},
+			{ID: 3, Name: "Khu Kam (reprint)", NumPages: 300, AuthorID: 1},
+			{ID: 4, Name: "Untitled", NumPages: 100},
+		},
+		&[]legacyUser{
+			{ID: 1, Username: "wanida", Password: "secret1"},
+			{ID: 2, Username: "kittipong", Password: "secret2"},
+			{ID: 3, Username: "Somchai", Password: "secret3"},
+		},
+		&[]legacyRecord{
+			{BookID: 1, UserID: 1, Status: "Done", CurrProgress: 300, CreatedAt: finishedAt.AddDate(0, -1, 0), FinishDate: &finishedAt},
+			// The reprint has the same ISBN as the book the user already has
+			{BookID: 3, UserID: 1, Status: "reading"},
+			{BookID: 4, UserID: 1, Status: "In Progress", CurrProgress: 150, CurrChapter: 3},
+			{BookID: 2, UserID: 2, Status: "want"},
+			{BookID: 1, UserID: 2, Status: "current", CurrProgress: 20},
+			{BookID: 1, UserID: 3, Status: "finished"},
+		},
+	}
+	for _, row := range rows {
+		if err := source.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return source
+}
+
+// newTargetDB returns a migrated backend database with the user somchai
+func newTargetDB(t *testing.T, dir string) *gorm.DB {
+	t.Helper()
+	target := openSQLite(t, dir, "biblia.db")
+	migrator, err := db.NewMigrator(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Create(&model.User{Username: "somchai"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+// count returns the number of rows of a model
+func count(t *testing.T, conn *gorm.DB, value interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := conn.Model(value).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+var isbns = map[uint]string{1: "9786161851125", 2: "9786161851132", 3: "9786161851125"}
+
+func TestDryRunWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	source, target := newLegacyDB(t, dir), newTargetDB(t, dir)
+
+	report, err := NewImporter(source, target, isbns, true).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Report{
+		DryRun:            true,
+		Users:             3,
+		UsersCreated:      2,
+		UsernameConflicts: []string{"Somchai"},
+		Records:           6,
+		RecordsCreated:    4,
+		RecordsSkipped:    2,
+		BooksWithoutISBN:  []uint{4},
+		Statuses: map[string]int{
+			"Done -> finished":       1,
+			"reading -> reading":     1,
+			"In Progress -> reading": 1,
+			"want -> want":           1,
+			"current -> reading":     1,
+		},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got %+v\nwant %+v", report, want)
+	}
+	if users, records, imports := count(t, target, &model.User{}), count(t, target, &model.Record{}), count(t, target, &Imported{}); users != 1 || records != 0 || imports != 0 {
+		t.Errorf("dry run wrote %d users, %d records and %d imports", users-1, records, imports)
+	}
+}
+
+func TestImportTwice(t *testing.T) {
+	dir := t.TempDir()
+	source, target := newLegacyDB(t, dir), newTargetDB(t, dir)
+
+	report, err := NewImporter(source, target, isbns, false).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.UsersCreated != 2 || report.RecordsCreated != 4 || report.RecordsSkipped != 2 {
+		t.Errorf("first run: got %+v", report)
+	}
+
+	var wanida model.User
+	if err := target.Where("username = ?", "wanida").First(&wanida).Error; err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(wanida.Password), []byte("secret1")) != nil {
+		t.Error("legacy password does not match its hash")
+	}
+
+	var records []model.Record
+	if err := target.Where("user_id = ?", wanida.ID).Order("isbn").Find(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records of wanida, want 2", len(records))
+	}
+	if khuKam := records[0]; khuKam.ISBN != "9786161851125" || khuKam.Title != "Khu Kam" || khuKam.Author != "Tommayanti" ||
+		khuKam.Status != model.StatusFinished || khuKam.DateFinished == nil || !khuKam.DateFinished.Equal(finishedAt) {
+		t.Errorf("finished record: got %+v", khuKam)
+	}
+	if untitled := records[1]; untitled.ISBN != "legacy-4" || untitled.Status != model.StatusReading ||
+		untitled.CurrentPage != 100 || untitled.Notes != "Imported at chapter 3" || untitled.Ownership != model.OwnershipOwned {
+		t.Errorf("record without ISBN: got %+v", untitled)
+	}
+
+	// Running again finds everything imported and adds nothing
+	again, err := NewImporter(source, target, isbns, false).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.UsersCreated != 0 || again.UsersDone != 2 || again.RecordsCreated != 0 || again.RecordsDone != 4 || again.RecordsSkipped != 2 {
+		t.Errorf("second run: got %+v", again)
+	}
+	if users, records, imports := count(t, target, &model.User{}), count(t, target, &model.Record{}), count(t, target, &Imported{}); users != 3 || records != 4 || imports != 6 {
+		t.Errorf("after two runs: got %d users, %d records and %d imports; want 3, 4 and 6", users, records, imports)
+	}
+
+	// A legacy record of a book the user has since added in the backend is skipped
+	if err := target.Create(&model.Record{UserID: wanida.ID, ISBN: "9786161851132", Title: "Lap Lae"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Create(&legacyRecord{BookID: 2, UserID: 1, Status: "reading"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	third, err := NewImporter(source, target, isbns, false).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Records != 7 || third.RecordsCreated != 0 || third.RecordsSkipped != 3 {
+		t.Errorf("third run: got %+v", third)
+	}
+	if records := count(t, target, &model.Record{}); records != 5 {
+		t.Errorf("after the third run: got %d records, want 5", records)
+	}
+}
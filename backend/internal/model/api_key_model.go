@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// APIKey is a long-lived credential for clients that cannot log in interactively,
+// such as e-reader apps. Only a hash of the key is stored.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID     uint       `json:"userID" gorm:"index"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(16)"`
+	KeyHash    string     `json:"-" gorm:"type:char(64);uniqueIndex"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	User       User       `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type CreateAPIKey struct {
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
@@ -0,0 +1,26 @@
+package model
+
+// Operations of a change pushed by a client
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// RecordChange is a change a client made to its copy of a record while it was
+// offline. The record is named by ISBN; userID and isbn of Record are taken
+// from the change and the push.
+type RecordChange struct {
+	Op   string `json:"op" binding:"required,oneof=create update delete"`
+	ISBN string `json:"isbn" binding:"required"`
+	// IfMatch is the ETag of the record the change was made to. Without it the
+	// change overwrites whatever version the server has.
+	IfMatch string        `json:"ifMatch"`
+	Record  *CreateRecord `json:"record" binding:"required_if=Op create"`
+	Update  *UpdateRecord `json:"update" binding:"required_if=Op update"`
+}
+
+type PushChanges struct {
+	UserID  uint           `json:"userID" binding:"required"`
+	Changes []RecordChange `json:"changes" binding:"required,min=1,max=100"`
+}
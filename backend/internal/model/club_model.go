@@ -0,0 +1,91 @@
+package model
+
+import "time"
+
+// Club member roles, from most to least privileged
+const (
+	ClubOwner     = "owner"
+	ClubModerator = "moderator"
+	ClubMember    = "member"
+)
+
+// Club is a group of users reading the same book on a shared schedule
+type Club struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	Name        string           `json:"name"`
+	Description string           `json:"description" gorm:"type:text"`
+	ISBN        string           `json:"isbn" gorm:"type:varchar(20)"`
+	Title       string           `json:"title"`
+	TotalPages  int32            `json:"totalPages"`
+	StartDate   *time.Time       `json:"startDate"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	Members     []ClubMembership `json:"members,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Schedule    []ClubMilestone  `json:"schedule,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// ClubMembership is a user's membership of a club
+type ClubMembership struct {
+	ClubID   uint      `json:"clubID" gorm:"primaryKey;autoIncrement:false"`
+	UserID   uint      `json:"userID" gorm:"primaryKey;autoIncrement:false;index"`
+	Role     string    `json:"role" gorm:"type:varchar(16)"`
+	JoinedAt time.Time `json:"joinedAt"`
+	User     User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// ClubMilestone is a point of the reading schedule: the page to reach by a date
+type ClubMilestone struct {
+	ID      uint      `json:"id" gorm:"primaryKey"`
+	ClubID  uint      `json:"clubID" gorm:"index"`
+	Label   string    `json:"label"`
+	Page    int32     `json:"page"`
+	DueDate time.Time `json:"dueDate"`
+}
+
+// ClubPost is a message in a club discussion. Posts without a parent start a
+// thread. Page marks how far into the book the post goes; members who have not
+// read that far get the post without its text.
+type ClubPost struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ClubID    uint      `json:"clubID" gorm:"index"`
+	ParentID  *uint     `json:"parentID" gorm:"index"`
+	UserID    uint      `json:"userID"`
+	ISBN      string    `json:"isbn" gorm:"type:varchar(20)"`
+	Title     string    `json:"title,omitempty"`
+	Body      string    `json:"body" gorm:"type:text"`
+	Page      int32     `json:"page"`
+	CreatedAt time.Time `json:"createdAt"`
+	Club      Club      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Parent    *ClubPost `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	User      User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type CreateClub struct {
+	UserID      uint   `json:"userID" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type SetClubBook struct {
+	ISBN       string            `json:"isbn" binding:"required"`
+	Title      string            `json:"title" binding:"required"`
+	TotalPages int32             `json:"totalPages" binding:"required,min=1"`
+	StartDate  *time.Time        `json:"startDate"`
+	Schedule   []CreateMilestone `json:"schedule"`
+}
+
+type CreateMilestone struct {
+	Label   string    `json:"label"`
+	Page    int32     `json:"page"`
+	DueDate time.Time `json:"dueDate"`
+}
+
+type UpdateClubMember struct {
+	Role string `json:"role" binding:"required,oneof=owner moderator member"`
+}
+
+type CreateClubPost struct {
+	UserID uint   `json:"userID" binding:"required"`
+	Title  string `json:"title"`
+	Body   string `json:"body" binding:"required"`
+	Page   int32  `json:"page" binding:"min=0"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Event types shown in activity feeds
+const (
+	EventAdded    = "added"
+	EventStarted  = "started"
+	EventFinished = "finished"
+	EventRated    = "rated"
+)
+
+// Event is something a user did with a book. Book details are copied so the event
+// still reads correctly after the record changes or is deleted.
+type Event struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"userID" gorm:"index:idx_event_user_created"`
+	RecordID  *uint     `json:"recordID"`
+	Type      string    `json:"type" gorm:"type:varchar(16)"`
+	ISBN      string    `json:"isbn" gorm:"type:varchar(20)"`
+	Title     string    `json:"title"`
+	Cover     string    `json:"cover"`
+	Rating    int8      `json:"rating,omitempty"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index:idx_event_user_created"`
+	User      User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Record    *Record   `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
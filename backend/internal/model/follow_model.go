@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// Profile privacy settings
+const (
+	// PrivacyPublic lets anyone follow the user and see their activity
+	PrivacyPublic = "public"
+	// PrivacyFollowers requires follow requests to be approved before activity is shared
+	PrivacyFollowers = "followers"
+	// PrivacyPrivate shares activity with nobody
+	PrivacyPrivate = "private"
+)
+
+// ValidPrivacy reports whether privacy is one of the supported settings
+func ValidPrivacy(privacy string) bool {
+	return privacy == PrivacyPublic || privacy == PrivacyFollowers || privacy == PrivacyPrivate
+}
+
+// Follow is a follower's subscription to another user's activity. Follows of
+// followers-only profiles stay pending until the followed user approves them.
+type Follow struct {
+	FollowerID uint      `json:"followerID" gorm:"primaryKey;autoIncrement:false"`
+	FolloweeID uint      `json:"followeeID" gorm:"primaryKey;autoIncrement:false;index"`
+	Accepted   bool      `json:"accepted"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Follower   User      `json:"-" gorm:"foreignKey:FollowerID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Followee   User      `json:"-" gorm:"foreignKey:FolloweeID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
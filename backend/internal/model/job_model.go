@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+)
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+type Job struct {
+	ID              uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type            string     `json:"type" gorm:"type:varchar(64);index"`
+	UserID          uint       `json:"userID" gorm:"index"`
+	Status          string     `json:"status" gorm:"type:varchar(16);index:idx_job_status_run"`
+	Progress        float64    `json:"progress"`
+	Payload         string     `json:"-" gorm:"type:longtext"`
+	Result          string     `json:"-" gorm:"type:longtext"`
+	Attempts        int        `json:"attempts"`
+	MaxAttempts     int        `json:"maxAttempts"`
+	LastError       string     `json:"lastError,omitempty" gorm:"type:text"`
+	CancelRequested bool       `json:"cancelRequested"`
+	RunAt           time.Time  `json:"runAt" gorm:"index:idx_job_status_run"`
+	StartedAt       *time.Time `json:"startedAt,omitempty"`
+	FinishedAt      *time.Time `json:"finishedAt,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// Finished reports whether the job reached a terminal status
+func (job Job) Finished() bool {
+	return job.Status == JobSucceeded || job.Status == JobFailed || job.Status == JobCancelled
+}
@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// Record ownership states changed by loans
+const (
+	// OwnershipOwned is a book on the user's own shelf
+	OwnershipOwned = "owned"
+	// OwnershipLent is a book the user owns that is lent out
+	OwnershipLent = "lent"
+	// OwnershipBorrowed is a book the user has borrowed from another user
+	OwnershipBorrowed = "borrowed"
+	// OwnershipReturned is a borrowed book that went back to its owner
+	OwnershipReturned = "returned"
+)
+
+// Loan is a physical book lent from one user's record to another user, or to
+// someone outside Biblia named in BorrowerName. Names are copied when the loan is
+// made so both sides can show who they lent to or borrowed from.
+type Loan struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	RecordID         uint       `json:"recordID" gorm:"index"`
+	LenderID         uint       `json:"lenderID" gorm:"index"`
+	LenderName       string     `json:"lenderName"`
+	BorrowerID       *uint      `json:"borrowerID" gorm:"index"`
+	BorrowerName     string     `json:"borrowerName"`
+	BorrowerRecordID *uint      `json:"borrowerRecordID" gorm:"index"`
+	ISBN             string     `json:"isbn" gorm:"type:varchar(20)"`
+	Title            string     `json:"title"`
+	LentAt           time.Time  `json:"lentAt"`
+	DueAt            *time.Time `json:"dueAt"`
+	ReturnedAt       *time.Time `json:"returnedAt"`
+	Notes            string     `json:"notes"`
+	Record           *Record    `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	BorrowerRecord   *Record    `json:"-" gorm:"foreignKey:BorrowerRecordID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Lender           User       `json:"-" gorm:"foreignKey:LenderID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Borrower         *User      `json:"-" gorm:"foreignKey:BorrowerID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+// Overdue reports whether the loan is still out after its due date
+func (loan Loan) Overdue(now time.Time) bool {
+	return loan.ReturnedAt == nil && loan.DueAt != nil && loan.DueAt.Before(now)
+}
+
+type CreateLoan struct {
+	RecordID     uint       `json:"recordID" binding:"required"`
+	BorrowerID   *uint      `json:"borrowerID"`
+	BorrowerName string     `json:"borrowerName"`
+	LentAt       *time.Time `json:"lentAt"`
+	DueAt        *time.Time `json:"dueAt"`
+	Notes        string     `json:"notes"`
+}
+
+type ReturnLoan struct {
+	ReturnedAt *time.Time `json:"returnedAt"`
+}
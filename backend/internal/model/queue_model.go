@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Queue priorities, from least to most urgent
+const (
+	PriorityLow    = 1
+	PriorityNormal = 2
+	PriorityHigh   = 3
+)
+
+// QueueItem is a book on a user's to-be-read queue. Position is a fractional
+// index key: items sort by it and a moved item gets a key between its new
+// neighbours, so the rest of the queue keeps its keys.
+type QueueItem struct {
+	ID       uint      `json:"id" gorm:"primaryKey"`
+	UserID   uint      `json:"userID" gorm:"uniqueIndex:idx_queue_user_isbn;index:idx_queue_user_position"`
+	ISBN     string    `json:"isbn" gorm:"type:varchar(20);uniqueIndex:idx_queue_user_isbn"`
+	Title    string    `json:"title"`
+	Author   string    `json:"author"`
+	Cover    string    `json:"cover"`
+	Genre    string    `json:"genre"`
+	Position string    `json:"position" gorm:"type:varchar(80);index:idx_queue_user_position"`
+	Priority int8      `json:"priority" gorm:"default:2"`
+	Reason   string    `json:"reason" gorm:"type:text"`
+	AddedAt  time.Time `json:"addedAt"`
+	User     User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type CreateQueueItem struct {
+	ISBN     string `json:"isbn" binding:"required"`
+	Title    string `json:"title" binding:"required"`
+	Author   string `json:"author"`
+	Cover    string `json:"cover"`
+	Genre    string `json:"genre"`
+	Priority int8   `json:"priority" binding:"omitempty,min=1,max=3"`
+	Reason   string `json:"reason"`
+}
+
+type UpdateQueueItem struct {
+	Priority *int8   `json:"priority" binding:"omitempty,min=1,max=3"`
+	Reason   *string `json:"reason"`
+}
+
+// MoveQueueItem places an item right after another one, or at the top of the
+// queue when AfterID is empty
+type MoveQueueItem struct {
+	AfterID *uint `json:"afterID"`
+}
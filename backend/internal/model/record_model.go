@@ -1,38 +1,109 @@
 package model
 
 import (
+	"fmt"
+	"math"
 	"time"
 )
 
+// Record statuses with special meaning; other values are free-form
+const (
+	// StatusReading is the status of a book the user is currently reading
+	StatusReading = "reading"
+	// StatusFinished is the status of a book the user has read to the end
+	StatusFinished = "finished"
+)
+
+// MaxRating is the highest rating a record can have; 0 means unrated
+const MaxRating = 5
+
 type Record struct {
-	ID          uint      `gorm:"primaryKey;autoIncrement"`
-	UserID      uint      `json:"userID" gorm:"uniqueIndex:idx_user_isbn;foreignKey:UserID;references:ID"`
-	ISBN        string    `json:"isbn" gorm:"type:varchar(20);uniqueIndex:idx_user_isbn"`
-	Title       string    `json:"title"`
-	Author      string    `json:"author"`
-	Cover       string    `json:"cover"`
-	Genre       string    `json:"genre"`
-	Status      string    `json:"status"`
-	CurrentPage int32     `json:"currentPage"`
-	TotalPages  int32     `json:"totalPages"`
-	DateAdded   time.Time `json:"dateAdded"`
-	User        User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ID           uint       `gorm:"primaryKey;autoIncrement"`
+	UserID       uint       `json:"userID" gorm:"uniqueIndex:idx_user_isbn;foreignKey:UserID;references:ID"`
+	ISBN         string     `json:"isbn" gorm:"type:varchar(20);uniqueIndex:idx_user_isbn"`
+	Title        string     `json:"title"`
+	Author       string     `json:"author"`
+	Cover        string     `json:"cover"`
+	Genre        string     `json:"genre"`
+	Status       string     `json:"status"`
+	CurrentPage  int32      `json:"currentPage"`
+	TotalPages   int32      `json:"totalPages"`
+	DateAdded    time.Time  `json:"dateAdded"`
+	DateFinished *time.Time `json:"dateFinished,omitempty"`
+	EbookKey     string     `json:"ebookKey,omitempty"`
+	DocumentID   string     `json:"documentID,omitempty" gorm:"type:varchar(32);index"`
+	Shelves      []string   `json:"shelves" gorm:"serializer:json"`
+	Notes        string     `json:"notes" gorm:"type:text"`
+	Rating       int8       `json:"rating"`
+	Ownership    string     `json:"ownership" gorm:"type:varchar(16);default:owned"`
+	Version      uint       `json:"version" gorm:"not null;default:1"`
+	ChangeSeq    uint64     `json:"-" gorm:"not null;default:0"`
+	Loan         *Loan      `json:"loan,omitempty" gorm:"-"`
+	User         User       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
 type CreateRecord struct {
-	UserID      uint      `json:"userID"`
-	ISBN        string    `json:"isbn"`
-	Title       string    `json:"title"`
-	Author      string    `json:"author"`
-	Cover       string    `json:"cover"`
-	Genre       string    `json:"genre"`
-	Status      string    `json:"status"`
-	CurrentPage int32     `json:"currentPage"`
-	TotalPages  int32     `json:"totalPages"`
-	DateAdded   time.Time `json:"dateAdded"`
+	UserID       uint       `json:"userID" binding:"required"`
+	ISBN         string     `json:"isbn" binding:"required"`
+	Title        string     `json:"title" binding:"required"`
+	Author       string     `json:"author"`
+	Cover        string     `json:"cover"`
+	Genre        string     `json:"genre"`
+	Status       string     `json:"status"`
+	CurrentPage  int32      `json:"currentPage"`
+	TotalPages   int32      `json:"totalPages"`
+	DateAdded    time.Time  `json:"dateAdded"`
+	DateFinished *time.Time `json:"dateFinished"`
+	Shelves      []string   `json:"shelves"`
+	Notes        string     `json:"notes"`
+	Rating       int8       `json:"rating" binding:"min=0,max=5"`
 }
 
 type UpdateRecord struct {
-	Status      string `json:"status"`
-	CurrentPage int32  `json:"currentPage"`
+	Status      string   `json:"status"`
+	CurrentPage int32    `json:"currentPage" binding:"min=0"`
+	Shelves     []string `json:"shelves"`
+	Notes       *string  `json:"notes"`
+	Rating      *int8    `json:"rating" binding:"omitempty,min=0,max=5"`
+}
+
+// SetStatus changes the status, setting DateFinished when the book becomes finished
+// and clearing it when it no longer is
+func (record *Record) SetStatus(status string, now time.Time) {
+	if status == StatusFinished && (record.Status != StatusFinished || record.DateFinished == nil) {
+		record.DateFinished = &now
+	} else if status != StatusFinished {
+		record.DateFinished = nil
+	}
+	record.Status = status
+}
+
+// ETag identifies this version of the record for If-Match preconditions. It
+// includes the ID so a record deleted and added again never matches an old tag.
+func (record Record) ETag() string {
+	return fmt.Sprintf(`"%d-%d"`, record.ID, record.Version)
+}
+
+// Progress is the fraction of the book read, rounded to four decimals so it
+// compares equal to the same value computed by the database
+func (record Record) Progress() float64 {
+	if record.TotalPages <= 0 {
+		return 0
+	}
+	return math.Round(float64(record.CurrentPage)*10000/float64(record.TotalPages)) / 10000
+}
+
+type ImportRecords struct {
+	UserID  uint           `json:"userID" binding:"required"`
+	Records []CreateRecord `json:"records" binding:"required,min=1"`
+}
+
+// CatalogBook is the shared metadata of a book, derived from the records of all users
+type CatalogBook struct {
+	ISBN       string `json:"isbn"`
+	Title      string `json:"title"`
+	Author     string `json:"author"`
+	Cover      string `json:"cover"`
+	Genre      string `json:"genre"`
+	TotalPages int32  `json:"totalPages"`
 }
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// Series is an ordered set of books. Its entries link to records and catalog
+// books by ISBN.
+type Series struct {
+	ID          uint          `json:"id" gorm:"primaryKey"`
+	Name        string        `json:"name" gorm:"index"`
+	Author      string        `json:"author"`
+	Description string        `json:"description" gorm:"type:text"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	Entries     []SeriesEntry `json:"entries,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// SeriesEntry is a volume of a series. Position gives the reading order and can
+// be fractional for novellas set between two books, like 1.5.
+type SeriesEntry struct {
+	ID       uint    `json:"id" gorm:"primaryKey"`
+	SeriesID uint    `json:"seriesID" gorm:"uniqueIndex:idx_series_isbn"`
+	ISBN     string  `json:"isbn" gorm:"type:varchar(20);uniqueIndex:idx_series_isbn;index"`
+	Title    string  `json:"title"`
+	Position float64 `json:"position"`
+}
+
+type CreateSeries struct {
+	Name        string              `json:"name" binding:"required"`
+	Author      string              `json:"author"`
+	Description string              `json:"description"`
+	Entries     []CreateSeriesEntry `json:"entries"`
+}
+
+type CreateSeriesEntry struct {
+	ISBN     string  `json:"isbn"`
+	Title    string  `json:"title"`
+	Position float64 `json:"position"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+)
+
+// SyncProgress is the last reading position an e-reader pushed for a document.
+// Documents are identified by KOReader's fingerprint and mapped to the record
+// of the book they contain when it is known.
+type SyncProgress struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID     uint      `json:"userID" gorm:"uniqueIndex:idx_user_document"`
+	Document   string    `json:"document" gorm:"type:varchar(64);uniqueIndex:idx_user_document"`
+	RecordID   *uint     `json:"recordID"`
+	Progress   string    `json:"progress" gorm:"type:text"`
+	Percentage float64   `json:"percentage"`
+	Device     string    `json:"device"`
+	DeviceID   string    `json:"deviceID"`
+	Timestamp  int64     `json:"timestamp"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	User       User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Record     *Record   `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+type LinkSyncDocument struct {
+	Document string `json:"document" binding:"required"`
+}
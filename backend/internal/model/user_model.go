@@ -4,18 +4,22 @@ type User struct {
 	ID             uint     `gorm:"primaryKey" json:"id"`
 	Username       string   `json:"username"`
 	Password       string   `json:"password"`
+	SyncKey        string   `json:"-"`
 	FavoriteGenres []string `json:"favorite_genres" gorm:"serializer:json"`
+	Privacy        string   `json:"privacy" gorm:"type:varchar(16);default:public"`
 	Records        []Record `json:"records" gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 }
 
 type CreateUser struct {
-	Username       string   `json:"username" binding:"required"`
-	Password       string   `json:"password" binding:"required"`
+	Username       string   `json:"username" binding:"required,min=3,max=50"`
+	Password       string   `json:"password" binding:"required,min=6"`
 	FavoriteGenres []string `json:"favorite_genres"`
+	Privacy        string   `json:"privacy" binding:"omitempty,oneof=public followers private"`
 }
 
 type UpdateUser struct {
-	Username       string   `json:"username" binding:"required"`
-	Password       string   `json:"password" binding:"required"`
+	Username       string   `json:"username" binding:"required,min=3,max=50"`
+	Password       string   `json:"password" binding:"required,min=6"`
 	FavoriteGenres []string `json:"favorite_genres"`
+	Privacy        string   `json:"privacy" binding:"omitempty,oneof=public followers private"`
 }
@@ -0,0 +1,95 @@
+// Package opds defines the Atom documents of an OPDS 1.2 catalog.
+package opds
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Media types of OPDS catalog documents
+const (
+	NavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	AcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+)
+
+// Link relations defined by OPDS
+const (
+	RelAcquisition = "http://opds-spec.org/acquisition"
+	RelImage       = "http://opds-spec.org/image"
+	RelThumbnail   = "http://opds-spec.org/image/thumbnail"
+	RelSubsection  = "subsection"
+	RelStart       = "start"
+	RelSelf        = "self"
+	RelUp          = "up"
+	RelNext        = "next"
+)
+
+// Feed is an Atom feed carrying OPDS navigation or acquisition entries
+type Feed struct {
+	XMLName   xml.Name  `xml:"feed"`
+	Xmlns     string    `xml:"xmlns,attr"`
+	XmlnsDC   string    `xml:"xmlns:dc,attr"`
+	XmlnsOPDS string    `xml:"xmlns:opds,attr"`
+	ID        string    `xml:"id"`
+	Title     string    `xml:"title"`
+	Updated   time.Time `xml:"updated"`
+	Author    *Author   `xml:"author,omitempty"`
+	Links     []Link    `xml:"link"`
+	Entries   []Entry   `xml:"entry"`
+}
+
+// Entry is a navigation entry or a publication
+type Entry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Updated    time.Time  `xml:"updated"`
+	Authors    []Author   `xml:"author,omitempty"`
+	Identifier string     `xml:"dc:identifier,omitempty"`
+	Language   string     `xml:"dc:language,omitempty"`
+	Categories []Category `xml:"category,omitempty"`
+	Summary    string     `xml:"summary,omitempty"`
+	Content    *Content   `xml:"content,omitempty"`
+	Links      []Link     `xml:"link"`
+}
+
+type Author struct {
+	Name string `xml:"name"`
+}
+
+type Category struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type Content struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type Link struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+// NewFeed creates a feed with the Atom, Dublin Core and OPDS namespaces declared
+func NewFeed(id, title string, updated time.Time) *Feed {
+	return &Feed{
+		Xmlns:     "http://www.w3.org/2005/Atom",
+		XmlnsDC:   "http://purl.org/dc/terms/",
+		XmlnsOPDS: "http://opds-spec.org/2010/catalog",
+		ID:        id,
+		Title:     title,
+		Updated:   updated.UTC(),
+	}
+}
+
+// Marshal renders the feed as an XML document
+func (f *Feed) Marshal() ([]byte, error) {
+	body, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
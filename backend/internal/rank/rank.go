@@ -0,0 +1,124 @@
+// Package rank generates fractional index keys: strings that sort in the order of
+// a list and leave room between any two of them, so an item can be moved by
+// giving it a new key between its neighbours without renumbering the others.
+//
+// Keys use the digits 0-9 and a-z, which sort the same way in byte order and in
+// the case insensitive collations databases default to. They never end in '0',
+// which guarantees a key exists between any two different keys.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// MaxLength is the key length past which a list should be spread out again
+// with Sequence. Keys grow by about one digit each time an item is inserted
+// at the same spot five times in a row.
+const MaxLength = 64
+
+// ErrInvalidKey is returned for keys that were not made by this package
+var ErrInvalidKey = errors.New("invalid rank key")
+
+// ErrOrder is returned when the lower key is not below the upper key
+var ErrOrder = errors.New("rank keys out of order")
+
+// Between returns a key that sorts after a and before b. An empty a means the
+// start of the list and an empty b the end.
+func Between(a, b string) (string, error) {
+	if !valid(a) || !valid(b) {
+		return "", ErrInvalidKey
+	}
+	if b != "" && a >= b {
+		return "", ErrOrder
+	}
+	return midpoint(a, b), nil
+}
+
+// Sequence returns n keys spread evenly over the key space, in order
+func Sequence(n int) []string {
+	keys := make([]string, n)
+	// Use as many digits as needed to give every key its own value
+	width, space := 1, len(digits)
+	for space <= n {
+		width++
+		space *= len(digits)
+	}
+	for i := range keys {
+		keys[i] = strings.TrimRight(encode((i+1)*space/(n+1), width), "0")
+	}
+	return keys
+}
+
+// midpoint finds a key between a and b, where b is empty for the end of the list
+func midpoint(a, b string) string {
+	if b != "" {
+		// Keep the prefix both keys share, reading missing digits of a as zeros
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			if n > len(a) {
+				return b[:n] + midpoint("", b[n:])
+			}
+			return b[:n] + midpoint(a[n:], b[n:])
+		}
+	}
+
+	low := 0
+	if a != "" {
+		low = strings.IndexByte(digits, a[0])
+	}
+	high := len(digits)
+	if b != "" {
+		high = strings.IndexByte(digits, b[0])
+	}
+	if high-low > 1 {
+		return string(digits[(low+high)/2])
+	}
+
+	// The first digits are adjacent: a longer b can be cut short, otherwise
+	// keep a's first digit and find room after the rest of a
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if a != "" {
+		rest = a[1:]
+	}
+	return string(digits[low]) + midpoint(rest, "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return digits[0]
+}
+
+func encode(value, width int) string {
+	key := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		key[i] = digits[value%len(digits)]
+		value /= len(digits)
+	}
+	return string(key)
+}
+
+func valid(key string) bool {
+	if key == "" {
+		return true
+	}
+	if key[len(key)-1] == digits[0] {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package rank
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// between calls Between and checks that the key is valid and sorts between a
+// and b
+func between(t *testing.T, a, b string) string {
+	t.Helper()
+	key, err := Between(a, b)
+	if err != nil {
+		t.Fatalf("Between(%q, %q): %v", a, b, err)
+	}
+	if !valid(key) || key == "" || key <= a || b != "" && key >= b {
+		t.Fatalf("Between(%q, %q) = %q", a, b, key)
+	}
+	return key
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"empty list", "", ""},
+		{"before first", "", "i"},
+		{"before smallest digit", "", "1"},
+		{"before smallest key", "", "01"},
+		{"after last", "i", ""},
+		{"after largest digit", "z", ""},
+		{"after largest key", "zzz", ""},
+		{"apart", "a", "c"},
+		{"adjacent digits", "a", "b"},
+		{"adjacent with longer upper", "a", "b1"},
+		{"adjacent with longer lower", "az", "b"},
+		{"lower is prefix", "a", "a1"},
+		{"lower is prefix of longer upper", "a", "a01"},
+		{"shared prefix", "abc1", "abc2"},
+		{"deep", "0001", "0002"},
+		{"edges of the alphabet", "1", "z"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			between(t, tc.a, tc.b)
+		})
+	}
+}
+
+func TestBetweenErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want error
+	}{
+		{"trailing zero", "a0", "", ErrInvalidKey},
+		{"upper case", "", "A", ErrInvalidKey},
+		{"symbol", "a-", "b", ErrInvalidKey},
+		{"equal", "a", "a", ErrOrder},
+		{"reversed", "b", "a", ErrOrder},
+		{"reversed prefix", "a1", "a", ErrOrder},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if key, err := Between(tc.a, tc.b); !errors.Is(err, tc.want) {
+				t.Errorf("Between(%q, %q) = %q, %v; want %v", tc.a, tc.b, key, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestInsertChains(t *testing.T) {
+	const inserts = 500
+
+	tests := []struct {
+		name string
+		// next returns the neighbours of the next key in a list of keys
+		next func(keys []string) (string, string)
+	}{
+		{"at the front", func(keys []string) (string, string) { return "", keys[0] }},
+		{"at the end", func(keys []string) (string, string) { return keys[len(keys)-1], "" }},
+		{"after the first", func(keys []string) (string, string) { return keys[0], keys[1] }},
+		{"before the last", func(keys []string) (string, string) { return keys[len(keys)-2], keys[len(keys)-1] }},
+		{"in the middle", func(keys []string) (string, string) {
+			i := len(keys)/2 - 1
+			return keys[i], keys[i+1]
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			keys := Sequence(2)
+			for i := 0; i < inserts; i++ {
+				a, b := tc.next(keys)
+				keys = append(keys, between(t, a, b))
+				sort.Strings(keys)
+			}
+			for i := 1; i < len(keys); i++ {
+				if keys[i-1] == keys[i] {
+					t.Fatalf("duplicate key %q", keys[i])
+				}
+			}
+			for _, key := range keys {
+				// About one digit per five inserts at the same spot
+				if len(key) > inserts/4 {
+					t.Fatalf("key %q grew too long", key)
+				}
+			}
+		})
+	}
+}
+
+func TestInsertRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for i := 0; i < 2000; i++ {
+		pos := random.Intn(len(keys) + 1)
+		a, b := "", ""
+		if pos > 0 {
+			a = keys[pos-1]
+		}
+		if pos < len(keys) {
+			b = keys[pos]
+		}
+		key := between(t, a, b)
+		keys = append(keys[:pos], append([]string{key}, keys[pos:]...)...)
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Fatal("keys are out of order")
+	}
+}
+
+func TestSequence(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 35, 36, 37, 1000, 50000} {
+		keys := Sequence(n)
+		if len(keys) != n {
+			t.Fatalf("Sequence(%d) returned %d keys", n, len(keys))
+		}
+		for i, key := range keys {
+			if !valid(key) || key == "" {
+				t.Fatalf("Sequence(%d)[%d] = %q is invalid", n, i, key)
+			}
+			if i > 0 && keys[i-1] >= key {
+				t.Fatalf("Sequence(%d) is out of order at %d: %q, %q", n, i, keys[i-1], key)
+			}
+			if len(key) > 4 {
+				t.Fatalf("Sequence(%d)[%d] = %q is longer than needed", n, i, key)
+			}
+		}
+		// Spreading a list out leaves room around every key
+		for i := 0; i <= len(keys); i++ {
+			a, b := "", ""
+			if i > 0 {
+				a = keys[i-1]
+			}
+			if i < len(keys) {
+				b = keys[i]
+			}
+			between(t, a, b)
+		}
+	}
+}
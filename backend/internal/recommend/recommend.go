@@ -0,0 +1,221 @@
+// Package recommend ranks books a reader does not have yet by combining genre
+// affinity with item-based collaborative filtering over reading records.
+package recommend
+
+import (
+	"biblia-be/internal/model"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Score weights of the signals
+const (
+	coReadingWeight  = 0.6
+	genreWeight      = 0.35
+	popularityWeight = 0.05
+)
+
+// Input is everything known about a reader and the books around them
+type Input struct {
+	// FavoriteGenres are the genres the reader picked in their profile
+	FavoriteGenres []string
+	// Library holds the reader's own records
+	Library []model.Record
+	// Candidates holds records of other users: everything read by users who share
+	// a book with the reader, and books in genres the reader likes
+	Candidates []model.Record
+	// Readers is the number of users with each ISBN of Library and Candidates
+	Readers map[string]int
+}
+
+// Recommendation is a book worth suggesting. Book is the most recently added
+// candidate record of the ISBN, to take the catalog metadata from.
+type Recommendation struct {
+	ISBN    string
+	Book    model.Record
+	Score   float64
+	Reasons []string
+}
+
+// genreKey normalizes a genre for comparison
+func genreKey(genre string) string {
+	return strings.ToLower(strings.TrimSpace(genre))
+}
+
+// strength is how much a record says about the reader's taste
+func strength(record model.Record) float64 {
+	if record.Status == model.StatusFinished {
+		return 1
+	}
+	if record.TotalPages > 0 && record.CurrentPage > 0 {
+		return 0.4 + 0.4*math.Min(1, float64(record.CurrentPage)/float64(record.TotalPages))
+	}
+	return 0.3
+}
+
+// because describes a library record as the reason for a recommendation
+func because(record model.Record) string {
+	switch {
+	case record.Status == model.StatusFinished:
+		return fmt.Sprintf("because you finished %s", record.Title)
+	case record.CurrentPage > 0:
+		return fmt.Sprintf("because you are reading %s", record.Title)
+	default:
+		return fmt.Sprintf("because you added %s", record.Title)
+	}
+}
+
+// genreAffinity weighs genres from the favorites, which count fully, and from the
+// reader's history, in proportion to how much of it falls in each genre
+func genreAffinity(input Input) (map[string]float64, map[string]bool) {
+	affinity := map[string]float64{}
+	favorite := map[string]bool{}
+	for _, genre := range input.FavoriteGenres {
+		if key := genreKey(genre); key != "" {
+			affinity[key] = 1
+			favorite[key] = true
+		}
+	}
+
+	var total float64
+	history := map[string]float64{}
+	for _, record := range input.Library {
+		if key := genreKey(record.Genre); key != "" {
+			history[key] += strength(record)
+			total += strength(record)
+		}
+	}
+	for key, weight := range history {
+		affinity[key] = math.Min(1, affinity[key]+weight/total)
+	}
+	return affinity, favorite
+}
+
+// Recommend returns up to limit books from the candidates that are not in the
+// library, best first. The co-reading score of a book sums, over the library, the
+// cosine similarity between the sets of users having each library book and the
+// candidate, weighted by how strongly the reader engaged with the library book.
+func Recommend(input Input, limit int) []Recommendation {
+	owned := map[string]model.Record{}
+	for _, record := range input.Library {
+		owned[record.ISBN] = record
+	}
+
+	// Books of each other user, and the latest record of each candidate book
+	shelves := map[uint][]string{}
+	books := map[string]model.Record{}
+	for _, record := range input.Candidates {
+		if record.UserID == 0 {
+			continue
+		}
+		shelves[record.UserID] = append(shelves[record.UserID], record.ISBN)
+		if _, mine := owned[record.ISBN]; mine {
+			continue
+		}
+		if book, ok := books[record.ISBN]; !ok || record.DateAdded.After(book.DateAdded) {
+			books[record.ISBN] = record
+		}
+	}
+
+	// co[candidate][library book] counts users having both
+	co := map[string]map[string]int{}
+	for _, isbns := range shelves {
+		var mine, theirs []string
+		for _, isbn := range isbns {
+			if _, ok := owned[isbn]; ok {
+				mine = append(mine, isbn)
+			} else {
+				theirs = append(theirs, isbn)
+			}
+		}
+		for _, candidate := range theirs {
+			if co[candidate] == nil {
+				co[candidate] = map[string]int{}
+			}
+			for _, isbn := range mine {
+				co[candidate][isbn]++
+			}
+		}
+	}
+
+	affinity, favorite := genreAffinity(input)
+
+	type scored struct {
+		Recommendation
+		coReading float64
+		genre     float64
+	}
+	var results []scored
+	var maxCoReading float64
+	for isbn, book := range books {
+		result := scored{Recommendation: Recommendation{ISBN: isbn, Book: book}}
+
+		var bestContribution float64
+		var bestSource model.Record
+		for source, both := range co[isbn] {
+			readers := math.Sqrt(float64(input.Readers[source]) * float64(input.Readers[isbn]))
+			if readers == 0 {
+				continue
+			}
+			contribution := strength(owned[source]) * float64(both) / readers
+			result.coReading += contribution
+			if contribution > bestContribution ||
+				(contribution == bestContribution && owned[source].Title < bestSource.Title) {
+				bestContribution, bestSource = contribution, owned[source]
+			}
+		}
+		if bestContribution > 0 {
+			result.Reasons = append(result.Reasons, because(bestSource))
+		}
+		maxCoReading = math.Max(maxCoReading, result.coReading)
+
+		key := genreKey(book.Genre)
+		if result.genre = affinity[key]; result.genre > 0 {
+			if favorite[key] {
+				result.Reasons = append(result.Reasons, fmt.Sprintf("because %s is one of your favorite genres", book.Genre))
+			} else {
+				result.Reasons = append(result.Reasons, fmt.Sprintf("because you often read %s", book.Genre))
+			}
+		}
+
+		if result.coReading > 0 || result.genre > 0 {
+			results = append(results, result)
+		}
+	}
+
+	// Popularity only breaks ties between otherwise similar books
+	var maxReaders int
+	for _, result := range results {
+		if n := input.Readers[result.ISBN]; n > maxReaders {
+			maxReaders = n
+		}
+	}
+	for i := range results {
+		result := &results[i]
+		if maxCoReading > 0 {
+			result.Score += coReadingWeight * result.coReading / maxCoReading
+		}
+		result.Score += genreWeight * result.genre
+		if maxReaders > 0 {
+			result.Score += popularityWeight * math.Log1p(float64(input.Readers[result.ISBN])) / math.Log1p(float64(maxReaders))
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ISBN < results[j].ISBN
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	recommendations := make([]Recommendation, len(results))
+	for i, result := range results {
+		recommendations[i] = result.Recommendation
+	}
+	return recommendations
+}
@@ -0,0 +1,101 @@
+package recommend
+
+import (
+	"biblia-be/internal/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testInput is a reader who finished a fantasy novel and is halfway through an
+// essay, among other users who share some of those books
+func testInput() Input {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	record := func(userID uint, isbn, title, genre string, added int) model.Record {
+		return model.Record{UserID: userID, ISBN: isbn, Title: title, Genre: genre, DateAdded: day(added)}
+	}
+
+	dune := record(1, "dune", "Dune", "Fantasy", 1)
+	dune.Status = model.StatusFinished
+	essays := record(1, "essays", "Essays", "essay", 2)
+	essays.CurrentPage, essays.TotalPages = 50, 100
+
+	return Input{
+		FavoriteGenres: []string{" thai novel "},
+		Library:        []model.Record{dune, essays},
+		Candidates: []model.Record{
+			record(2, "dune", "Dune", "fantasy", 3),
+			record(2, "hobbit", "The Hobbit (old edition)", "fantasy", 3),
+			record(2, "horror", "Horror", "horror", 3),
+			record(3, "dune", "Dune", "fantasy", 4),
+			record(3, "hobbit", "The Hobbit", "fantasy", 5),
+			record(4, "essays", "Essays", "essay", 4),
+			record(4, "horror", "Horror", "horror", 4),
+			record(5, "khu-kam", "คู่กรรม", "Thai Novel", 6),
+			record(6, "cooking", "Cooking", "cooking", 6),
+			record(0, "orphan", "Orphan", "fantasy", 7),
+		},
+		Readers: map[string]int{"dune": 3, "essays": 2, "hobbit": 2, "horror": 2, "khu-kam": 1, "cooking": 1},
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	recommendations := Recommend(testInput(), 0)
+
+	// Co-reading outweighs genre, books without any signal are left out, and
+	// owned books and records without a user are never suggested
+	want := []struct {
+		isbn    string
+		title   string
+		reasons []string
+	}{
+		{"hobbit", "The Hobbit", []string{"because you finished Dune", "because you often read fantasy"}},
+		{"horror", "Horror", []string{"because you finished Dune"}},
+		{"khu-kam", "คู่กรรม", []string{"because Thai Novel is one of your favorite genres"}},
+	}
+	if len(recommendations) != len(want) {
+		t.Fatalf("got %+v, want %d recommendations", recommendations, len(want))
+	}
+	for i, w := range want {
+		got := recommendations[i]
+		if got.ISBN != w.isbn || got.Book.Title != w.title || !reflect.DeepEqual(got.Reasons, w.reasons) {
+			t.Errorf("#%d: got %s %q %q, want %s %q %q", i, got.ISBN, got.Book.Title, got.Reasons, w.isbn, w.title, w.reasons)
+		}
+		if i > 0 && got.Score >= recommendations[i-1].Score {
+			t.Errorf("#%d: score %v is not below %v", i, got.Score, recommendations[i-1].Score)
+		}
+	}
+}
+
+func TestRecommendLimit(t *testing.T) {
+	recommendations := Recommend(testInput(), 2)
+	if len(recommendations) != 2 || recommendations[0].ISBN != "hobbit" || recommendations[1].ISBN != "horror" {
+		t.Errorf("got %+v, want hobbit and horror", recommendations)
+	}
+}
+
+func TestRecommendEngagement(t *testing.T) {
+	// Each candidate shares a reader with one library book: the one next to the
+	// finished book ranks above the one next to a barely started book
+	input := Input{
+		Library: []model.Record{
+			{UserID: 1, ISBN: "finished", Title: "Finished", Status: model.StatusFinished},
+			{UserID: 1, ISBN: "started", Title: "Started", CurrentPage: 1, TotalPages: 100},
+		},
+		Candidates: []model.Record{
+			{UserID: 2, ISBN: "started"},
+			{UserID: 2, ISBN: "a-after-started"},
+			{UserID: 3, ISBN: "finished"},
+			{UserID: 3, ISBN: "b-after-finished"},
+		},
+		Readers: map[string]int{"finished": 2, "started": 2, "a-after-started": 1, "b-after-finished": 1},
+	}
+
+	recommendations := Recommend(input, 0)
+	if len(recommendations) != 2 || recommendations[0].ISBN != "b-after-finished" {
+		t.Fatalf("got %+v, want b-after-finished first", recommendations)
+	}
+	if reasons := recommendations[0].Reasons; len(reasons) != 1 || reasons[0] != "because you finished Finished" {
+		t.Errorf("got reasons %q", reasons)
+	}
+}
@@ -0,0 +1,436 @@
+package repository
+
+import (
+	"biblia-be/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ApplyPage adds the keyset condition, ordering and limit of a page to a query,
+// ordering by the SQL column or expression expr with the ID as tiebreaker
+func ApplyPage(query *gorm.DB, expr string, page Page) *gorm.DB {
+	op, dir := ">", "ASC"
+	if page.Desc {
+		op, dir = "<", "DESC"
+	}
+
+	if page.After != nil {
+		query = query.Where(
+			fmt.Sprintf("((%s %s ?) OR (%s = ? AND id %s ?))", expr, op, expr, op),
+			page.After.Value, page.After.Value, page.After.ID,
+		)
+	}
+
+	return query.Order(fmt.Sprintf("%s %s, id %s", expr, dir, dir)).Limit(page.Limit)
+}
+
+// sortExpr looks up the SQL expression of a named sort
+func sortExpr(exprs map[string]string, sort string) (string, error) {
+	expr, ok := exprs[sort]
+	if !ok {
+		return "", fmt.Errorf("unsupported sort %q", sort)
+	}
+	return expr, nil
+}
+
+// EscapeLike escapes the wildcards of a LIKE pattern with '!', which unlike a
+// backslash means the same in every dialect. Patterns must end in ESCAPE '!'.
+func EscapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+// HasPrefix returns a scope matching rows where column starts with prefix,
+// ignoring case like MySQL's default collation does
+func HasPrefix(column, prefix string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		like := "LIKE"
+		if db.Dialector.Name() == "postgres" {
+			like = "ILIKE"
+		}
+		return db.Where(column+" "+like+" ? ESCAPE '!'", EscapeLike(prefix)+"%")
+	}
+}
+
+// yearExpr extracts the year of a date column
+func yearExpr(db *gorm.DB, column string) string {
+	if db.Dialector.Name() == "sqlite" {
+		return "strftime('%Y', " + column + ")"
+	}
+	return "EXTRACT(YEAR FROM " + column + ")"
+}
+
+// notFound turns GORM's missing row error into ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// duplicate turns the driver's unique index violation into ErrDuplicate
+func duplicate(db *gorm.DB, err error) error {
+	if err == nil {
+		return nil
+	}
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok && errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// userSortExprs are the columns of the user sorts
+var userSortExprs = map[string]string{
+	"id":       "id",
+	"username": "username",
+}
+
+// GormUserRepository stores users in the database
+type GormUserRepository struct {
+	db *gorm.DB
+}
+
+// NewGormUserRepository creates a user repository on a database connection
+func NewGormUserRepository(db *gorm.DB) *GormUserRepository {
+	return &GormUserRepository{db: db}
+}
+
+// userScope applies a user filter
+func userScope(filter UserFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.UsernamePrefix != "" {
+			db = db.Scopes(HasPrefix("username", filter.UsernamePrefix))
+		}
+		return db
+	}
+}
+
+func (repo *GormUserRepository) List(ctx context.Context, query UserQuery) ([]model.User, error) {
+	expr, err := sortExpr(userSortExprs, query.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	db := repo.db.WithContext(ctx).Scopes(userScope(query.UserFilter))
+	if query.WithRecords {
+		db = db.Preload("Records")
+	}
+
+	users := []model.User{}
+	err = ApplyPage(db, expr, query.Page).Find(&users).Error
+	return users, err
+}
+
+func (repo *GormUserRepository) Count(ctx context.Context, filter UserFilter) (int64, error) {
+	var total int64
+	err := repo.db.WithContext(ctx).Model(&model.User{}).Scopes(userScope(filter)).Count(&total).Error
+	return total, err
+}
+
+func (repo *GormUserRepository) Get(ctx context.Context, id uint, withRecords bool) (model.User, error) {
+	db := repo.db.WithContext(ctx)
+	if withRecords {
+		db = db.Preload("Records")
+	}
+	var user model.User
+	err := db.First(&user, id).Error
+	return user, notFound(err)
+}
+
+// GetByUsername ignores case on every driver, as MySQL's default collation does
+func (repo *GormUserRepository) GetByUsername(ctx context.Context, username string, withRecords bool) (model.User, error) {
+	db := repo.db.WithContext(ctx)
+	if withRecords {
+		db = db.Preload("Records")
+	}
+	var user model.User
+	err := db.Where("LOWER(username) = LOWER(?)", username).First(&user).Error
+	return user, notFound(err)
+}
+
+func (repo *GormUserRepository) UsernameTaken(ctx context.Context, username string, exceptID uint) (bool, error) {
+	var count int64
+	err := repo.db.WithContext(ctx).Model(&model.User{}).
+		Where("LOWER(username) = LOWER(?) AND id <> ?", username, exceptID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (repo *GormUserRepository) Create(ctx context.Context, user *model.User) error {
+	return duplicate(repo.db, repo.db.WithContext(ctx).Create(user).Error)
+}
+
+func (repo *GormUserRepository) Update(ctx context.Context, user *model.User) error {
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		if user.Privacy != model.PrivacyPublic {
+			return nil
+		}
+		return tx.Model(&model.Follow{}).Where("followee_id = ? AND accepted = ?", user.ID, false).
+			Update("accepted", true).Error
+	})
+}
+
+func (repo *GormUserRepository) SetSyncKey(ctx context.Context, id uint, syncKey string) error {
+	return repo.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", id).Update("sync_key", syncKey).Error
+}
+
+func (repo *GormUserRepository) Delete(ctx context.Context, id uint) error {
+	result := repo.db.WithContext(ctx).Delete(&model.User{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordHook runs inside the transaction that saves a record, so its side effects
+// commit or roll back with the record. before is nil for a new record.
+type RecordHook func(tx *gorm.DB, before *model.Record, after model.Record) error
+
+// recordProgressExpr computes model.Record.Progress in SQL
+const recordProgressExpr = "ROUND(CASE WHEN total_pages > 0 THEN current_page * 1.0 / total_pages ELSE 0 END, 4)"
+
+// recordSortExprs are the columns and expressions of the record sorts
+var recordSortExprs = map[string]string{
+	"dateAdded": "date_added",
+	"title":     "title",
+	"author":    "author",
+	"progress":  recordProgressExpr,
+}
+
+// recordFacetExpr groups records by an expression, or by the year of a date
+// column, skipping rows where valid is false
+type recordFacetExpr struct {
+	expr  string
+	year  bool
+	valid string
+}
+
+// recordFacetExprs are the SQL expressions of the record facets
+var recordFacetExprs = map[string]recordFacetExpr{
+	"status":       {expr: "status", valid: "status <> ''"},
+	"genre":        {expr: "genre", valid: "genre <> ''"},
+	"author":       {expr: "author", valid: "author <> ''"},
+	"ownership":    {expr: "ownership", valid: "ownership <> ''"},
+	"yearAdded":    {expr: "date_added", year: true, valid: "date_added IS NOT NULL"},
+	"yearFinished": {expr: "date_finished", year: true, valid: "date_finished IS NOT NULL"},
+}
+
+// GormRecordRepository stores records in the database
+type GormRecordRepository struct {
+	db    *gorm.DB
+	hooks []RecordHook
+}
+
+// NewGormRecordRepository creates a record repository on a database connection.
+// The hooks run in order whenever a record is created or updated.
+func NewGormRecordRepository(db *gorm.DB, hooks ...RecordHook) *GormRecordRepository {
+	return &GormRecordRepository{db: db, hooks: hooks}
+}
+
+// recordScope applies a record filter
+func recordScope(filter RecordFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.UserID != nil {
+			db = db.Where("user_id = ?", *filter.UserID)
+		}
+		if filter.ISBN != "" {
+			db = db.Where("isbn = ?", filter.ISBN)
+		}
+		lists := []struct {
+			column string
+			values []string
+		}{
+			{"status", filter.Status},
+			{"genre", filter.Genre},
+			{"author", filter.Author},
+			{"ownership", filter.Ownership},
+		}
+		for _, list := range lists {
+			if len(list.values) > 0 {
+				db = db.Where(list.column+" IN ?", list.values)
+			}
+		}
+		if filter.AddedFrom != nil {
+			db = db.Where("date_added >= ?", *filter.AddedFrom)
+		}
+		if filter.AddedTo != nil {
+			db = db.Where("date_added < ?", *filter.AddedTo)
+		}
+		if filter.FinishedFrom != nil {
+			db = db.Where("date_finished >= ?", *filter.FinishedFrom)
+		}
+		if filter.FinishedTo != nil {
+			db = db.Where("date_finished < ?", *filter.FinishedTo)
+		}
+		return db
+	}
+}
+
+func (repo *GormRecordRepository) List(ctx context.Context, query RecordQuery) ([]model.Record, error) {
+	expr, err := sortExpr(recordSortExprs, query.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	db := repo.db.WithContext(ctx)
+	records := []model.Record{}
+	if err := ApplyPage(db.Scopes(recordScope(query.RecordFilter)), expr, query.Page).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	if err := attachLoans(db, records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// attachLoans sets the active loan of each record that is lent or borrowed
+func attachLoans(db *gorm.DB, records []model.Record) error {
+	ids := make([]uint, 0, len(records))
+	for _, record := range records {
+		if record.Ownership == model.OwnershipLent || record.Ownership == model.OwnershipBorrowed {
+			ids = append(ids, record.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var loans []model.Loan
+	if err := db.Where("returned_at IS NULL AND (record_id IN ? OR borrower_record_id IN ?)", ids, ids).
+		Find(&loans).Error; err != nil {
+		return err
+	}
+
+	byRecord := make(map[uint]*model.Loan, len(loans)*2)
+	for i := range loans {
+		byRecord[loans[i].RecordID] = &loans[i]
+		if loans[i].BorrowerRecordID != nil {
+			byRecord[*loans[i].BorrowerRecordID] = &loans[i]
+		}
+	}
+	for i := range records {
+		records[i].Loan = byRecord[records[i].ID]
+	}
+	return nil
+}
+
+func (repo *GormRecordRepository) Count(ctx context.Context, filter RecordFilter) (int64, error) {
+	var total int64
+	err := repo.db.WithContext(ctx).Model(&model.Record{}).Scopes(recordScope(filter)).Count(&total).Error
+	return total, err
+}
+
+func (repo *GormRecordRepository) Facets(ctx context.Context, filter RecordFilter, names []string) (map[string][]FacetCount, error) {
+	result := make(map[string][]FacetCount, len(names))
+	for _, name := range names {
+		facet, ok := recordFacetExprs[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported facet %q", name)
+		}
+		expr := facet.expr
+		if facet.year {
+			expr = yearExpr(repo.db, expr)
+		}
+		counts := []FacetCount{}
+		err := repo.db.WithContext(ctx).Model(&model.Record{}).
+			Scopes(recordScope(filter.Without(recordFacets[name]))).
+			Select(expr + " AS value, COUNT(*) AS count").
+			Where(facet.valid).
+			Group("value").
+			Order("count DESC, value").
+			Limit(facetLimit).
+			Scan(&counts).Error
+		if err != nil {
+			return nil, err
+		}
+		result[name] = counts
+	}
+	return result, nil
+}
+
+func (repo *GormRecordRepository) Get(ctx context.Context, userID uint, isbn string) (model.Record, error) {
+	var record model.Record
+	err := repo.db.WithContext(ctx).Where("user_id = ? AND isbn = ?", userID, isbn).First(&record).Error
+	return record, notFound(err)
+}
+
+func (repo *GormRecordRepository) Exists(ctx context.Context, userID uint, isbn string) (bool, error) {
+	var count int64
+	err := repo.db.WithContext(ctx).Model(&model.Record{}).
+		Where("user_id = ? AND isbn = ?", userID, isbn).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (repo *GormRecordRepository) Create(ctx context.Context, record *model.Record) error {
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return duplicate(tx, err)
+		}
+		return repo.runHooks(tx, nil, *record)
+	})
+}
+
+func (repo *GormRecordRepository) Import(ctx context.Context, record *model.Record) error {
+	return duplicate(repo.db, repo.db.WithContext(ctx).Create(record).Error)
+}
+
+func (repo *GormRecordRepository) Update(ctx context.Context, before model.Record, record *model.Record) error {
+	record.Version = before.Version + 1
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Only the writer that still sees the version it read gets to save
+		result := tx.Model(record).Where("version = ?", before.Version).Select("*").Updates(record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return repo.runHooks(tx, &before, *record)
+	})
+}
+
+func (repo *GormRecordRepository) runHooks(tx *gorm.DB, before *model.Record, after model.Record) error {
+	for _, hook := range repo.hooks {
+		if err := hook(tx, before, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *GormRecordRepository) Delete(ctx context.Context, userID uint, isbn string, version uint) error {
+	// Deleting the loaded row lets the search index see which record went away
+	record, err := repo.Get(ctx, userID, isbn)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		return repo.db.WithContext(ctx).Delete(&record).Error
+	}
+
+	result := repo.db.WithContext(ctx).Where("version = ?", version).Delete(&record)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// NextVersion bumps the version of records in an UPDATE. Writes outside the
+// repository use it so the ETags of the records they change go stale.
+func NextVersion() clause.Expr {
+	return gorm.Expr("version + 1")
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"biblia-be/internal/db"
+	"biblia-be/internal/model"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestGormCreateDuplicate(t *testing.T) {
+	conn, err := db.NewDB(db.DriverSQLite, "", "", "", filepath.Join(t.TempDir(), "repository.db"), "", 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	migrator, err := db.NewMigrator(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	users := NewGormUserRepository(conn)
+	user := model.User{Username: "wanida"}
+	if err := users.Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Create(ctx, &model.User{ID: user.ID, Username: "kittipong"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("user with a taken ID: got %v, want ErrDuplicate", err)
+	}
+
+	records := NewGormRecordRepository(conn)
+	if err := records.Create(ctx, &model.Record{UserID: user.ID, ISBN: "9786161851125", Title: "Khu Kam"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := records.Create(ctx, &model.Record{UserID: user.ID, ISBN: "9786161851125", Title: "Khu Kam"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Create: got %v, want ErrDuplicate", err)
+	}
+	if err := records.Import(ctx, &model.Record{UserID: user.ID, ISBN: "9786161851125", Title: "Khu Kam"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Import: got %v, want ErrDuplicate", err)
+	}
+
+	// Other failures are passed on as they are
+	if err := records.Create(ctx, &model.Record{UserID: 99, ISBN: "9786161851125", Title: "Khu Kam"}); err == nil || errors.Is(err, ErrDuplicate) {
+		t.Errorf("record of a missing user: got %v, want a foreign key error", err)
+	}
+}
@@ -0,0 +1,459 @@
+package repository
+
+import (
+	"biblia-be/internal/model"
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// compareValues orders two sort values of the same kind. Strings compare without
+// case, like the database collation.
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b.(string)))
+	default:
+		return cmp.Compare(toFloat(a), toFloat(b))
+	}
+}
+
+// toFloat converts a numeric sort value, which cursors carry as float64
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case uint:
+		return float64(v)
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	panic(fmt.Sprintf("unsupported sort value %T", value))
+}
+
+// memorySort returns the sort value of an item
+type memorySort[T any] map[string]func(T) interface{}
+
+// page sorts items in place and returns the window selected by page
+func (sorts memorySort[T]) page(items []T, page Page, id func(T) uint) ([]T, error) {
+	value, ok := sorts[page.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort %q", page.Sort)
+	}
+
+	// order compares an item with a sort value and ID in the page direction
+	order := func(item T, v interface{}, itemID uint) int {
+		c := compareValues(value(item), v)
+		if c == 0 {
+			c = cmp.Compare(id(item), itemID)
+		}
+		if page.Desc {
+			c = -c
+		}
+		return c
+	}
+	sort.Slice(items, func(i, j int) bool { return order(items[i], value(items[j]), id(items[j])) < 0 })
+
+	result := []T{}
+	for _, item := range items {
+		if page.After != nil && order(item, page.After.Value, page.After.ID) <= 0 {
+			continue
+		}
+		if page.Limit > 0 && len(result) == page.Limit {
+			break
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+var memoryUserSorts = memorySort[model.User]{
+	"id":       func(u model.User) interface{} { return u.ID },
+	"username": func(u model.User) interface{} { return u.Username },
+}
+
+// MemoryUserRepository keeps users in process memory, for tests
+type MemoryUserRepository struct {
+	mu      sync.RWMutex
+	users   map[uint]model.User
+	lastID  uint
+	records *MemoryRecordRepository
+}
+
+// NewMemoryUserRepository creates an empty user repository. Records are loaded
+// from records when requested, which may be nil for users without records.
+func NewMemoryUserRepository(records *MemoryRecordRepository) *MemoryUserRepository {
+	return &MemoryUserRepository{users: make(map[uint]model.User), records: records}
+}
+
+func (filter UserFilter) matches(user model.User) bool {
+	return strings.HasPrefix(strings.ToLower(user.Username), strings.ToLower(filter.UsernamePrefix))
+}
+
+// withRecords sets the records of a user when requested
+func (repo *MemoryUserRepository) withRecords(user model.User, load bool) model.User {
+	if load && repo.records != nil {
+		user.Records = repo.records.byUser(user.ID)
+	}
+	return user
+}
+
+func (repo *MemoryUserRepository) List(ctx context.Context, query UserQuery) ([]model.User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var users []model.User
+	for _, user := range repo.users {
+		if query.matches(user) {
+			users = append(users, repo.withRecords(user, query.WithRecords))
+		}
+	}
+	return memoryUserSorts.page(users, query.Page, func(u model.User) uint { return u.ID })
+}
+
+func (repo *MemoryUserRepository) Count(ctx context.Context, filter UserFilter) (int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var total int64
+	for _, user := range repo.users {
+		if filter.matches(user) {
+			total++
+		}
+	}
+	return total, nil
+}
+
+func (repo *MemoryUserRepository) Get(ctx context.Context, id uint, withRecords bool) (model.User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	user, ok := repo.users[id]
+	if !ok {
+		return model.User{}, ErrNotFound
+	}
+	return repo.withRecords(user, withRecords), nil
+}
+
+func (repo *MemoryUserRepository) GetByUsername(ctx context.Context, username string, withRecords bool) (model.User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	for _, user := range repo.users {
+		if strings.EqualFold(user.Username, username) {
+			return repo.withRecords(user, withRecords), nil
+		}
+	}
+	return model.User{}, ErrNotFound
+}
+
+func (repo *MemoryUserRepository) UsernameTaken(ctx context.Context, username string, exceptID uint) (bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	for _, user := range repo.users {
+		if user.ID != exceptID && strings.EqualFold(user.Username, username) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (repo *MemoryUserRepository) Create(ctx context.Context, user *model.User) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	repo.lastID++
+	user.ID = repo.lastID
+	stored := *user
+	stored.Records = nil
+	repo.users[user.ID] = stored
+	return nil
+}
+
+func (repo *MemoryUserRepository) Update(ctx context.Context, user *model.User) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.users[user.ID]; !ok {
+		return ErrNotFound
+	}
+	stored := *user
+	stored.Records = nil
+	repo.users[user.ID] = stored
+	return nil
+}
+
+func (repo *MemoryUserRepository) SetSyncKey(ctx context.Context, id uint, syncKey string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	user, ok := repo.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.SyncKey = syncKey
+	repo.users[id] = user
+	return nil
+}
+
+// Delete removes the user and, like the foreign key, their records
+func (repo *MemoryUserRepository) Delete(ctx context.Context, id uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(repo.users, id)
+	if repo.records != nil {
+		repo.records.deleteUser(id)
+	}
+	return nil
+}
+
+var memoryRecordSorts = memorySort[model.Record]{
+	"dateAdded": func(r model.Record) interface{} { return r.DateAdded },
+	"title":     func(r model.Record) interface{} { return r.Title },
+	"author":    func(r model.Record) interface{} { return r.Author },
+	"progress":  func(r model.Record) interface{} { return r.Progress() },
+}
+
+// memoryFacetValues return the facet value of a record and whether it has one
+var memoryFacetValues = map[string]func(model.Record) (string, bool){
+	"status":    func(r model.Record) (string, bool) { return r.Status, r.Status != "" },
+	"genre":     func(r model.Record) (string, bool) { return r.Genre, r.Genre != "" },
+	"author":    func(r model.Record) (string, bool) { return r.Author, r.Author != "" },
+	"ownership": func(r model.Record) (string, bool) { return r.Ownership, r.Ownership != "" },
+	"yearAdded": func(r model.Record) (string, bool) { return strconv.Itoa(r.DateAdded.Year()), true },
+	"yearFinished": func(r model.Record) (string, bool) {
+		if r.DateFinished == nil {
+			return "", false
+		}
+		return strconv.Itoa(r.DateFinished.Year()), true
+	},
+}
+
+// MemoryRecordRepository keeps records in process memory, for tests. Records have
+// no loans and no hooks run.
+type MemoryRecordRepository struct {
+	mu      sync.RWMutex
+	records map[uint]model.Record
+	lastID  uint
+}
+
+// NewMemoryRecordRepository creates an empty record repository
+func NewMemoryRecordRepository() *MemoryRecordRepository {
+	return &MemoryRecordRepository{records: make(map[uint]model.Record)}
+}
+
+// contains reports whether value is one of values, or values is empty
+func contains(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (filter RecordFilter) matches(record model.Record) bool {
+	if filter.UserID != nil && record.UserID != *filter.UserID {
+		return false
+	}
+	if filter.ISBN != "" && record.ISBN != filter.ISBN {
+		return false
+	}
+	if !contains(filter.Status, record.Status) || !contains(filter.Genre, record.Genre) ||
+		!contains(filter.Author, record.Author) || !contains(filter.Ownership, record.Ownership) {
+		return false
+	}
+	if filter.AddedFrom != nil && record.DateAdded.Before(*filter.AddedFrom) {
+		return false
+	}
+	if filter.AddedTo != nil && !record.DateAdded.Before(*filter.AddedTo) {
+		return false
+	}
+	if filter.FinishedFrom != nil && (record.DateFinished == nil || record.DateFinished.Before(*filter.FinishedFrom)) {
+		return false
+	}
+	if filter.FinishedTo != nil && (record.DateFinished == nil || !record.DateFinished.Before(*filter.FinishedTo)) {
+		return false
+	}
+	return true
+}
+
+// matching returns the records matching filter
+func (repo *MemoryRecordRepository) matching(filter RecordFilter) []model.Record {
+	var records []model.Record
+	for _, record := range repo.records {
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// byUser returns the records of a user in ID order
+func (repo *MemoryRecordRepository) byUser(userID uint) []model.Record {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	records := repo.matching(RecordFilter{UserID: &userID})
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records
+}
+
+// deleteUser removes the records of a user
+func (repo *MemoryRecordRepository) deleteUser(userID uint) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for id, record := range repo.records {
+		if record.UserID == userID {
+			delete(repo.records, id)
+		}
+	}
+}
+
+// find returns the record of a user for an ISBN
+func (repo *MemoryRecordRepository) find(userID uint, isbn string) (model.Record, bool) {
+	for _, record := range repo.records {
+		if record.UserID == userID && record.ISBN == isbn {
+			return record, true
+		}
+	}
+	return model.Record{}, false
+}
+
+func (repo *MemoryRecordRepository) List(ctx context.Context, query RecordQuery) ([]model.Record, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return memoryRecordSorts.page(repo.matching(query.RecordFilter), query.Page, func(r model.Record) uint { return r.ID })
+}
+
+func (repo *MemoryRecordRepository) Count(ctx context.Context, filter RecordFilter) (int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return int64(len(repo.matching(filter))), nil
+}
+
+func (repo *MemoryRecordRepository) Facets(ctx context.Context, filter RecordFilter, names []string) (map[string][]FacetCount, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	result := make(map[string][]FacetCount, len(names))
+	for _, name := range names {
+		facetValue, ok := memoryFacetValues[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported facet %q", name)
+		}
+		byValue := map[string]int64{}
+		for _, record := range repo.matching(filter.Without(recordFacets[name])) {
+			if value, ok := facetValue(record); ok {
+				byValue[value]++
+			}
+		}
+
+		counts := []FacetCount{}
+		for value, count := range byValue {
+			counts = append(counts, FacetCount{Value: value, Count: count})
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			if counts[i].Count != counts[j].Count {
+				return counts[i].Count > counts[j].Count
+			}
+			return counts[i].Value < counts[j].Value
+		})
+		if len(counts) > facetLimit {
+			counts = counts[:facetLimit]
+		}
+		result[name] = counts
+	}
+	return result, nil
+}
+
+func (repo *MemoryRecordRepository) Get(ctx context.Context, userID uint, isbn string) (model.Record, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	record, ok := repo.find(userID, isbn)
+	if !ok {
+		return model.Record{}, ErrNotFound
+	}
+	return record, nil
+}
+
+func (repo *MemoryRecordRepository) Exists(ctx context.Context, userID uint, isbn string) (bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	_, ok := repo.find(userID, isbn)
+	return ok, nil
+}
+
+func (repo *MemoryRecordRepository) Create(ctx context.Context, record *model.Record) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.find(record.UserID, record.ISBN); ok {
+		return ErrDuplicate
+	}
+	repo.lastID++
+	record.ID = repo.lastID
+	if record.Ownership == "" {
+		record.Ownership = model.OwnershipOwned
+	}
+	if record.Version == 0 {
+		record.Version = 1
+	}
+	repo.records[record.ID] = *record
+	return nil
+}
+
+func (repo *MemoryRecordRepository) Import(ctx context.Context, record *model.Record) error {
+	return repo.Create(ctx, record)
+}
+
+func (repo *MemoryRecordRepository) Update(ctx context.Context, before model.Record, record *model.Record) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	stored, ok := repo.records[record.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Version != before.Version {
+		return ErrVersionConflict
+	}
+	record.Version = before.Version + 1
+	repo.records[record.ID] = *record
+	return nil
+}
+
+func (repo *MemoryRecordRepository) Delete(ctx context.Context, userID uint, isbn string, version uint) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	record, ok := repo.find(userID, isbn)
+	if !ok {
+		return ErrNotFound
+	}
+	if version != 0 && record.Version != version {
+		return ErrVersionConflict
+	}
+	delete(repo.records, record.ID)
+	return nil
+}
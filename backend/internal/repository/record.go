@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"biblia-be/internal/model"
+	"context"
+	"time"
+)
+
+// Filter groups of RecordFilter. Facets leave out the conditions of their own
+// group, so every value stays visible while one of them is selected.
+const (
+	GroupUserID    = "userId"
+	GroupISBN      = "isbn"
+	GroupStatus    = "status"
+	GroupGenre     = "genre"
+	GroupAuthor    = "author"
+	GroupOwnership = "ownership"
+	GroupAdded     = "added"
+	GroupFinished  = "finished"
+)
+
+// RecordFilter selects records. Set fields combine with AND and list fields
+// match any of their values.
+type RecordFilter struct {
+	UserID    *uint
+	ISBN      string
+	Status    []string
+	Genre     []string
+	Author    []string
+	Ownership []string
+	// AddedFrom and FinishedFrom are inclusive, AddedTo and FinishedTo exclusive
+	AddedFrom    *time.Time
+	AddedTo      *time.Time
+	FinishedFrom *time.Time
+	FinishedTo   *time.Time
+}
+
+// Without returns a copy of the filter without the conditions of a group
+func (filter RecordFilter) Without(group string) RecordFilter {
+	switch group {
+	case GroupUserID:
+		filter.UserID = nil
+	case GroupISBN:
+		filter.ISBN = ""
+	case GroupStatus:
+		filter.Status = nil
+	case GroupGenre:
+		filter.Genre = nil
+	case GroupAuthor:
+		filter.Author = nil
+	case GroupOwnership:
+		filter.Ownership = nil
+	case GroupAdded:
+		filter.AddedFrom, filter.AddedTo = nil, nil
+	case GroupFinished:
+		filter.FinishedFrom, filter.FinishedTo = nil, nil
+	}
+	return filter
+}
+
+// RecordQuery is a filtered page of records, sorted by "dateAdded", "title",
+// "author" or "progress"
+type RecordQuery struct {
+	RecordFilter
+	Page
+}
+
+// facetLimit caps the number of values returned per facet
+const facetLimit = 50
+
+// recordFacets maps the supported facets to the filter group they ignore
+var recordFacets = map[string]string{
+	"status":       GroupStatus,
+	"genre":        GroupGenre,
+	"author":       GroupAuthor,
+	"ownership":    GroupOwnership,
+	"yearAdded":    GroupAdded,
+	"yearFinished": GroupFinished,
+}
+
+// IsRecordFacet reports whether records can be counted by the named facet
+func IsRecordFacet(name string) bool {
+	_, ok := recordFacets[name]
+	return ok
+}
+
+// RecordRepository stores reading records, unique per user and ISBN
+type RecordRepository interface {
+	// List returns a page of records. Lent and borrowed records carry their
+	// active loan.
+	List(ctx context.Context, query RecordQuery) ([]model.Record, error)
+	// Count returns the number of records matching filter
+	Count(ctx context.Context, filter RecordFilter) (int64, error)
+	// Facets counts the records matching filter by each of the named facets, most
+	// common values first. Each facet ignores the filter group it counts.
+	Facets(ctx context.Context, filter RecordFilter, names []string) (map[string][]FacetCount, error)
+	// Get returns the record of a user for an ISBN, or ErrNotFound
+	Get(ctx context.Context, userID uint, isbn string) (model.Record, error)
+	// Exists reports whether the user has a record for the ISBN
+	Exists(ctx context.Context, userID uint, isbn string) (bool, error)
+	// Create stores a new record, sets its ID and runs the hooks. It returns
+	// ErrDuplicate when the user already has a record for the ISBN.
+	Create(ctx context.Context, record *model.Record) error
+	// Import stores a new record without running the hooks, so bulk imports do
+	// not flood the activity feed. Duplicates fail like in Create.
+	Import(ctx context.Context, record *model.Record) error
+	// Update saves every field of an existing record, bumps its version and runs
+	// the hooks. It returns ErrVersionConflict when the stored record no longer
+	// has the version of before.
+	Update(ctx context.Context, before model.Record, record *model.Record) error
+	// Delete removes the record of a user for an ISBN, or returns ErrNotFound.
+	// A non-zero version must match the stored one, else ErrVersionConflict is
+	// returned.
+	Delete(ctx context.Context, userID uint, isbn string, version uint) error
+}
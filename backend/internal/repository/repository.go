@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"errors"
+)
+
+// ErrNotFound is returned when the requested user or record does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrVersionConflict is returned when a record changed after it was read
+var ErrVersionConflict = errors.New("version conflict")
+
+// ErrDuplicate is returned when a write would break a unique index, as when two
+// requests create a record of the same book at once
+var ErrDuplicate = errors.New("duplicate")
+
+// Cursor is the keyset position of the last item of a page: its sort value and
+// ID, which together are unique
+type Cursor struct {
+	Value interface{}
+	ID    uint
+}
+
+// Page selects a window of a sorted list. Sort names one of the orderings the
+// list supports, and at most Limit items after the After position are returned.
+type Page struct {
+	Sort  string
+	Desc  bool
+	After *Cursor
+	Limit int
+}
+
+// FacetCount is the number of matching records with one value of a facet
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
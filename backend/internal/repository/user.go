@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"biblia-be/internal/model"
+	"context"
+)
+
+// UserFilter selects users
+type UserFilter struct {
+	// UsernamePrefix matches usernames starting with it, ignoring case
+	UsernamePrefix string
+}
+
+// UserQuery is a filtered page of users, sorted by "id" or "username"
+type UserQuery struct {
+	UserFilter
+	Page
+	// WithRecords loads each user's reading records
+	WithRecords bool
+}
+
+// UserRepository stores user accounts. Usernames compare without case, like the
+// database collation does.
+type UserRepository interface {
+	// List returns a page of users
+	List(ctx context.Context, query UserQuery) ([]model.User, error)
+	// Count returns the number of users matching filter
+	Count(ctx context.Context, filter UserFilter) (int64, error)
+	// Get returns a user by ID, or ErrNotFound
+	Get(ctx context.Context, id uint, withRecords bool) (model.User, error)
+	// GetByUsername returns a user by username, or ErrNotFound
+	GetByUsername(ctx context.Context, username string, withRecords bool) (model.User, error)
+	// UsernameTaken reports whether a user other than exceptID has the username
+	UsernameTaken(ctx context.Context, username string, exceptID uint) (bool, error)
+	// Create stores a new user and sets its ID, or returns ErrDuplicate
+	Create(ctx context.Context, user *model.User) error
+	// Update saves every field of an existing user. Pending follow requests are
+	// accepted once the profile is public.
+	Update(ctx context.Context, user *model.User) error
+	// SetSyncKey replaces the e-reader sync key of a user
+	SetSyncKey(ctx context.Context, id uint, syncKey string) error
+	// Delete removes a user, or returns ErrNotFound
+	Delete(ctx context.Context, id uint) error
+}
@@ -0,0 +1,252 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Field weights used for ranking, in the order of memoryDoc.fields
+var fieldWeights = [...]float64{4, 3, 2, 2, 1}
+
+// notesField is the index of notes in memoryDoc.fields; catalog searches skip it
+const notesField = 4
+
+// memoryDoc is a document with its normalized fields: title, author, genre, ISBN, notes
+type memoryDoc struct {
+	Document
+	fields [5]string
+}
+
+// MemoryIndex is an in-process inverted index over character unigrams and bigrams.
+// Candidates are found through the grams of each term and then verified against
+// the normalized text, so any substring matches, including inside unspaced Thai.
+// It keeps nothing on disk and suits tests and single instance deployments.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[uint]*memoryDoc
+	postings map[string]map[uint]struct{}
+}
+
+// NewMemoryIndex creates an empty index
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     make(map[uint]*memoryDoc),
+		postings: make(map[string]map[uint]struct{}),
+	}
+}
+
+// grams returns the distinct unigrams and bigrams of the words in text
+func grams(text string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		for i := range runes {
+			set[string(runes[i])] = struct{}{}
+			if i+1 < len(runes) {
+				set[string(runes[i:i+2])] = struct{}{}
+			}
+		}
+	}
+	return set
+}
+
+// termGrams returns the grams a document must contain to match term
+func termGrams(term string) []string {
+	runes := []rune(term)
+	if len(runes) == 1 {
+		return []string{term}
+	}
+	result := make([]string, 0, len(runes)-1)
+	for i := 0; i+1 < len(runes); i++ {
+		result = append(result, string(runes[i:i+2]))
+	}
+	return result
+}
+
+// Index adds or replaces documents
+func (m *MemoryIndex) Index(ctx context.Context, docs ...Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, doc := range docs {
+		m.remove(doc.RecordID)
+
+		entry := &memoryDoc{Document: doc}
+		entry.fields = [5]string{
+			Normalize(doc.Title),
+			Normalize(doc.Author),
+			Normalize(doc.Genre),
+			Normalize(doc.ISBN),
+			Normalize(doc.Notes),
+		}
+		m.docs[doc.RecordID] = entry
+
+		for gram := range grams(strings.Join(entry.fields[:], " ")) {
+			if m.postings[gram] == nil {
+				m.postings[gram] = make(map[uint]struct{})
+			}
+			m.postings[gram][doc.RecordID] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// Delete removes the documents of the given records
+func (m *MemoryIndex) Delete(ctx context.Context, recordIDs ...uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range recordIDs {
+		m.remove(id)
+	}
+	return nil
+}
+
+// remove drops a document and its postings. The caller must hold the write lock.
+func (m *MemoryIndex) remove(id uint) {
+	entry, ok := m.docs[id]
+	if !ok {
+		return
+	}
+	for gram := range grams(strings.Join(entry.fields[:], " ")) {
+		delete(m.postings[gram], id)
+		if len(m.postings[gram]) == 0 {
+			delete(m.postings, gram)
+		}
+	}
+	delete(m.docs, id)
+}
+
+// Count returns the number of indexed documents
+func (m *MemoryIndex) Count(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int64(len(m.docs)), nil
+}
+
+// Search returns the best matches for a query
+func (m *MemoryIndex) Search(ctx context.Context, query Query) ([]Hit, error) {
+	terms := Terms(query.Text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	fields := len(fieldWeights)
+	owned := map[string]bool{}
+	if query.Catalog {
+		fields = notesField
+		for _, entry := range m.docs {
+			if entry.UserID == query.UserID {
+				owned[entry.ISBN] = true
+			}
+		}
+	}
+
+	best := map[string]Hit{}
+	var hits []Hit
+	for id := range m.candidates(terms) {
+		entry := m.docs[id]
+		if query.Catalog {
+			if entry.UserID == query.UserID || owned[entry.ISBN] {
+				continue
+			}
+		} else if entry.UserID != query.UserID {
+			continue
+		}
+
+		score, ok := entry.score(terms, fields)
+		if !ok {
+			continue
+		}
+		hit := Hit{RecordID: id, ISBN: entry.ISBN, Score: score}
+
+		if !query.Catalog {
+			hits = append(hits, hit)
+			continue
+		}
+		if prev, seen := best[entry.ISBN]; !seen || hit.Score > prev.Score ||
+			(hit.Score == prev.Score && hit.RecordID > prev.RecordID) {
+			best[entry.ISBN] = hit
+		}
+	}
+	for _, hit := range best {
+		hits = append(hits, hit)
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].RecordID > hits[j].RecordID
+	})
+	if query.Limit > 0 && len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
+
+// candidates returns the documents containing every gram of every term. The caller
+// must hold the read lock.
+func (m *MemoryIndex) candidates(terms []string) map[uint]struct{} {
+	var result map[uint]struct{}
+	for _, term := range terms {
+		for _, gram := range termGrams(term) {
+			postings := m.postings[gram]
+			if result == nil {
+				result = make(map[uint]struct{}, len(postings))
+				for id := range postings {
+					result[id] = struct{}{}
+				}
+				continue
+			}
+			for id := range result {
+				if _, ok := postings[id]; !ok {
+					delete(result, id)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// score ranks a document against the terms using the first n fields. A term
+// scores the weight of the best field it occurs in: triple for a whole word,
+// double for a word prefix. Every term must occur for the document to match.
+func (entry *memoryDoc) score(terms []string, n int) (float64, bool) {
+	var total float64
+	for _, term := range terms {
+		var best float64
+		for i := 0; i < n; i++ {
+			if s := fieldWeights[i] * matchQuality(entry.fields[i], term); s > best {
+				best = s
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		total += best
+	}
+	return total, true
+}
+
+// matchQuality grades how term occurs in a normalized field
+func matchQuality(field, term string) float64 {
+	if !strings.Contains(field, term) {
+		return 0
+	}
+	quality := 1.0
+	for _, word := range strings.Fields(field) {
+		if word == term {
+			return 3
+		}
+		if strings.HasPrefix(word, term) {
+			quality = 2
+		}
+	}
+	return quality
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// newTestIndex indexes a few books of three users
+func newTestIndex(t *testing.T) *MemoryIndex {
+	t.Helper()
+	index := NewMemoryIndex()
+	err := index.Index(context.Background(),
+		Document{RecordID: 1, UserID: 1, ISBN: "9786161851125", Title: "คู่กรรม", Author: "ทมยันตี", Genre: "นวนิยาย"},
+		Document{RecordID: 2, UserID: 1, ISBN: "hobbit", Title: "The Hobbit", Author: "J.R.R. Tolkien", Genre: "fantasy"},
+		Document{RecordID: 3, UserID: 1, ISBN: "crafts", Title: "Hobbies and Crafts", Author: "Somchai", Genre: "craft"},
+		Document{RecordID: 4, UserID: 1, ISBN: "tolkien", Title: "Tolkien: A Biography", Author: "Humphrey Carpenter", Notes: "read after the hobbit"},
+		Document{RecordID: 5, UserID: 2, ISBN: "hobbit", Title: "The Hobbit", Author: "J.R.R. Tolkien"},
+		Document{RecordID: 6, UserID: 3, ISBN: "hobbit", Title: "The Hobbit", Author: "J.R.R. Tolkien"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
+
+// search returns the record IDs of the hits in order
+func search(t *testing.T, index *MemoryIndex, query Query) string {
+	t.Helper()
+	hits, err := index.Search(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.RecordID
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	index := newTestIndex(t)
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		// A title beats notes, and a whole word beats a prefix
+		{"whole word", Query{Text: "hobbit", UserID: 1}, "[2 4]"},
+		{"case and accents", Query{Text: "HÓBBIT", UserID: 1}, "[2 4]"},
+		{"prefix ties by newest", Query{Text: "hob", UserID: 1}, "[3 2 4]"},
+		{"title before author", Query{Text: "tolk", UserID: 1}, "[4 2]"},
+		{"every term must match", Query{Text: "hobbit tolkien", UserID: 1}, "[2 4]"},
+		{"inside a word", Query{Text: "obbi", UserID: 1}, "[3 2 4]"},
+		{"limit", Query{Text: "hob", UserID: 1, Limit: 2}, "[3 2]"},
+		{"no match", Query{Text: "hobbit dragon", UserID: 1}, "[]"},
+		{"thai prefix without tone marks", Query{Text: "คูกร", UserID: 1}, "[1]"},
+		{"inside unspaced thai", Query{Text: "กรรม", UserID: 1}, "[1]"},
+		{"thai author", Query{Text: "ทมยันตี", UserID: 1}, "[1]"},
+		{"other users", Query{Text: "hobbit", UserID: 2}, "[5]"},
+		// The catalog holds one hit per ISBN, skips notes and what the user owns
+		{"catalog", Query{Text: "hobbit", UserID: 4, Catalog: true}, "[6]"},
+		{"catalog skips owned isbns", Query{Text: "hobbit", UserID: 2, Catalog: true}, "[]"},
+		{"catalog skips notes", Query{Text: "tolkien", UserID: 2, Catalog: true}, "[4]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := search(t, index, tc.query); got != tc.want {
+				t.Errorf("%+v: got %s, want %s", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMemoryIndexUpdates(t *testing.T) {
+	ctx := context.Background()
+	index := newTestIndex(t)
+
+	if err := index.Delete(ctx, 2, 99); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(t, index, Query{Text: "hobbit", UserID: 1}); got != "[4]" {
+		t.Errorf("after delete: got %s, want [4]", got)
+	}
+
+	// Indexing a record again replaces its old text
+	if err := index.Index(ctx, Document{RecordID: 3, UserID: 1, ISBN: "crafts", Title: "The Hobbit Returns"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(t, index, Query{Text: "hobbit", UserID: 1}); got != "[3 4]" {
+		t.Errorf("after reindex: got %s, want [3 4]", got)
+	}
+	if got := search(t, index, Query{Text: "somchai", UserID: 1}); got != "[]" {
+		t.Errorf("old text: got %s, want no hits", got)
+	}
+
+	if count, err := index.Count(ctx); err != nil || count != 5 {
+		t.Errorf("Count() = %d, %v; want 5", count, err)
+	}
+}
@@ -0,0 +1,134 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// mysqlDocument is a row of the search_documents table. Fields are stored
+// normalized so matching behaves the same as in MemoryIndex regardless of the
+// column collation. The FULLTEXT indexes are defined in the schema migrations.
+type mysqlDocument struct {
+	RecordID uint `gorm:"primaryKey;autoIncrement:false"`
+	UserID   uint
+	ISBN     string
+	Title    string
+	Meta     string
+	Notes    string
+}
+
+func (mysqlDocument) TableName() string {
+	return "search_documents"
+}
+
+// MySQLIndex keeps documents in a table with FULLTEXT indexes built by the ngram
+// parser, which splits text into overlapping character pairs and so handles Thai,
+// which is written without spaces between words
+type MySQLIndex struct {
+	db *gorm.DB
+}
+
+// NewMySQLIndex uses the search_documents table created by the schema migrations
+func NewMySQLIndex(db *gorm.DB) (*MySQLIndex, error) {
+	if !db.Migrator().HasTable(&mysqlDocument{}) {
+		return nil, errors.New("search_documents table is missing, run the migrations first")
+	}
+	return &MySQLIndex{db: db}, nil
+}
+
+// Index adds or replaces documents
+func (m *MySQLIndex) Index(ctx context.Context, docs ...Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	rows := make([]mysqlDocument, len(docs))
+	for i, doc := range docs {
+		rows[i] = mysqlDocument{
+			RecordID: doc.RecordID,
+			UserID:   doc.UserID,
+			ISBN:     doc.ISBN,
+			Title:    Normalize(doc.Title),
+			Meta:     Normalize(doc.Author + " " + doc.Genre + " " + doc.ISBN),
+			Notes:    Normalize(doc.Notes),
+		}
+	}
+	return m.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&rows).Error
+}
+
+// Delete removes the documents of the given records
+func (m *MySQLIndex) Delete(ctx context.Context, recordIDs ...uint) error {
+	if len(recordIDs) == 0 {
+		return nil
+	}
+	return m.db.WithContext(ctx).Delete(&mysqlDocument{}, recordIDs).Error
+}
+
+// Count returns the number of indexed documents
+func (m *MySQLIndex) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := m.db.WithContext(ctx).Model(&mysqlDocument{}).Count(&count).Error
+	return count, err
+}
+
+// booleanQuery requires every term. The ngram parser turns a quoted term into a
+// phrase of its character pairs, which matches the term anywhere in a word; a
+// single character is shorter than a pair and needs a wildcard instead.
+func booleanQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		if len([]rune(term)) == 1 {
+			parts[i] = "+" + term + "*"
+		} else {
+			parts[i] = `+"` + term + `"`
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Search returns the best matches for a query. Title matches count double.
+func (m *MySQLIndex) Search(ctx context.Context, query Query) ([]Hit, error) {
+	terms := Terms(query.Text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	against := booleanQuery(terms)
+	limit := query.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var hits []Hit
+	db := m.db.WithContext(ctx)
+
+	if !query.Catalog {
+		err := db.Model(&mysqlDocument{}).
+			Select("record_id, isbn, "+
+				"MATCH(title) AGAINST (? IN BOOLEAN MODE) * 2 + MATCH(title, meta, notes) AGAINST (? IN BOOLEAN MODE) AS score",
+				against, against).
+			Where("user_id = ? AND MATCH(title, meta, notes) AGAINST (? IN BOOLEAN MODE)", query.UserID, against).
+			Order("score DESC, record_id DESC").
+			Limit(limit).
+			Scan(&hits).Error
+		return hits, err
+	}
+
+	matches := db.Model(&mysqlDocument{}).
+		Select("record_id, isbn, "+
+			"MATCH(title) AGAINST (? IN BOOLEAN MODE) * 2 + MATCH(title, meta) AGAINST (? IN BOOLEAN MODE) AS score",
+			against, against).
+		Where("user_id <> ? AND MATCH(title, meta) AGAINST (? IN BOOLEAN MODE)", query.UserID, against).
+		Where("isbn NOT IN (?)", db.Model(&mysqlDocument{}).Select("isbn").Where("user_id = ?", query.UserID))
+
+	err := db.Table("(?) AS matches", matches).
+		Select("MAX(record_id) AS record_id, isbn, MAX(score) AS score").
+		Group("isbn").
+		Order("score DESC, record_id DESC").
+		Limit(limit).
+		Scan(&hits).Error
+	return hits, err
+}
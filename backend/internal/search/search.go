@@ -0,0 +1,99 @@
+package search
+
+import (
+	"biblia-be/internal/model"
+	"context"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Document is the searchable text of a record
+type Document struct {
+	RecordID uint
+	UserID   uint
+	ISBN     string
+	Title    string
+	Author   string
+	Genre    string
+	Notes    string
+}
+
+// Query describes a search
+type Query struct {
+	Text   string
+	UserID uint
+	// Catalog searches the books of other users instead of the user's own records.
+	// Only shared metadata is matched, never notes, and books the user already has
+	// are left out. Hits are unique by ISBN.
+	Catalog bool
+	Limit   int
+}
+
+// Hit is a matching record, ordered by descending score
+type Hit struct {
+	RecordID uint
+	ISBN     string
+	Score    float64
+}
+
+// SearchIndex is a full-text index of records. Matching is case and accent
+// insensitive and every query term must match, as a prefix or anywhere inside a
+// word, so results narrow while the user types.
+type SearchIndex interface {
+	// Index adds or replaces documents
+	Index(ctx context.Context, docs ...Document) error
+	// Delete removes the documents of the given records. Missing records are ignored.
+	Delete(ctx context.Context, recordIDs ...uint) error
+	// Search returns the best matches for a query
+	Search(ctx context.Context, query Query) ([]Hit, error)
+	// Count returns the number of indexed documents
+	Count(ctx context.Context) (int64, error)
+}
+
+// NewDocument extracts the searchable text of a record
+func NewDocument(record model.Record) Document {
+	return Document{
+		RecordID: record.ID,
+		UserID:   record.UserID,
+		ISBN:     record.ISBN,
+		Title:    record.Title,
+		Author:   record.Author,
+		Genre:    record.Genre,
+		Notes:    record.Notes,
+	}
+}
+
+// foldLatin maps letters that have no canonical decomposition to their base form
+var foldLatin = strings.NewReplacer("ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "đ", "d", "ł", "l", "ı", "i")
+
+// isThaiVowelMark reports whether r is one of the Thai vowel signs written above or
+// below a consonant. Unlike tone marks they change the word, so they are kept.
+func isThaiVowelMark(r rune) bool {
+	return r == '\u0e31' || (r >= '\u0e34' && r <= '\u0e3a')
+}
+
+// Normalize lowercases text, strips Latin accents and Thai tone marks and turns
+// everything other than letters and digits into single spaces
+func Normalize(text string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(text)) {
+		switch {
+		case isThaiVowelMark(r):
+			b.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+			// Combining accents, Thai tone marks and the silent-letter mark
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte(' ')
+		}
+	}
+	return strings.Join(strings.Fields(foldLatin.Replace(b.String())), " ")
+}
+
+// Terms splits a query into normalized terms
+func Terms(text string) []string {
+	return strings.Fields(Normalize(text))
+}
@@ -0,0 +1,51 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"case and punctuation", "Harry-Potter, Vol.1!", "harry potter vol 1"},
+		{"latin accents", "Café Crème Brûlée", "cafe creme brulee"},
+		{"letters without decomposition", "Straße Ørsted Łódź", "strasse orsted lodz"},
+		{"thai tone marks", "คู่กรรม", "คูกรรม"},
+		{"thai silent letter mark", "การ์ตูน", "การตูน"},
+		{"thai vowels above and below", "ปีศาจ สุริยะ", "ปีศาจ สุริยะ"},
+		{"thai digits", "เล่ม ๑๒", "เลม ๑๒"},
+		{"mixed scripts", "  Harry  แฮร์รี่ ", "harry แฮรรี"},
+		{"nothing searchable", " -- / ", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Normalize(tc.text); got != tc.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"The Hobbit", []string{"the", "hobbit"}},
+		{"J.R.R. Tolkien", []string{"j", "r", "r", "tolkien"}},
+		{"ทมยันตี คู่กรรม", []string{"ทมยันตี", "คูกรรม"}},
+		{"", []string{}},
+		{"?!", []string{}},
+	}
+
+	for _, tc := range tests {
+		if got := Terms(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package search
+
+import (
+	"biblia-be/internal/model"
+	"context"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// reindexBatchSize is the number of records loaded at a time by Reindex
+const reindexBatchSize = 500
+
+// Sync registers GORM callbacks that keep index in step with the records table.
+// Records created, saved or deleted through a model value are reindexed after the
+// statement; bulk updates by condition are not seen and need a Reindex. Index
+// failures are logged rather than failing the write, since the index can always
+// be rebuilt from the records.
+func Sync(db *gorm.DB, index SearchIndex) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:after_create").Register("search:index", indexRecords(index)); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:after_update").Register("search:index", indexRecords(index)); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:after_delete").Register("search:delete", deleteRecords(index))
+}
+
+// statementRecordIDs returns the IDs of the records a statement was run with
+func statementRecordIDs(tx *gorm.DB) []uint {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.Table != "records" ||
+		!tx.Statement.ReflectValue.IsValid() {
+		return nil
+	}
+
+	var ids []uint
+	switch value := tx.Statement.ReflectValue.Interface().(type) {
+	case model.Record:
+		ids = append(ids, value.ID)
+	case []model.Record:
+		for _, record := range value {
+			ids = append(ids, record.ID)
+		}
+	case []*model.Record:
+		for _, record := range value {
+			ids = append(ids, record.ID)
+		}
+	}
+
+	result := ids[:0]
+	for _, id := range ids {
+		if id != 0 {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func indexRecords(index SearchIndex) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ids := statementRecordIDs(tx)
+		if len(ids) == 0 {
+			return
+		}
+
+		// Partial updates only carry the changed columns, so read the rows back
+		// through the statement's connection, which sees its own transaction
+		var records []model.Record
+		if err := tx.Session(&gorm.Session{NewDB: true}).Where("id IN ?", ids).Find(&records).Error; err != nil {
+			log.Printf("failed to load records for search indexing: %v", err)
+			return
+		}
+
+		docs := make([]Document, len(records))
+		for i, record := range records {
+			docs[i] = NewDocument(record)
+		}
+		if err := index.Index(tx.Statement.Context, docs...); err != nil {
+			log.Printf("failed to index records for search: %v", err)
+		}
+	}
+}
+
+func deleteRecords(index SearchIndex) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		// A delete conditioned on the version may have matched nothing
+		if tx.RowsAffected == 0 {
+			return
+		}
+		if ids := statementRecordIDs(tx); len(ids) > 0 {
+			if err := index.Delete(tx.Statement.Context, ids...); err != nil {
+				log.Printf("failed to remove records from search: %v", err)
+			}
+		}
+	}
+}
+
+// Reindex indexes every record
+func Reindex(ctx context.Context, db *gorm.DB, index SearchIndex) error {
+	var records []model.Record
+	return db.WithContext(ctx).FindInBatches(&records, reindexBatchSize, func(tx *gorm.DB, batch int) error {
+		docs := make([]Document, len(records))
+		for i, record := range records {
+			docs[i] = NewDocument(record)
+		}
+		return index.Index(ctx, docs...)
+	}).Error
+}
+
+// ReindexIfEmpty builds the index from scratch when it holds no documents, as
+// after a first deployment or on every start of an in-process index
+func ReindexIfEmpty(ctx context.Context, db *gorm.DB, index SearchIndex) error {
+	count, err := index.Count(ctx)
+	if err != nil || count > 0 {
+		return err
+	}
+	return Reindex(ctx, db, index)
+}
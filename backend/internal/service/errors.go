@@ -0,0 +1,117 @@
+package service
+
+import (
+	"biblia-be/internal/i18n"
+	"errors"
+)
+
+// Kinds of domain errors. Callers match them with errors.Is and map them to
+// their transport, such as HTTP statuses.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden means the caller is known but may not do this
+	ErrForbidden = errors.New("forbidden")
+	// ErrPreconditionFailed means the client wrote with a stale version
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// Error codes are stable identifiers clients can branch on, unlike messages
+const (
+	CodeValidation         = "VALIDATION_FAILED"
+	CodeInvalidBody        = "INVALID_BODY"
+	CodeUserNotFound       = "USER_NOT_FOUND"
+	CodeUsernameTaken      = "USERNAME_TAKEN"
+	CodeInvalidCredentials = "INVALID_CREDENTIALS"
+	CodeRecordNotFound     = "RECORD_NOT_FOUND"
+	CodeRecordExists       = "RECORD_EXISTS"
+	CodeRecordModified     = "RECORD_MODIFIED"
+)
+
+// FieldError describes why one field of a request is invalid. Code is the
+// failed rule, such as required, min or max, and Param its argument.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+	// Key is the catalog key of the message, filled with the field and param
+	Key string `json:"-"`
+}
+
+// newFieldError builds a field error with its message in the default locale
+func newFieldError(field, code, param, key string) FieldError {
+	fieldErr := FieldError{Field: field, Code: code, Param: param, Key: key}
+	fieldErr.Message = fieldErr.Localize(i18n.DefaultLocale)
+	return fieldErr
+}
+
+// Localize returns the message of the field error in a locale
+func (e FieldError) Localize(locale string) string {
+	return i18n.T(locale, e.Key, i18n.Params{"field": e.Field, "param": e.Param})
+}
+
+// Error is a domain error with a message that is safe to show to clients.
+// Message is in the default locale; Localize translates it.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Fields  []FieldError
+	// Key and Params select the catalog message, by default "error." + Code
+	Key    string
+	Params i18n.Params
+}
+
+// NewError creates a domain error with the catalog message of key, or of
+// "error." + code when key is empty
+func NewError(kind error, code, key string, params i18n.Params) *Error {
+	err := &Error{Kind: kind, Code: code, Key: key, Params: params}
+	err.Message = err.Localize(i18n.DefaultLocale)
+	return err
+}
+
+// Localize returns the message of the error in a locale. Validation errors
+// read as their first invalid field.
+func (e *Error) Localize(locale string) string {
+	if len(e.Fields) > 0 {
+		return e.Fields[0].Localize(locale)
+	}
+	key := e.Key
+	if key == "" {
+		key = "error." + e.Code
+	}
+	return i18n.T(locale, key, e.Params)
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap lets errors.Is match the kind of the error
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func notFound(code string) error {
+	return NewError(ErrNotFound, code, "", nil)
+}
+
+func conflict(code string) error {
+	return NewError(ErrConflict, code, "", nil)
+}
+
+func unauthorized(code string) error {
+	return NewError(ErrUnauthorized, code, "", nil)
+}
+
+func preconditionFailed(code string) error {
+	return NewError(ErrPreconditionFailed, code, "", nil)
+}
+
+// invalidFields reports invalid fields, using the first one as the message
+func invalidFields(fields ...FieldError) error {
+	return &Error{Kind: ErrValidation, Code: CodeValidation, Message: fields[0].Message, Fields: fields}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"biblia-be/internal/model"
+	"biblia-be/internal/repository"
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// RecordService holds the reading record rules shared by the API and imports
+type RecordService struct {
+	records repository.RecordRepository
+}
+
+// NewRecordService creates a record service on a repository
+func NewRecordService(records repository.RecordRepository) *RecordService {
+	return &RecordService{records: records}
+}
+
+// updateAttempts bounds how often an update that allows any version is retried
+// when another write to the record lands between reading and saving it
+const updateAttempts = 3
+
+// Precondition holds the ETags of the record versions a client has seen, as
+// sent in If-Match. A write only goes ahead while the record still has one of
+// them. "*" and an empty precondition allow any version.
+type Precondition []string
+
+// anyVersion reports whether the precondition allows every version
+func (p Precondition) anyVersion() bool {
+	for _, tag := range p {
+		if tag == "*" {
+			return true
+		}
+	}
+	return len(p) == 0
+}
+
+// allows reports whether a record satisfies the precondition
+func (p Precondition) allows(record model.Record) bool {
+	if p.anyVersion() {
+		return true
+	}
+	etag := record.ETag()
+	for _, tag := range p {
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRecord builds a record from creation data, stamping the date added
+func NewRecord(createRecord model.CreateRecord) model.Record {
+	record := model.Record{
+		ISBN:        createRecord.ISBN,
+		UserID:      createRecord.UserID,
+		Title:       createRecord.Title,
+		Author:      createRecord.Author,
+		Cover:       createRecord.Cover,
+		Genre:       createRecord.Genre,
+		Status:      createRecord.Status,
+		CurrentPage: createRecord.CurrentPage,
+		TotalPages:  createRecord.TotalPages,
+		DateAdded:   time.Now(),
+		Shelves:     createRecord.Shelves,
+		Notes:       createRecord.Notes,
+		Rating:      createRecord.Rating,
+		Ownership:   model.OwnershipOwned,
+		Version:     1,
+	}
+	record.DateFinished = createRecord.DateFinished
+	record.SetStatus(createRecord.Status, record.DateAdded)
+	return record
+}
+
+// List returns a page of records
+func (service *RecordService) List(ctx context.Context, query repository.RecordQuery) ([]model.Record, error) {
+	return service.records.List(ctx, query)
+}
+
+// Count returns how many records match a filter
+func (service *RecordService) Count(ctx context.Context, filter repository.RecordFilter) (int64, error) {
+	return service.records.Count(ctx, filter)
+}
+
+// Facets counts the values of the named facets for a filter
+func (service *RecordService) Facets(ctx context.Context, filter repository.RecordFilter, names []string) (map[string][]repository.FacetCount, error) {
+	return service.records.Facets(ctx, filter, names)
+}
+
+// Get returns the record a user keeps of a book
+func (service *RecordService) Get(ctx context.Context, userID uint, isbn string) (model.Record, error) {
+	record, err := service.records.Get(ctx, userID, isbn)
+	if errors.Is(err, repository.ErrNotFound) {
+		return record, notFound(CodeRecordNotFound)
+	}
+	return record, err
+}
+
+// Create validates and stores a new record
+func (service *RecordService) Create(ctx context.Context, createRecord model.CreateRecord) (model.Record, error) {
+	if err := validateStruct(createRecord); err != nil {
+		return model.Record{}, err
+	}
+
+	exists, err := service.records.Exists(ctx, createRecord.UserID, createRecord.ISBN)
+	if err != nil {
+		return model.Record{}, err
+	}
+	if exists {
+		return model.Record{}, conflict(CodeRecordExists)
+	}
+
+	// A concurrent request can create the record between the check and the insert
+	record := NewRecord(createRecord)
+	if err := service.records.Create(ctx, &record); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return model.Record{}, conflict(CodeRecordExists)
+		}
+		return model.Record{}, err
+	}
+	return record, nil
+}
+
+// Import creates a record unless the user already has one of the book and
+// reports whether it did. Imported records skip the record hooks.
+func (service *RecordService) Import(ctx context.Context, createRecord model.CreateRecord) (bool, error) {
+	if err := validateStruct(createRecord); err != nil {
+		return false, err
+	}
+
+	exists, err := service.records.Exists(ctx, createRecord.UserID, createRecord.ISBN)
+	if err != nil || exists {
+		return false, err
+	}
+
+	record := NewRecord(createRecord)
+	if err := service.records.Import(ctx, &record); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Update records reading progress on a user's record of a book, provided the
+// record satisfies the precondition
+func (service *RecordService) Update(ctx context.Context, userID uint, isbn string, updateRecord model.UpdateRecord, precondition Precondition) (model.Record, error) {
+	if err := validateStruct(updateRecord); err != nil {
+		return model.Record{}, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		record, err := service.Get(ctx, userID, isbn)
+		if err != nil {
+			return model.Record{}, err
+		}
+		if !precondition.allows(record) {
+			return model.Record{}, preconditionFailed(CodeRecordModified)
+		}
+		if updateRecord.CurrentPage > record.TotalPages {
+			return model.Record{}, invalidFields(newFieldError("currentPage", "max", strconv.Itoa(int(record.TotalPages)), "field.pastTotalPages"))
+		}
+
+		before := record
+		record.SetStatus(updateRecord.Status, time.Now())
+		record.CurrentPage = updateRecord.CurrentPage
+		if updateRecord.Shelves != nil {
+			record.Shelves = updateRecord.Shelves
+		}
+		if updateRecord.Notes != nil {
+			record.Notes = *updateRecord.Notes
+		}
+		if updateRecord.Rating != nil {
+			record.Rating = *updateRecord.Rating
+		}
+
+		err = service.records.Update(ctx, before, &record)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			// Read again unless the client pinned the version it saw
+			if precondition.anyVersion() && attempt < updateAttempts {
+				continue
+			}
+			return model.Record{}, preconditionFailed(CodeRecordModified)
+		}
+		if err != nil {
+			return model.Record{}, err
+		}
+		return record, nil
+	}
+}
+
+// Delete removes a user's record of a book, provided the record satisfies the
+// precondition
+func (service *RecordService) Delete(ctx context.Context, userID uint, isbn string, precondition Precondition) error {
+	var version uint
+	if !precondition.anyVersion() {
+		record, err := service.Get(ctx, userID, isbn)
+		if err != nil {
+			return err
+		}
+		if !precondition.allows(record) {
+			return preconditionFailed(CodeRecordModified)
+		}
+		version = record.Version
+	}
+
+	err := service.records.Delete(ctx, userID, isbn, version)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return notFound(CodeRecordNotFound)
+	case errors.Is(err, repository.ErrVersionConflict):
+		return preconditionFailed(CodeRecordModified)
+	}
+	return err
+}
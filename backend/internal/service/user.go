@@ -0,0 +1,171 @@
+package service
+
+import (
+	"biblia-be/internal/kosync"
+	"biblia-be/internal/model"
+	"biblia-be/internal/repository"
+	"context"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserService holds the account rules shared by every way users are managed
+type UserService struct {
+	users repository.UserRepository
+}
+
+// NewUserService creates a user service on a repository
+func NewUserService(users repository.UserRepository) *UserService {
+	return &UserService{users: users}
+}
+
+// hashSyncKey hashes the key KOReader derives from a password so e-readers can
+// sync with the account password without the server keeping an MD5 of it
+func hashSyncKey(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(kosync.AuthKey(password)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// setPassword stores the hashes of a new password on a user
+func setPassword(user *model.User, password string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	syncKey, err := hashSyncKey(password)
+	if err != nil {
+		return err
+	}
+	user.Password = string(hashed)
+	user.SyncKey = syncKey
+	return nil
+}
+
+// checkUsername fails with a conflict when another user has the username
+func (service *UserService) checkUsername(ctx context.Context, username string, exceptID uint) error {
+	taken, err := service.users.UsernameTaken(ctx, username, exceptID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return conflict(CodeUsernameTaken)
+	}
+	return nil
+}
+
+// List returns a page of users
+func (service *UserService) List(ctx context.Context, query repository.UserQuery) ([]model.User, error) {
+	return service.users.List(ctx, query)
+}
+
+// Count returns how many users match a filter
+func (service *UserService) Count(ctx context.Context, filter repository.UserFilter) (int64, error) {
+	return service.users.Count(ctx, filter)
+}
+
+// Get returns a user, optionally with their records
+func (service *UserService) Get(ctx context.Context, id uint, withRecords bool) (model.User, error) {
+	user, err := service.users.Get(ctx, id, withRecords)
+	if errors.Is(err, repository.ErrNotFound) {
+		return user, notFound(CodeUserNotFound)
+	}
+	return user, err
+}
+
+// Create validates and stores a new account
+func (service *UserService) Create(ctx context.Context, createUser model.CreateUser) (model.User, error) {
+	if err := validateStruct(createUser); err != nil {
+		return model.User{}, err
+	}
+	if err := service.checkUsername(ctx, createUser.Username, 0); err != nil {
+		return model.User{}, err
+	}
+
+	user := model.User{
+		Username:       createUser.Username,
+		FavoriteGenres: createUser.FavoriteGenres,
+		Privacy:        model.PrivacyPublic,
+	}
+	if createUser.Privacy != "" {
+		user.Privacy = createUser.Privacy
+	}
+	if err := setPassword(&user, createUser.Password); err != nil {
+		return model.User{}, err
+	}
+
+	if err := service.users.Create(ctx, &user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return model.User{}, conflict(CodeUsernameTaken)
+		}
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// Update replaces the username, password, favorite genres and privacy of a
+// user. Pending follow requests are accepted once the profile is public.
+func (service *UserService) Update(ctx context.Context, id uint, updateUser model.UpdateUser) (model.User, error) {
+	if err := validateStruct(updateUser); err != nil {
+		return model.User{}, err
+	}
+
+	user, err := service.Get(ctx, id, false)
+	if err != nil {
+		return model.User{}, err
+	}
+	if updateUser.Username != user.Username {
+		if err := service.checkUsername(ctx, updateUser.Username, user.ID); err != nil {
+			return model.User{}, err
+		}
+	}
+
+	user.Username = updateUser.Username
+	user.FavoriteGenres = updateUser.FavoriteGenres
+	if updateUser.Privacy != "" {
+		user.Privacy = updateUser.Privacy
+	}
+	if err := setPassword(&user, updateUser.Password); err != nil {
+		return model.User{}, err
+	}
+
+	if err := service.users.Update(ctx, &user); err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// Delete removes a user and their records
+func (service *UserService) Delete(ctx context.Context, id uint) error {
+	err := service.users.Delete(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFound(CodeUserNotFound)
+	}
+	return err
+}
+
+// Authenticate checks credentials and returns the user with their records
+func (service *UserService) Authenticate(ctx context.Context, username, password string) (model.User, error) {
+	user, err := service.users.GetByUsername(ctx, username, true)
+	if errors.Is(err, repository.ErrNotFound) {
+		return model.User{}, unauthorized(CodeInvalidCredentials)
+	}
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return model.User{}, unauthorized(CodeInvalidCredentials)
+	}
+
+	// Accounts created before e-reader sync existed get their sync key on next login
+	if user.SyncKey == "" {
+		if syncKey, err := hashSyncKey(password); err == nil {
+			service.users.SetSyncKey(ctx, user.ID, syncKey)
+		}
+	}
+	return user, nil
+}
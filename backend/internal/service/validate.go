@@ -0,0 +1,99 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validate checks the binding tags of request models, the same tags gin checks
+// when it binds a request
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(JSONFieldName)
+	return v
+}
+
+// JSONFieldName names struct fields in validation errors after their JSON key
+func JSONFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" || name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// validateStruct checks a request model against its binding tags
+func validateStruct(value interface{}) error {
+	if err := validate.Struct(value); err != nil {
+		return InvalidInput(err)
+	}
+	return nil
+}
+
+// InvalidInput turns the error of decoding or validating a request into a
+// validation error, without exposing decoder internals to clients
+func InvalidInput(err error) error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, len(validationErrs))
+		for i, fieldErr := range validationErrs {
+			fields[i] = translateFieldError(fieldErr)
+		}
+		return invalidFields(fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return invalidFields(newFieldError(typeErr.Field, "type", jsonType(typeErr.Type), "field.type"))
+	}
+
+	return NewError(ErrValidation, CodeInvalidBody, "", nil)
+}
+
+// translateFieldError picks the message of a failed validation rule
+func translateFieldError(fieldErr validator.FieldError) FieldError {
+	field, rule, param := fieldErr.Field(), fieldErr.Tag(), fieldErr.Param()
+	length := fieldErr.Kind() == reflect.String || fieldErr.Kind() == reflect.Slice
+
+	key := "field.invalid"
+	switch rule {
+	case "required":
+		key = "field.required"
+	case "min", "max":
+		key = "field." + rule
+		if length {
+			key += "Length"
+		}
+	case "oneof":
+		key = "field.oneof"
+		param = strings.ReplaceAll(param, " ", ", ")
+	}
+	return newFieldError(field, rule, param, key)
+}
+
+// jsonType names the JSON type a Go type is decoded from
+func jsonType(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "date"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "number"
+	}
+}
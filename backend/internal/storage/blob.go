@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that are empty or escape the store
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobInfo describes a stored blob
+type BlobInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// BlobStore stores opaque binary objects under slash separated keys
+type BlobStore interface {
+	// Put stores the content read from r under key, replacing any existing blob
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens the blob for reading. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, BlobInfo, error)
+	// Exists reports whether a blob is stored under key
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes the blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}